@@ -3,16 +3,17 @@ package main
 import (
 	"database/sql"
 	"encoding/binary"
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
-	"strings"
 
 	"github.com/juju/errors"
-	_ "github.com/lib/pq"
 
+	"xy/names"
 	"xy/stats"
 	"xy/util"
+	"xy/veekun"
 )
 
 var (
@@ -22,14 +23,17 @@ var (
 )
 
 func main() {
-	if len(os.Args) < 5 {
-		fmt.Fprintln(os.Stderr, "usage: addpokemon database version path/to/garc n")
+	showDiff := flag.Bool("diff", false, "list the rows added, removed, and changed")
+	flag.Parse()
+	if flag.NArg() < 4 {
+		fmt.Fprintln(os.Stderr, "usage: addpokemon [-diff] database version path/to/garc n")
+		fmt.Fprintln(os.Stderr, "database is a Postgres database name or URL, an SQLite file, or dry-run:database")
 		os.Exit(1)
 	}
-	dbname := os.Args[1]
-	versionname := os.Args[2]
-	garcname := os.Args[3]
-	numbers := os.Args[4:]
+	dbname := flag.Arg(0)
+	versionname := flag.Arg(1)
+	garcname := flag.Arg(2)
+	numbers := flag.Args()[3:]
 
 	g, err := util.OpenGARC(garcname)
 	if err != nil {
@@ -38,14 +42,27 @@ func main() {
 	}
 	defer g.Close()
 
-	db, err := sql.Open("postgres", fmt.Sprintf("postgres:///%s?sslmode=disable", dbname))
+	db, err := veekun.Open(dbname)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 	defer db.Close()
 
-	err = db.QueryRow(
+	tx, err := db.Begin()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// os.Exit skips deferred calls, so roll back explicitly on errors.
+	abort := func(err error) {
+		fmt.Fprintln(os.Stderr, err)
+		tx.Rollback()
+		db.Close()
+		os.Exit(1)
+	}
+
+	err = tx.QueryRow(
 		`SELECT v.id, vg.id, g.id
 		FROM versions v
 		JOIN version_groups vg on v.version_group_id = vg.id
@@ -53,34 +70,39 @@ func main() {
 		WHERE v.identifier = $1`,
 		versionname).Scan(&version, &pair, &gen)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		abort(err)
 	}
 
 	fmt.Println(gen, pair, version)
 
 	pokemon, err := readPokemon(g)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		abort(err)
 	}
 
-	tx, err := db.Begin()
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	}
-	defer tx.Rollback()
-	for i := range numbers {
-		n, err := strconv.ParseInt(numbers[i], 0, 0)
+	// Check every number before changing anything.
+	forms := make([]*Pokemon, len(numbers))
+	for i, s := range numbers {
+		n, err := strconv.ParseInt(s, 0, 0)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
+			abort(err)
+		}
+		if n < 0 || n >= int64(len(pokemon)) {
+			abort(fmt.Errorf("no pokemon %d: %s has %d", n, garcname, len(pokemon)))
 		}
-		p := pokemon[n]
+		forms[i] = pokemon[n]
+	}
+
+	for i, p := range forms {
 		fmt.Println(i, p.Name)
-		if err := AddForm(tx, p); err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			break
+		diffs, err := AddForm(tx, p)
+		if *showDiff {
+			for _, d := range diffs {
+				d.WriteTo(os.Stdout)
+			}
+		}
+		if err != nil {
+			abort(err)
 		}
 	}
 	if err := tx.Commit(); err != nil {
@@ -133,185 +155,244 @@ func readPokemon(g *util.GARC) ([]*Pokemon, error) {
 	return list, nil
 }
 
-func AddForm(tx *sql.Tx, p *Pokemon) error {
-	var id int     // pokemon id
-	var formid int // pokemon_form id
-	if err := tx.QueryRow(`select max(id)+1 from pokemon`).Scan(&id); err != nil {
-		return err
-	}
-	if err := tx.QueryRow(`select max(id)+1 from pokemon_forms`).Scan(&formid); err != nil {
-		return err
-	}
-
+// AddForm adds a form to the database,
+// or brings it up to date if it was added before.
+// It returns the changes made to each table.
+func AddForm(tx veekun.Tx, p *Pokemon) ([]*veekun.Diff, error) {
 	// Since we are only adding alternate forms,
 	// the pokemon identifier is the same as the pokemon_form identifier
-	ident := nameToIdent(p.Name)
+	ident := names.Ident(p.Name)
 
-	_, err := tx.Exec(`INSERT INTO pokemon
-		(id, identifier, species_id, height, weight, base_experience, "order", is_default)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
-		id, ident, p.Species, p.Height, p.Weight, p.Exp, 0, p.Species == p.Index)
+	id, err := nextID(tx, "pokemon", ident)
 	if err != nil {
-		return errors.Annotate(err, "adding pokemon")
+		return nil, err
 	}
-
-	_, err = tx.Exec(`INSERT INTO pokemon_forms
-		(id, identifier, form_identifier, pokemon_id, introduced_in_version_group_id, is_default, is_battle_only, is_mega, form_order, "order")
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
-		formid, ident, ident, id, pair, true, false, false, 0, 0)
+	formid, err := nextID(tx, "pokemon_forms", ident)
 	if err != nil {
-		return errors.Annotate(err, "adding pokemon_form")
+		return nil, err
 	}
 
-	err = addTypes(tx, id, p.Type[:])
-	if err != nil {
-		return errors.Annotate(err, "adding types")
+	var diffs []*veekun.Diff
+	sync := func(what string, d *veekun.Diff, err error) error {
+		if err != nil {
+			return errors.Annotate(err, what)
+		}
+		diffs = append(diffs, d)
+		return nil
 	}
 
-	err = addAbilities(tx, id, p.Ability[:])
-	if err != nil {
-		return errors.Annotate(err, "adding abilities")
+	d, err := veekun.Sync(tx, pokemonTable, "", nil, []veekun.Record{
+		{id, ident, p.Species, p.Height, p.Weight, p.Exp, 0, p.Species == p.Index},
+	})
+	if err := sync("adding pokemon", d, err); err != nil {
+		return diffs, err
+	}
+
+	d, err = veekun.Sync(tx, formTable, "", nil, []veekun.Record{
+		{formid, ident, ident, id, pair, true, false, false, 0, 0},
+	})
+	if err := sync("adding pokemon_form", d, err); err != nil {
+		return diffs, err
+	}
+
+	d, err = addTypes(tx, id, p.Type[:])
+	if err := sync("adding types", d, err); err != nil {
+		return diffs, err
+	}
+
+	d, err = addAbilities(tx, id, p.Ability[:])
+	if err := sync("adding abilities", d, err); err != nil {
+		return diffs, err
 	}
 
 	/*
 	if p.Item[0] == p.Item[1] {
-		err = addItems(tx, id, p.Item[:1], 100)
+		d, err = addItems(tx, id, p.Item[:1], 100)
 	} else {
-		err = addItems(tx, id, p.Item[:2], 50, 5)
+		d, err = addItems(tx, id, p.Item[:2], 50, 5)
 	}
-	if err != nil {
-		return errors.Annotate(err, "adding items")
+	if err := sync("adding items", d, err); err != nil {
+		return diffs, err
 	}
 	*/
 
-	err = addStats(tx, id, p.Stat[:], p.Effort())
-	if err != nil {
-		return errors.Annotate(err, "adding stats")
+	d, err = addStats(tx, id, p.Stat[:], p.Effort())
+	if err := sync("adding stats", d, err); err != nil {
+		return diffs, err
 	}
 
 	// Egg groups are per-species
 	// Colors are per-species
 	// Names are i18n
 
-	err = addPokemonGameIndex(tx, id, p.Index)
-	if err != nil {
-		return err
+	d, err = addPokemonGameIndex(tx, id, p.Index)
+	if err := sync("adding game index", d, err); err != nil {
+		return diffs, err
 	}
 
-	err = addPokemonFormIndex(tx, formid, p.Form)
-	if err != nil {
-		return err
+	d, err = addPokemonFormIndex(tx, formid, p.Form)
+	if err := sync("adding form index", d, err); err != nil {
+		return diffs, err
 	}
-	err = addNames(tx, formid, p.Name, p.FormName)
-	if err != nil {
-		return err
+
+	d, err = addNames(tx, formid, p.Name, p.FormName)
+	if err := sync("adding names", d, err); err != nil {
+		return diffs, err
 	}
 
-	return nil
+	return diffs, nil
 }
 
-func addTypes(tx *sql.Tx, pokemon int, types []uint8) error {
-	const sql = `INSERT INTO pokemon_types (pokemon_id, slot, type_id)
-		VALUES ($1, $3,
-			(select type_id from type_game_indices
-			where game_index = $2 and generation_id = $4))`
+// nextID returns the id of the row of table with the given identifier,
+// or the next unused id if there is none.
+func nextID(tx veekun.Tx, table, ident string) (int, error) {
+	var id int
+	err := tx.QueryRow(`SELECT id FROM `+table+` WHERE identifier = $1`, ident).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+	err = tx.QueryRow(`SELECT max(id)+1 FROM ` + table).Scan(&id)
+	return id, err
+}
 
+var (
+	pokemonTable = &veekun.Table{
+		Name:    "pokemon",
+		Key:     []string{"id"},
+		Columns: []string{"identifier", "species_id", "height", "weight", "base_experience", `"order"`, "is_default"},
+	}
+	formTable = &veekun.Table{
+		Name:    "pokemon_forms",
+		Key:     []string{"id"},
+		Columns: []string{"identifier", "form_identifier", "pokemon_id", "introduced_in_version_group_id", "is_default", "is_battle_only", "is_mega", "form_order", `"order"`},
+	}
+	typeTable = &veekun.Table{
+		Name:    "pokemon_types",
+		Key:     []string{"pokemon_id", "slot"},
+		Columns: []string{"type_id"},
+	}
+	abilityTable = &veekun.Table{
+		Name:    "pokemon_abilities",
+		Key:     []string{"pokemon_id", "slot"},
+		Columns: []string{"ability_id", "is_hidden"},
+	}
+	itemTable = &veekun.Table{
+		Name:    "pokemon_items",
+		Key:     []string{"pokemon_id", "version_id", "item_id"},
+		Columns: []string{"rarity"},
+	}
+	statTable = &veekun.Table{
+		Name:    "pokemon_stats",
+		Key:     []string{"pokemon_id", "stat_id"},
+		Columns: []string{"base_stat", "effort"},
+	}
+	gameIndexTable = &veekun.Table{
+		Name:    "pokemon_game_indices",
+		Key:     []string{"pokemon_id", "version_id"},
+		Columns: []string{"game_index"},
+	}
+	formIndexTable = &veekun.Table{
+		Name:    "pokemon_form_generations",
+		Key:     []string{"pokemon_form_id", "generation_id"},
+		Columns: []string{"game_index"},
+	}
+	nameTable = &veekun.Table{
+		Name:    "pokemon_form_names",
+		Key:     []string{"pokemon_form_id", "local_language_id"},
+		Columns: []string{"form_name", "pokemon_name"},
+	}
+)
+
+// lookup returns the id found by a query.
+func lookup(tx veekun.Tx, query string, args ...interface{}) (int, error) {
+	var id int
+	err := tx.QueryRow(query, args...).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %v", args, err)
+	}
+	return id, nil
+}
+
+func addTypes(tx veekun.Tx, pokemon int, types []uint8) (*veekun.Diff, error) {
+	const sql = `SELECT type_id FROM type_game_indices
+		WHERE game_index = $1 AND generation_id = $2`
+
+	var records []veekun.Record
 	for slot, id := range types {
 		if slot > 0 && id == types[0] {
 			continue
 		}
-		_, err := tx.Exec(sql, pokemon, id, slot+1, gen)
+		typeID, err := lookup(tx, sql, id, gen)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		records = append(records, veekun.Record{pokemon, slot + 1, typeID})
 	}
-	return nil
+	return veekun.Sync(tx, typeTable, "pokemon_id = $1", []interface{}{pokemon}, records)
 }
 
-func addAbilities(tx *sql.Tx, pokemon int, abilities []uint8) error {
-	const sql = `INSERT INTO pokemon_abilities
-		(pokemon_id, ability_id, slot, is_hidden)
-		VALUES ($1, $2, $3, $4)`
-
+func addAbilities(tx veekun.Tx, pokemon int, abilities []uint8) (*veekun.Diff, error) {
+	var records []veekun.Record
 	for slot, id := range abilities {
 		if slot > 0 && id == abilities[0] {
 			continue
 		}
-		_, err := tx.Exec(sql, pokemon, id, slot+1, slot == 2)
-		if err != nil {
-			return err
-		}
+		records = append(records, veekun.Record{pokemon, slot + 1, id, slot == 2})
 	}
-	return nil
+	return veekun.Sync(tx, abilityTable, "pokemon_id = $1", []interface{}{pokemon}, records)
 }
 
-func addItems(tx *sql.Tx, pokemon int, items []uint16, rates ...int) error {
-	const sql = `INSERT INTO pokemon_items (pokemon_id, version_id, rarity, item_id)
-		VALUES ($1, $5, $3,
-			(select item_id from item_game_indices
-			where game_index = $2 and generation_id = $4))`
+func addItems(tx veekun.Tx, pokemon int, items []uint16, rates ...int) (*veekun.Diff, error) {
+	const sql = `SELECT item_id FROM item_game_indices
+		WHERE game_index = $1 AND generation_id = $2`
 
+	var records []veekun.Record
 	for slot, id := range items {
 		if id == 0 {
 			continue
 		}
-		_, err := tx.Exec(sql, pokemon, id, rates[slot], gen, version)
+		itemID, err := lookup(tx, sql, id, gen)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		records = append(records, veekun.Record{pokemon, version, itemID, rates[slot]})
 	}
-	return nil
+	return veekun.Sync(tx, itemTable, "pokemon_id = $1 AND version_id = $2", []interface{}{pokemon, version}, records)
 }
 
-func addStats(tx *sql.Tx, pokemon int, stats []uint8, effort []int) error {
-	const sql = `INSERT INTO pokemon_stats (pokemon_id, stat_id, base_stat, effort)
-		VALUES ($1, (select id from stats where game_index = $2+1), $3, $4)`
+func addStats(tx veekun.Tx, pokemon int, stats []uint8, effort []int) (*veekun.Diff, error) {
+	const sql = `SELECT id FROM stats WHERE game_index = $1+1`
+
+	var records []veekun.Record
 	for i := range stats {
-		_, err := tx.Exec(sql, pokemon, i, stats[i], effort[i])
+		statID, err := lookup(tx, sql, i)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		records = append(records, veekun.Record{pokemon, statID, stats[i], effort[i]})
 	}
-	return nil
-}
-
-func addPokemonGameIndex(tx *sql.Tx, pokemon, index int) error {
-	_, err := tx.Exec(
-		`INSERT INTO pokemon_game_indices
-		(pokemon_id, game_index, version_id)
-		VALUES ($1, $2, $3)`,
-		pokemon, index, version)
-	return err
+	return veekun.Sync(tx, statTable, "pokemon_id = $1", []interface{}{pokemon}, records)
 }
 
-func addPokemonFormIndex(tx *sql.Tx, form, index int) error {
-	_, err := tx.Exec(
-		`INSERT INTO pokemon_form_generations
-		(pokemon_form_id, game_index, generation_id)
-		VALUES ($1, $2, $3)`,
-		form, index, gen)
-	return err
+func addPokemonGameIndex(tx veekun.Tx, pokemon, index int) (*veekun.Diff, error) {
+	return veekun.Sync(tx, gameIndexTable, "", nil, []veekun.Record{
+		{pokemon, version, index},
+	})
 }
 
-func addNames(tx *sql.Tx, form int, name, formname string) error {
-	_, err := tx.Exec(
-		`INSERT INTO pokemon_form_names
-		(pokemon_form_id, local_language_id, form_name, pokemon_name)
-		VALUES ($1, $2, $3, $4)`,
-		form, 9, formname, name)
-	return err
+func addPokemonFormIndex(tx veekun.Tx, form, index int) (*veekun.Diff, error) {
+	return veekun.Sync(tx, formIndexTable, "", nil, []veekun.Record{
+		{form, gen, index},
+	})
 }
 
-func nameToIdent(s string) string {
-	replace := strings.NewReplacer(
-		"é", "e",
-		" ", "-",
-		".", "",
-		",", "",
-	)
-	s = strings.ToLower(s)
-	s = replace.Replace(s)
-	return s
+func addNames(tx veekun.Tx, form int, name, formname string) (*veekun.Diff, error) {
+	return veekun.Sync(tx, nameTable, "", nil, []veekun.Record{
+		{form, 9, formname, name},
+	})
 }
 
 var formNames = []string{
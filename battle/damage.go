@@ -0,0 +1,149 @@
+package battle
+
+import (
+	"xy/stats"
+	"xy/types"
+)
+
+// Damage classes, as in stats.MoveStats.DamageClassCode.
+const (
+	Status = iota
+	Physical
+	Special
+)
+
+type Weather int
+
+const (
+	NoWeather Weather = iota
+	Sun
+	Rain
+	Sandstorm
+	Hail
+)
+
+// Type indexes used by the damage formula, as in names.Type.
+const (
+	typeRock  = 5
+	typeFire  = 9
+	typeWater = 10
+)
+
+// Options are the conditions of an attack.
+type Options struct {
+	Crit    bool
+	Weather Weather
+
+	// Spread is set when the move hits more than one target.
+	Spread bool
+}
+
+// Range is the damage done by each of the 16 random rolls,
+// from lowest to highest.
+type Range [16]int
+
+func (r Range) Min() int { return r[0] }
+func (r Range) Max() int { return r[len(r)-1] }
+
+// Modifiers are fractions of 4096.
+const (
+	modHalf         = 2048
+	modThreeQuarter = 3072
+	modOneAndHalf   = 6144
+)
+
+// pokeRound rounds x/4096 to the nearest integer,
+// rounding halves down.
+func pokeRound(x int) int {
+	return (x + 2047) >> 12
+}
+
+func applyMod(v, mod int) int {
+	return pokeRound(v * mod)
+}
+
+// Damage calculates the damage done by one hit of move
+// from att to def.
+// Status moves and moves without a fixed power do no damage.
+// A nil *Options means no special conditions.
+func Damage(att, def *Pokemon, move *stats.MoveStats, chart *types.Chart, o *Options) Range {
+	var r Range
+	if o == nil {
+		o = new(Options)
+	}
+	if move.Power <= 1 || move.DamageClassCode == Status {
+		return r
+	}
+	atkStat, defStat := Attack, Defense
+	if move.DamageClassCode == Special {
+		atkStat, defStat = SpecialAttack, SpecialDefense
+	}
+
+	// Critical hits ignore the attacker's lowered stats
+	// and the defender's raised stats.
+	atkStage, defStage := att.Stages[atkStat], def.Stages[defStat]
+	if o.Crit {
+		if atkStage < 0 {
+			atkStage = 0
+		}
+		if defStage > 0 {
+			defStage = 0
+		}
+	}
+	a := staged(att.Stat(atkStat), atkStage)
+	d := staged(def.Stat(defStat), defStage)
+	if o.Weather == Sandstorm && move.DamageClassCode == Special && def.HasType(typeRock) {
+		d = applyMod(d, modOneAndHalf)
+	}
+	if d < 1 {
+		d = 1
+	}
+
+	base := (2*att.Level/5+2)*int(move.Power)*a/d/50 + 2
+
+	if o.Spread {
+		base = applyMod(base, modThreeQuarter)
+	}
+	switch {
+	case o.Weather == Sun && move.Type == typeFire,
+		o.Weather == Rain && move.Type == typeWater:
+		base = applyMod(base, modOneAndHalf)
+	case o.Weather == Sun && move.Type == typeWater,
+		o.Weather == Rain && move.Type == typeFire:
+		base = applyMod(base, modHalf)
+	}
+	if o.Crit {
+		base = applyMod(base, modOneAndHalf)
+	}
+
+	t := int(move.Type)
+	immune := effectiveness(1, chart, t, int(def.Base.Type[0])) == 0 ||
+		effectiveness(1, chart, t, int(def.Base.Type[1])) == 0
+	for i := range r {
+		v := base * (85 + i) / 100
+		if att.HasType(t) {
+			v = applyMod(v, modOneAndHalf)
+		}
+		v = effectiveness(v, chart, t, int(def.Base.Type[0]))
+		if def.Base.Type[1] != def.Base.Type[0] {
+			v = effectiveness(v, chart, t, int(def.Base.Type[1]))
+		}
+		if att.Burned && move.DamageClassCode == Physical {
+			v = applyMod(v, modHalf)
+		}
+		if v < 1 && !immune {
+			v = 1
+		}
+		r[i] = v
+	}
+	return r
+}
+
+// effectiveness applies the effectiveness of type atk against def to v.
+// A nil chart has no effect.
+func effectiveness(v int, chart *types.Chart, atk, def int) int {
+	if chart == nil || atk >= types.N || def >= types.N {
+		return v
+	}
+	return v * int(chart[atk][def]) / types.Effective
+}
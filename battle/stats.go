@@ -0,0 +1,128 @@
+// Package battle calculates stats and damage
+// using the formulas of the generation 6 games.
+package battle
+
+import (
+	"xy/stats"
+)
+
+// Stat indexes, in the order of stats.PokemonStats.Stat.
+const (
+	HP = iota
+	Attack
+	Defense
+	Speed
+	SpecialAttack
+	SpecialDefense
+)
+
+// A Nature raises one stat by 10% and lowers another by 10%.
+// Natures are numbered as in the games:
+// the stat raised is n/5 and the stat lowered is n%5,
+// counting Attack, Defense, Speed, Special Attack, Special Defense.
+// Natures which raise and lower the same stat have no effect.
+type Nature int
+
+var natureNames = []string{
+	"Hardy", "Lonely", "Brave", "Adamant", "Naughty",
+	"Bold", "Docile", "Relaxed", "Impish", "Lax",
+	"Timid", "Hasty", "Serious", "Jolly", "Naive",
+	"Modest", "Mild", "Quiet", "Bashful", "Rash",
+	"Calm", "Gentle", "Sassy", "Careful", "Quirky",
+}
+
+func (n Nature) String() string {
+	if 0 <= n && int(n) < len(natureNames) {
+		return natureNames[n]
+	}
+	return ""
+}
+
+// NatureByName returns the nature with the given English name.
+func NatureByName(name string) (Nature, bool) {
+	for i, s := range natureNames {
+		if s == name {
+			return Nature(i), true
+		}
+	}
+	return 0, false
+}
+
+// Modifier returns the nature's effect on the stat, in tenths:
+// 11 if it is raised, 9 if it is lowered, and 10 otherwise.
+func (n Nature) Modifier(stat int) int {
+	if stat == HP || n < 0 || n >= 25 {
+		return 10
+	}
+	up, down := int(n)/5+1, int(n)%5+1
+	switch {
+	case up == down:
+		return 10
+	case stat == up:
+		return 11
+	case stat == down:
+		return 9
+	}
+	return 10
+}
+
+// Stat calculates a stat from its base value, IV, and EV.
+func Stat(stat, base, iv, ev, level int, nature Nature) int {
+	v := (2*base + iv + ev/4) * level / 100
+	if stat == HP {
+		if base == 1 {
+			// Shedinja
+			return 1
+		}
+		return v + level + 10
+	}
+	return (v + 5) * nature.Modifier(stat) / 10
+}
+
+// A Pokemon is a Pokémon in battle.
+type Pokemon struct {
+	Base   *stats.PokemonStats
+	Level  int
+	IV     [6]int
+	EV     [6]int
+	Nature Nature
+
+	// Stages are the stat stages, from -6 to +6.
+	// Only Attack through Special Defense are used.
+	Stages [6]int
+
+	Burned bool
+}
+
+// Stat returns the stat before stat stages are applied.
+func (p *Pokemon) Stat(stat int) int {
+	return Stat(stat, int(p.Base.Stat[stat]), p.IV[stat], p.EV[stat], p.Level, p.Nature)
+}
+
+// Stats returns all six stats.
+func (p *Pokemon) Stats() [6]int {
+	var s [6]int
+	for i := range s {
+		s[i] = p.Stat(i)
+	}
+	return s
+}
+
+// HasType reports whether the Pokémon has type t.
+func (p *Pokemon) HasType(t int) bool {
+	return int(p.Base.Type[0]) == t || int(p.Base.Type[1]) == t
+}
+
+// staged applies a stat stage to v.
+func staged(v, stage int) int {
+	if stage > 6 {
+		stage = 6
+	}
+	if stage < -6 {
+		stage = -6
+	}
+	if stage >= 0 {
+		return v * (2 + stage) / 2
+	}
+	return v * 2 / (2 - stage)
+}
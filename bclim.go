@@ -0,0 +1,101 @@
+// Usage: bclim [-z] file.bclim >out.png
+// Usage: bclim -encode [-format RGBA8] [-paletted [-like original]] [-z] in.png >out.bclim
+// Convert a texture to PNG, or a PNG back to a texture.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+
+	"xy/image/ctr"
+	"xy/image/etc1"
+	"xy/lz"
+)
+
+var (
+	encode   = flag.Bool("encode", false, "convert a PNG to a texture")
+	format   = flag.String("format", "RGBA8", "pixel `format` of the encoded texture")
+	paletted = flag.Bool("paletted", false, "encode a paletted icon instead of a BCLIM")
+	colors   = flag.Int("colors", 16, "maximum number of `colors` in a paletted icon")
+	like     = flag.String("like", "", "copy the unknown header fields of a paletted icon from `file`")
+	quality  = flag.Int("quality", int(etc1.Medium), "ETC1 encoding `quality`, 0-2")
+	compress = flag.Bool("z", false, "compress or decompress with LZ11")
+)
+
+func die(v ...interface{}) {
+	fmt.Fprintln(os.Stderr, v...)
+	os.Exit(1)
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() < 1 {
+		die("usage: bclim [-encode] [-z] file")
+	}
+	b, err := ioutil.ReadFile(flag.Arg(0))
+	if err != nil {
+		die(err)
+	}
+	if *encode {
+		err = encodeMain(b)
+	} else {
+		err = decodeMain(b)
+	}
+	if err != nil {
+		die(err)
+	}
+}
+
+func decodeMain(b []byte) error {
+	if *compress {
+		var err error
+		b, err = lz.Decode(bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+	}
+	m, err := ctr.Decode(b)
+	if err != nil {
+		return err
+	}
+	return png.Encode(os.Stdout, m)
+}
+
+func encodeMain(b []byte) error {
+	m, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	o := &ctr.Options{
+		Quality:  etc1.Quality(*quality),
+		CLIM:     !*paletted,
+		Compress: *compress,
+		Colors:   *colors,
+	}
+	if *paletted {
+		if *like != "" {
+			orig, err := ioutil.ReadFile(*like)
+			if err != nil {
+				return err
+			}
+			if z, err := lz.Decode(bytes.NewReader(orig)); err == nil {
+				orig = z
+			}
+			o.PaletteHeader, o.ImagFormat, err = ctr.PaletteHeader(orig)
+			if err != nil {
+				return err
+			}
+		}
+		return ctr.EncodePaletted(os.Stdout, m, o)
+	}
+	f, err := ctr.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+	return ctr.Encode(os.Stdout, m, f, o)
+}
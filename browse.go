@@ -0,0 +1,861 @@
+// Usage: browse [-oras] [-lang en] romfs
+// Browse the files of a romfs in the terminal.
+//
+// Directories, GARCs, and DARCs can be opened like directories,
+// and LZ-compressed files are decompressed on the way.
+// Other files are shown as hex, and also as text, an image,
+// or a decoded table record if they look like one;
+// tab switches between the views.
+// Encounter tables are only decoded for X and Y.
+//
+// Keys:
+//
+//	up, down, pgup, pgdn, home, end  move (also k, j, g, G)
+//	enter, right                     open (also l)
+//	left, backspace                  go back (also h)
+//	tab                              next view
+//	/                                find a name in the list, or a line in the view
+//	n                                find again
+//	f                                search the contents of everything under the selection
+//	q                                quit
+//
+// The terminal is put in raw mode with stty.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"xy/darc"
+	"xy/export"
+	"xy/garc"
+	"xy/image/ctr"
+	"xy/lz"
+	"xy/names"
+	"xy/stats"
+	"xy/text"
+	"xy/util"
+	"xy/zone"
+)
+
+var (
+	oras = flag.Bool("oras", false, "the romfs is from Omega Ruby or Alpha Sapphire")
+	lang = flag.String("lang", "en", "decode tables with names from the game text in `language`")
+)
+
+func die(v ...interface{}) {
+	fmt.Fprintln(os.Stderr, v...)
+	os.Exit(1)
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() < 1 {
+		die("usage: browse [-oras] [-lang en] romfs")
+	}
+	romfs, err := util.OpenFS(flag.Arg(0))
+	if err != nil {
+		die(err)
+	}
+	v := names.XY
+	if *oras {
+		v = names.ORAS
+	}
+	cat, err := names.Load(romfs, v, *lang)
+	if err != nil {
+		log.Println(err)
+	}
+
+	b := &browser{
+		fs:    romfs,
+		v:     v,
+		game:  stats.GameFiles[v],
+		cat:   cat,
+		in:    bufio.NewReader(os.Stdin),
+		out:   bufio.NewWriter(os.Stdout),
+		width: 80, height: 24,
+	}
+	root := b.dir(".", nil)
+	root.name = flag.Arg(0)
+	if err := b.push(root); err != nil {
+		die(err)
+	}
+
+	restore, err := rawMode()
+	if err != nil {
+		die(err)
+	}
+	// Use the alternate screen and hide the cursor.
+	b.out.WriteString("\x1b[?1049h\x1b[?25l")
+	err = b.run()
+	b.out.WriteString("\x1b[?25h\x1b[?1049l")
+	b.out.Flush()
+	restore()
+	if err != nil {
+		die(err)
+	}
+}
+
+// rawMode puts the terminal in raw mode
+// and returns a function which restores it.
+func rawMode() (restore func(), err error) {
+	state, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("stty: %v", err)
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("stty: %v", err)
+	}
+	return func() { stty(strings.TrimSpace(state)) }, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// A node is a file or directory.
+// Files which hold other files, such as GARCs, are also directories.
+type node struct {
+	name   string
+	parent *node
+	size   int64 // -1 if unknown
+
+	list func() ([]*node, error) // set for directories
+	read func() ([]byte, error)  // set for files
+
+	garc  string // romfs path of the GARC the file is in, or ""
+	index int    // index of the file in the GARC
+
+	opened   bool
+	data     []byte
+	lz       bool // data was decompressed
+	children []*node
+}
+
+func (n *node) path() string {
+	if n.parent == nil {
+		return n.name
+	}
+	return path.Join(n.parent.path(), n.name)
+}
+
+// open reads a node's children, or its contents if it is a file.
+// Files which are GARCs or DARCs get children.
+func (n *node) open() error {
+	if n.opened {
+		return nil
+	}
+	var err error
+	if n.list != nil {
+		n.children, err = n.list()
+	} else {
+		err = n.openFile()
+	}
+	if err == nil {
+		n.opened = true
+	}
+	return err
+}
+
+func (n *node) openFile() error {
+	data, err := n.read()
+	if err != nil {
+		return err
+	}
+	if len(data) >= 4 && (data[0] == 0x10 || data[0] == 0x11) {
+		if z, err := lz.Decode(bytes.NewReader(data)); err == nil && len(z) >= len(data) {
+			data, n.lz = z, true
+		}
+	}
+	n.data = data
+	if files, err := garc.Files(bytes.NewReader(data)); err == nil {
+		n.children = garcNodes(n, files)
+		return nil
+	}
+	if len(data) >= 4 && string(data[:4]) != "CRAG" {
+		if d, err := darc.Read(bytes.NewReader(data)); err == nil && d.Root != nil {
+			n.children = darcNodes(n, d.Root)
+		}
+	}
+	return nil
+}
+
+func (n *node) isDir() bool {
+	return n.list != nil || n.children != nil
+}
+
+func (b *browser) dir(name string, parent *node) *node {
+	n := &node{name: path.Base(name), parent: parent, size: -1}
+	n.list = func() ([]*node, error) {
+		entries, err := fs.ReadDir(b.fs, name)
+		if err != nil {
+			return nil, err
+		}
+		var list []*node
+		for _, e := range entries {
+			full := path.Join(name, e.Name())
+			if e.IsDir() {
+				list = append(list, b.dir(full, n))
+				continue
+			}
+			c := &node{name: e.Name(), parent: n, size: -1, garc: full}
+			if fi, err := e.Info(); err == nil {
+				c.size = fi.Size()
+			}
+			c.read = func() ([]byte, error) { return fs.ReadFile(b.fs, full) }
+			list = append(list, c)
+		}
+		return list, nil
+	}
+	return n
+}
+
+func garcNodes(parent *node, files []*garc.File) []*node {
+	// Only the GARCs in directories hold tables.
+	g := ""
+	if parent.parent != nil && parent.parent.list != nil {
+		g = parent.garc
+	}
+	list := make([]*node, len(files))
+	for i, f := range files {
+		name := strconv.Itoa(i)
+		if f.Minor != 0 {
+			name = fmt.Sprintf("%d (%d.%d)", i, f.Major, f.Minor)
+		}
+		f := f
+		list[i] = &node{
+			name:   name,
+			parent: parent,
+			size:   f.Size(),
+			garc:   g,
+			index:  i,
+			read: func() ([]byte, error) {
+				return ioutil.ReadAll(io.NewSectionReader(&f.SectionReader, 0, f.Size()))
+			},
+		}
+	}
+	return list
+}
+
+func darcNodes(parent *node, dir *darc.Dir) []*node {
+	var list []*node
+	for _, d := range dir.Dirs {
+		d := d
+		n := &node{name: d.Name, parent: parent, size: -1}
+		n.list = func() ([]*node, error) { return darcNodes(n, d), nil }
+		list = append(list, n)
+	}
+	for _, f := range dir.Files {
+		f := f
+		list = append(list, &node{
+			name:   f.Name,
+			parent: parent,
+			size:   f.Size(),
+			read: func() ([]byte, error) {
+				return ioutil.ReadAll(io.NewSectionReader(f.SectionReader, 0, f.Size()))
+			},
+		})
+	}
+	return list
+}
+
+// A view is a way of showing a file.
+type view struct {
+	name  string
+	lines func(width, height int) []string
+}
+
+func (b *browser) views(n *node) []view {
+	var views []view
+	if r, ok := b.table(n); ok {
+		var buf bytes.Buffer
+		export.WriteYAML(&buf, []export.Record{r})
+		lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+		views = append(views, view{"table", func(int, int) []string { return lines }})
+	}
+	if lines, err := text.Read(bytes.NewReader(n.data)); err == nil && len(lines) > 0 {
+		for i, s := range lines {
+			lines[i] = fmt.Sprintf("%4d  %s", i, s)
+		}
+		views = append(views, view{"text", func(int, int) []string { return lines }})
+	}
+	if m, err := decodeImage(n.data); err == nil {
+		views = append(views, view{"image", func(w, h int) []string { return halfBlocks(m, w, h) }})
+	}
+	lines := hexLines(n.data)
+	views = append(views, view{"hex", func(int, int) []string { return lines }})
+	return views
+}
+
+func hexLines(data []byte) []string {
+	var lines []string
+	for off := 0; off < len(data); off += 16 {
+		end := off + 16
+		if end > len(data) {
+			end = len(data)
+		}
+		row := data[off:end]
+		var ascii []byte
+		for _, c := range row {
+			if c < 0x20 || c >= 0x7F {
+				c = '.'
+			}
+			ascii = append(ascii, c)
+		}
+		h := hex.EncodeToString(row)
+		var sp strings.Builder
+		for i := 0; i < len(h); i += 2 {
+			if i > 0 && i%8 == 0 {
+				sp.WriteByte(' ')
+			}
+			sp.WriteString(h[i : i+2])
+		}
+		lines = append(lines, fmt.Sprintf("%08x  %-35s  %s", off, sp.String(), ascii))
+	}
+	return lines
+}
+
+// decodeImage decodes a texture.
+// Most icons are paletted, but some are stored
+// in one of the ordinary texture formats, such as ETC1.
+func decodeImage(b []byte) (image.Image, error) {
+	foot, err := ctr.ReadFooter(b)
+	if err != nil {
+		return nil, err
+	}
+	w, h := int(foot.Imag.Width), int(foot.Imag.Height)
+	if len(b)-foot.Size() == ctr.DataSize(w, h, foot.Imag.Format) {
+		return ctr.Decode(b)
+	}
+	return ctr.DecodePaletted(b)
+}
+
+// halfBlocks draws an image with coloured half blocks,
+// two pixels to a character, scaled down to fit.
+func halfBlocks(m image.Image, width, height int) []string {
+	r := m.Bounds()
+	scale := 1
+	for r.Dx()/scale > width || r.Dy()/scale > height*2 {
+		scale++
+	}
+	at := func(x, y int) color.NRGBA {
+		c := color.NRGBAModel.Convert(m.At(r.Min.X+x*scale, r.Min.Y+y*scale)).(color.NRGBA)
+		// Draw transparent pixels over a dark grey.
+		const bg = 0x30
+		a := int(c.A)
+		blend := func(v uint8) uint8 { return uint8((int(v)*a + bg*(255-a)) / 255) }
+		return color.NRGBA{blend(c.R), blend(c.G), blend(c.B), 255}
+	}
+	w, h := r.Dx()/scale, r.Dy()/scale
+	var lines []string
+	for y := 0; y < h; y += 2 {
+		var s strings.Builder
+		for x := 0; x < w; x++ {
+			top, bot := at(x, y), color.NRGBA{0x30, 0x30, 0x30, 255}
+			if y+1 < h {
+				bot = at(x, y+1)
+			}
+			fmt.Fprintf(&s, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm▀", top.R, top.G, top.B, bot.R, bot.G, bot.B)
+		}
+		s.WriteString("\x1b[0m")
+		lines = append(lines, s.String())
+	}
+	return lines
+}
+
+// table decodes a file of one of the data tables.
+func (b *browser) table(n *node) (export.Record, bool) {
+	if n.garc == "" || n.parent == nil || n.parent.garc != n.garc {
+		return nil, false
+	}
+	le := binary.LittleEndian
+	r := bytes.NewReader(n.data)
+	switch n.garc {
+	case b.game.Personal:
+		var p stats.PokemonStats
+		if binary.Read(r, le, &p) != nil || r.Len() != 0 {
+			return nil, false
+		}
+		return p.Record(b.cat), true
+	case b.game.Moves:
+		var m stats.MoveStats
+		if binary.Read(r, le, &m) != nil || r.Len() != 0 {
+			return nil, false
+		}
+		return m.Record(b.cat), true
+	case b.game.Items:
+		var s stats.ItemStats
+		if binary.Read(r, le, &s) != nil {
+			return nil, false
+		}
+		return s.Record(b.cat), true
+	case b.game.Learnsets:
+		l, err := stats.ReadLearnset(r)
+		if err != nil {
+			return nil, false
+		}
+		return l.Record(b.cat), true
+	case b.game.Trainers:
+		var t stats.Team
+		var err error
+		if t.Trainer, err = stats.ReadTrainer(r); err != nil {
+			return nil, false
+		}
+		// The team is in another GARC.
+		if g, err := util.OpenGARCFS(b.fs, b.game.TrainerPokemon); err == nil {
+			if n.index < len(g.Files) {
+				t.Pokemon, _ = stats.ReadTeam(g.Files[n.index], &t.Trainer)
+			}
+			g.Close()
+		}
+		return t.Record(b.cat), true
+	case b.game.Zones:
+		if zone.CheckEncounters(b.v) != nil {
+			return nil, false
+		}
+		enc, err := zone.ReadEncounter(n.data)
+		if err != nil || enc == nil {
+			return nil, false
+		}
+		return enc.Record(b.cat), true
+	}
+	return nil, false
+}
+
+// A frame is a directory listing or a file being viewed.
+type frame struct {
+	node  *node
+	items []*node // for directories
+	label func(*node) string
+
+	views []view // for files
+	view  int
+
+	sel, top int
+}
+
+type browser struct {
+	fs   fs.FS
+	v    names.Version
+	game stats.Files
+	cat  *names.Catalog
+
+	in  *bufio.Reader
+	out *bufio.Writer
+
+	stack         []*frame
+	width, height int
+	status        string
+	find          string
+}
+
+func (b *browser) frame() *frame { return b.stack[len(b.stack)-1] }
+
+func (b *browser) push(n *node) error {
+	if err := n.open(); err != nil {
+		return err
+	}
+	f := &frame{node: n, label: func(n *node) string { return n.name }}
+	if n.isDir() {
+		f.items = n.children
+	} else {
+		f.views = b.views(n)
+	}
+	b.stack = append(b.stack, f)
+	return nil
+}
+
+func (b *browser) run() error {
+	for {
+		b.size()
+		b.draw()
+		if err := b.out.Flush(); err != nil {
+			return err
+		}
+		key, err := b.key()
+		if err != nil {
+			return err
+		}
+		b.status = ""
+		f := b.frame()
+		page := b.height - 2
+		switch key {
+		case "q", "\x03":
+			return nil
+		case "up", "k":
+			f.sel--
+		case "down", "j":
+			f.sel++
+		case "pgup":
+			f.sel -= page
+		case "pgdn", " ":
+			f.sel += page
+		case "home", "g":
+			f.sel = 0
+		case "end", "G":
+			f.sel = 1 << 30
+		case "enter", "right", "l":
+			if f.items != nil && f.sel < len(f.items) {
+				if err := b.push(f.items[f.sel]); err != nil {
+					b.status = err.Error()
+				}
+			}
+		case "left", "backspace", "h":
+			if len(b.stack) > 1 {
+				b.stack = b.stack[:len(b.stack)-1]
+			}
+		case "tab":
+			if len(f.views) > 0 {
+				f.view = (f.view + 1) % len(f.views)
+				f.sel, f.top = 0, 0
+			}
+		case "/":
+			s, ok := b.prompt("find: ")
+			if ok && s != "" {
+				b.find = s
+				b.findNext(f, true)
+			}
+		case "n":
+			if b.find != "" {
+				b.findNext(f, false)
+			}
+		case "f":
+			if f.items != nil && f.sel < len(f.items) {
+				s, ok := b.prompt("search contents: ")
+				if ok && s != "" {
+					b.search(f.items[f.sel], s)
+				}
+			}
+		}
+	}
+}
+
+// size gets the size of the terminal.
+func (b *browser) size() {
+	out, err := stty("size")
+	if err != nil {
+		return
+	}
+	var h, w int
+	if _, err := fmt.Sscan(out, &h, &w); err == nil && h > 2 && w > 0 {
+		b.width, b.height = w, h
+	}
+}
+
+// lines returns the lines of the current frame.
+func (b *browser) lines(f *frame) []string {
+	if f.items == nil {
+		if len(f.views) == 0 {
+			return nil
+		}
+		return f.views[f.view].lines(b.width, b.height-2)
+	}
+	lines := make([]string, len(f.items))
+	for i, n := range f.items {
+		s := f.label(n)
+		if n.isDir() {
+			s += "/"
+		}
+		if n.size >= 0 {
+			s = fmt.Sprintf("%-40s %10d", s, n.size)
+		}
+		lines[i] = s
+	}
+	return lines
+}
+
+func (b *browser) draw() {
+	f := b.frame()
+	lines := b.lines(f)
+	page := b.height - 2
+	if f.items == nil {
+		// Files scroll rather than having a selection.
+		if f.sel > len(lines)-page {
+			f.sel = len(lines) - page
+		}
+		if f.sel < 0 {
+			f.sel = 0
+		}
+		f.top = f.sel
+	} else {
+		if f.sel >= len(lines) {
+			f.sel = len(lines) - 1
+		}
+		if f.sel < 0 {
+			f.sel = 0
+		}
+		if f.sel < f.top {
+			f.top = f.sel
+		}
+		if f.sel >= f.top+page {
+			f.top = f.sel - page + 1
+		}
+	}
+
+	b.out.WriteString("\x1b[H\x1b[2J")
+	head := f.node.path()
+	if f.node.lz {
+		head += " [lz]"
+	}
+	for i, v := range f.views {
+		if i == f.view {
+			head += "  \x1b[7m" + v.name + "\x1b[27m"
+		} else {
+			head += "  " + v.name
+		}
+	}
+	b.line(0, "\x1b[1m"+head+"\x1b[0m", false)
+	for i := 0; i < page && f.top+i < len(lines); i++ {
+		b.line(i+1, lines[f.top+i], f.items != nil && f.top+i == f.sel)
+	}
+	status := b.status
+	if status == "" {
+		status = "enter open  ← back  tab view  / find  n again  f search contents  q quit"
+	}
+	b.line(b.height-1, "\x1b[2m"+status+"\x1b[0m", false)
+}
+
+// line draws a line of the screen, cut to its width.
+func (b *browser) line(y int, s string, selected bool) {
+	fmt.Fprintf(b.out, "\x1b[%dH", y+1)
+	if selected {
+		b.out.WriteString("\x1b[7m")
+	}
+	b.out.WriteString(cut(s, b.width))
+	if selected {
+		b.out.WriteString("\x1b[0m")
+	}
+}
+
+// cut shortens s to width characters, not counting escape sequences.
+func cut(s string, width int) string {
+	n := 0
+	for i := 0; i < len(s); {
+		if s[i] == 0x1b {
+			j := strings.IndexAny(s[i:], "mHJh")
+			if j < 0 {
+				return s[:i]
+			}
+			i += j + 1
+			continue
+		}
+		if n == width {
+			return s[:i] + "\x1b[0m"
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		n++
+	}
+	return s
+}
+
+// key reads a key press.
+func (b *browser) key() (string, error) {
+	c, err := b.in.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	switch c {
+	case '\r', '\n':
+		return "enter", nil
+	case '\t':
+		return "tab", nil
+	case 0x7F, 0x08:
+		return "backspace", nil
+	case 0x1b:
+	default:
+		if c < utf8.RuneSelf {
+			return string(c), nil
+		}
+		b.in.UnreadByte()
+		r, _, err := b.in.ReadRune()
+		return string(r), err
+	}
+	// An escape sequence, or just escape.
+	if b.in.Buffered() == 0 {
+		return "esc", nil
+	}
+	seq := []byte{}
+	for b.in.Buffered() > 0 {
+		c, _ := b.in.ReadByte()
+		seq = append(seq, c)
+		if len(seq) > 1 && (c >= 'A' && c <= 'Z' || c == '~') {
+			break
+		}
+	}
+	switch string(seq) {
+	case "[A", "OA":
+		return "up", nil
+	case "[B", "OB":
+		return "down", nil
+	case "[C", "OC":
+		return "right", nil
+	case "[D", "OD":
+		return "left", nil
+	case "[5~":
+		return "pgup", nil
+	case "[6~":
+		return "pgdn", nil
+	case "[H", "[1~", "OH":
+		return "home", nil
+	case "[F", "[4~", "OF":
+		return "end", nil
+	}
+	return "esc", nil
+}
+
+// prompt reads a line on the status line.
+// It returns false if it was cancelled with escape.
+func (b *browser) prompt(p string) (string, bool) {
+	var s []rune
+	for {
+		b.status = p + string(s)
+		b.draw()
+		b.out.WriteString("\x1b[?25h")
+		b.out.Flush()
+		key, err := b.in.ReadByte()
+		b.out.WriteString("\x1b[?25l")
+		if err != nil {
+			return "", false
+		}
+		switch {
+		case key == '\r' || key == '\n':
+			b.status = ""
+			return string(s), true
+		case key == 0x1b || key == 0x03:
+			// Drop the rest of an escape sequence.
+			for b.in.Buffered() > 0 {
+				b.in.ReadByte()
+			}
+			b.status = ""
+			return "", false
+		case key == 0x7F || key == 0x08:
+			if len(s) > 0 {
+				s = s[:len(s)-1]
+			}
+		case key >= 0x20:
+			b.in.UnreadByte()
+			r, _, _ := b.in.ReadRune()
+			s = append(s, r)
+		}
+	}
+}
+
+// findNext moves to the next line containing b.find,
+// starting at the current one if here is set.
+func (b *browser) findNext(f *frame, here bool) {
+	lines := b.lines(f)
+	needle := strings.ToLower(b.find)
+	start := f.sel + 1
+	if here {
+		start = f.sel
+	}
+	for i := 0; i < len(lines); i++ {
+		j := (start + i) % len(lines)
+		if strings.Contains(strings.ToLower(lines[j]), needle) {
+			f.sel = j
+			return
+		}
+	}
+	b.status = "not found: " + b.find
+}
+
+// search finds the files under n whose contents or text contain s,
+// and lists them.
+// The query is matched as ASCII and UTF-16 text,
+// or as bytes if it is written in hex starting with 0x.
+func (b *browser) search(n *node, s string) {
+	var patterns [][]byte
+	if strings.HasPrefix(s, "0x") {
+		p, err := hex.DecodeString(strings.Replace(s[2:], " ", "", -1))
+		if err != nil {
+			b.status = err.Error()
+			return
+		}
+		patterns = append(patterns, p)
+	} else {
+		patterns = append(patterns, []byte(s))
+		u := utf16.Encode([]rune(s))
+		p := make([]byte, 2*len(u))
+		for i, c := range u {
+			binary.LittleEndian.PutUint16(p[2*i:], c)
+		}
+		patterns = append(patterns, p)
+	}
+	needle := strings.ToLower(s)
+
+	b.status = "searching..."
+	b.draw()
+	b.out.Flush()
+
+	const max = 1000
+	var found []*node
+	var walk func(n *node)
+	walk = func(n *node) {
+		if len(found) >= max || n.open() != nil {
+			return
+		}
+		if n.isDir() {
+			for _, c := range n.children {
+				walk(c)
+			}
+			return
+		}
+		if matches(n.data, patterns, needle) {
+			found = append(found, n)
+		} else {
+			// Don't keep every file in memory.
+			n.opened, n.data = false, nil
+		}
+	}
+	walk(n)
+	if len(found) == 0 {
+		b.status = "not found: " + s
+		return
+	}
+	root := &node{name: fmt.Sprintf("search %q", s), parent: n.parent, opened: true, children: found}
+	root.list = func() ([]*node, error) { return found, nil }
+	b.stack = append(b.stack, &frame{node: root, items: found, label: func(c *node) string {
+		return strings.TrimPrefix(c.path(), n.parent.path()+"/")
+	}})
+	if len(found) == max {
+		b.status = fmt.Sprintf("showing the first %d matches", max)
+	}
+}
+
+func matches(data []byte, patterns [][]byte, needle string) bool {
+	for _, p := range patterns {
+		if bytes.Contains(data, p) {
+			return true
+		}
+	}
+	// The game text is encrypted.
+	if lines, err := text.Read(bytes.NewReader(data)); err == nil {
+		for _, l := range lines {
+			if strings.Contains(strings.ToLower(l), needle) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -1,309 +1,234 @@
+// Usage: cro [-dump] [-base addr -o out.bin] file.cro
+// Usage: cro elf file.cro out.elf
+// Usage: cro [-crr static.crr] verify|fixhash file.cro...
+// Print the header, segments, exports, and imports of a CRO module.
+// With -dump, write the text segment to stdout.
+// With -o, write the module as loaded at the -base address,
+// with its internal relocations applied.
+// The elf command converts the module to an ARM ELF relocatable object.
+// The verify command checks the hash tables of modules
+// and that they are listed in the CRR.
+// The fixhash command recomputes the hash tables of modified modules
+// in place, and updates the CRR to match.
 package main
 
 import (
-	"bytes"
-	"encoding/binary"
 	"encoding/hex"
 	"flag"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
+	"strconv"
 
 	"github.com/kr/pretty"
-)
-
-var le = binary.LittleEndian
-
-var Magic = [4]byte{'C', 'R', 'O', '0'}
-
-type Header struct {
-	HashTable [0x80]uint8
-	Magic     [4]byte
-	CodeSize0 uint32
-	A         [2]uint32
-	FileSize  uint32
-	B         [6]uint32
-	BaseAddr  uint32
-
-	CodeOffset    uint32
-	CodeSize      uint32
-	C             uint32
-	D             uint32
-	NameOffset    uint32
-	NameSize      uint32
-	SegmentOffset uint32
-	SegmentCount  uint32
-
-	ExportOffset       uint32
-	ExportCount        uint32
-	E                  uint32
-	F                  uint32
-	ExportStringOffset uint32
-	ExportStringSize   uint32
-	ExportTreeOffset   uint32
-	ExportTreeSize     uint32
-	G                  uint32
-	H                  uint32
-
-	ImportPatchOffset uint32
-	ImportPatchCount  uint32
-	ImportTable       [3]struct {
-		Offset uint32
-		Count  uint32
-	}
-	ImportStringOffset uint32
-	ImportStringSize   uint32
-	I                  [2]uint32
-	PatchOffset        uint32
-	PatchCount         uint32
-	J                  [2]uint32
-
-	K [18]uint32
-}
-
-type Segment struct {
-	Offset uint32
-	Size   uint32
-	ID     uint32
-}
-
-type Patch struct {
-	Dest uint32
-	Type uint8
-	Seg  uint8
-	_    uint8
-	_    uint8
-	X    uint32
-}
-
-type Import struct {
-	NameOffset  uint32
-	PatchOffset uint32
-}
 
-type AnonymousImport struct {
-	SegOffset   uint32
-	PatchOffset uint32
-}
+	"xy/cro"
+)
 
-type Export struct {
-	NameOffset uint32
-	DataOffset uint32
-}
+var (
+	dump    = flag.Bool("dump", false, "dump the text segment")
+	base    = flag.String("base", "0", "load `address` for -o")
+	outname = flag.String("o", "", "write the relocated image to `file`")
+	hexdump = flag.Bool("x", false, "hex dump each segment")
+	crrname = flag.String("crr", "", "check or update module hashes in `static.crr`")
+)
 
 func main() {
-	dump := flag.Bool("dump", false, "")
 	flag.Parse()
-	if *dump {
-		dumpmain()
-	} else {
-		printmain()
+	if flag.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "usage: cro [-dump] [-base addr -o out.bin] file.cro")
+		fmt.Fprintln(os.Stderr, "       cro elf file.cro out.elf")
+		fmt.Fprintln(os.Stderr, "       cro [-crr static.crr] verify|fixhash file.cro...")
+		os.Exit(1)
+	}
+	if cmd := flag.Arg(0); cmd == "verify" || cmd == "fixhash" {
+		if !hashmain(cmd == "fixhash", flag.Args()[1:]) {
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.Arg(0) == "elf" {
+		if flag.NArg() != 3 {
+			fmt.Fprintln(os.Stderr, "usage: cro elf file.cro out.elf")
+			os.Exit(1)
+		}
+		err := elfmain(flag.Arg(1), flag.Arg(2))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
-}
-
-func dumpmain() {
 	f, err := os.Open(flag.Arg(0))
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
-	var header Header
-	err = binary.Read(f, le, &header)
+	m, err := cro.Read(f)
+	f.Close()
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
-	if header.Magic != Magic {
-		fmt.Println("not a cro")
-		return
+
+	switch {
+	case *dump:
+		os.Stdout.Write(m.Contents(0))
+	case *outname != "":
+		addr, err := strconv.ParseUint(*base, 0, 32)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		img, err := m.Relocate(uint32(addr))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		err = ioutil.WriteFile(*outname, img, 0666)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	default:
+		printmain(m)
 	}
+}
 
-	segments := make([]Segment, header.SegmentCount)
-	_, err = f.Seek(int64(header.SegmentOffset), 0)
+func elfmain(in, out string) error {
+	f, err := os.Open(in)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
-	err = binary.Read(f, le, segments)
+	m, err := cro.Read(f)
+	f.Close()
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
-
-	seg := segments[0]
-	_, err = f.Seek(int64(seg.Offset), 0)
+	w, err := os.Create(out)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
-	_, err = io.CopyN(os.Stdout, f, int64(seg.Size))
-	if err != nil {
-		fmt.Println(err)
-		return
+	err = m.WriteELF(w)
+	if cerr := w.Close(); err == nil {
+		err = cerr
 	}
+	return err
 }
 
-func printmain() {
-	f, err := os.Open(flag.Arg(0))
-	if err != nil {
-		fmt.Println(err)
-		return
+func hashmain(fix bool, files []string) (ok bool) {
+	var crr *cro.CRR
+	if *crrname != "" {
+		b, err := ioutil.ReadFile(*crrname)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return false
+		}
+		crr, err = cro.ParseCRR(b)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, *crrname+":", err)
+			return false
+		}
+	}
+	ok = true
+	for _, filename := range files {
+		if err := hashfile(crr, fix, filename); err != nil {
+			fmt.Fprintln(os.Stderr, filename+":", err)
+			ok = false
+		}
 	}
-	contents, err := ioutil.ReadFile(flag.Arg(0))
+	if fix && crr != nil {
+		err := ioutil.WriteFile(*crrname, crr.Data, 0666)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return false
+		}
+	}
+	return ok
+}
+
+func hashfile(crr *cro.CRR, fix bool, filename string) error {
+	b, err := ioutil.ReadFile(filename)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
-	var header Header
-	err = binary.Read(f, le, &header)
+	m, err := cro.Parse(b)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
-	if header.Magic != Magic {
-		fmt.Println("not a cro")
-		return
+	if !fix {
+		if err := m.VerifyHashes(); err != nil {
+			return err
+		}
+		if crr != nil {
+			return crr.Verify(m)
+		}
+		return nil
 	}
-	pretty.Println(header)
+	if m.VerifyHashes() == nil && (crr == nil || crr.Verify(m) == nil) {
+		return nil
+	}
+	if crr != nil {
+		err = crr.Fix(m)
+	} else {
+		err = m.FixHashes()
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %v\n", filename, m.ModuleHash())
+	return ioutil.WriteFile(filename, m.Data, 0666)
+}
+
+func printmain(m *cro.Module) {
+	h := m.Header
+	h.HashTable = [0x80]uint8{}
+	pretty.Println(h)
 
-	fmt.Printf("Name: %s\n", contents[header.NameOffset:][:header.NameSize-1])
+	fmt.Printf("Name: %s\n", m.Name)
 
 	fmt.Println("Segments")
-	segments := make([]Segment, header.SegmentCount)
-	f.Seek(int64(header.SegmentOffset), 0)
-	err = binary.Read(f, le, segments)
-	if err != nil {
-		fmt.Println(err)
-		return
+	for i, s := range m.Segments {
+		fmt.Printf("%d %-7s offset=%x size=%x\n", i, s.Type, s.Offset, s.Size)
 	}
-	pretty.Println(segments)
 
 	fmt.Println("Exports")
-	exports := make([]Export, header.ExportCount)
-	f.Seek(int64(header.ExportOffset), 0)
-	err = binary.Read(f, le, exports)
-	if err != nil {
-		fmt.Println(err)
-		return
+	for _, e := range m.Exports {
+		fmt.Printf("%v %s\n", e.Tag, e.Name)
 	}
-	//fmt.Println(exports)
-	for _, e := range exports {
-		name := contents[e.NameOffset:]
-		name = name[:bytes.IndexByte(name, 0)]
-		fmt.Printf("%x %s\n", e.DataOffset, name)
+	for i, t := range m.IndexedExports {
+		fmt.Printf("%v #%d\n", t, i)
 	}
 
-	err = patch(f, &header, segments, contents)
-	if err != nil {
-		fmt.Println(err)
-		return
+	fmt.Println("Imports")
+	for _, imp := range m.Imports {
+		fmt.Printf("%s\n", imp.Name)
+		printRelocs(imp.Relocs)
 	}
-
-	if true {
-		imports := make([]AnonymousImport, header.ImportTable[2].Count)
-
-		f.Seek(int64(header.ImportTable[2].Offset), 0)
-		err = binary.Read(f, le, imports)
-		if err != nil {
-			fmt.Println(err)
-			return
+	for _, mod := range m.Modules {
+		for _, imp := range mod.Indexed {
+			fmt.Printf("%s #%d\n", mod.Name, imp.Index)
+			printRelocs(imp.Relocs)
 		}
-	importloop:
-		for _, imp := range imports {
-			//fmt.Printf("Import %d\n", i)
-			//fmt.Printf(" Name offset: %x\n", imp.NameOffset)
-			//fmt.Printf(" Patch offset: %x\n", imp.PatchOffset)
-
-			/*
-				var name []byte
-				if int(imp.NameOffset) < len(contents) {
-					name = contents[imp.NameOffset:]
-					name = name[:bytes.IndexByte(name, 0)]
-				}
-			*/
-			patch, err := readPatch(f, int64(imp.PatchOffset))
-			if err != nil {
-				fmt.Println(err)
-				continue
-			}
-			for off := imp.PatchOffset; ; off += 12 {
-				fmt.Printf("Import: target seg=%x, offset=%x, patch seg=%d,off=%x, islast=%x\n",
-					imp.SegOffset&0x0F, imp.SegOffset>>4,
-					patch.Dest&0xF, patch.Dest>>4, patch.Seg)
-
-				// for import patches, seg is actually an "is last" flag
-				if patch.Seg != 0 {
-					break
-				}
-				patch, err = readPatch(f, int64(off+12))
-				if err != nil {
-					fmt.Println(err)
-					continue importloop
-				}
-			}
-			// In table 3 at least,
-			// X is always zero (which kind of makes sense)
-			// however the first word is definitely not a name offset
+		for _, imp := range mod.Anonymous {
+			fmt.Printf("%s %v\n", mod.Name, imp.Tag)
+			printRelocs(imp.Relocs)
 		}
 	}
 
-	fmt.Println("Text segment contents:")
-	fmt.Print(hex.Dump(contents[segments[0].Offset:][:segments[0].Size]))
+	fmt.Println("Internal relocations")
+	printRelocs(m.InternalRelocations)
 
-	fmt.Println("Data segment contents:")
-	fmt.Print(hex.Dump(contents[segments[1].Offset:][:segments[1].Size]))
-
-	fmt.Println("Other data segment contents????")
-	fmt.Print(hex.Dump(contents[segments[2].Offset:][:segments[2].Size]))
-
-}
-
-func readPatch(f *os.File, off int64) (p Patch, _ error) {
-	_, err := f.Seek(off, os.SEEK_SET)
-	if err != nil {
-		return Patch{}, err
-	}
-	err = binary.Read(f, le, &p)
-	if err != nil {
-		return Patch{}, err
+	if *hexdump {
+		for i, s := range m.Segments {
+			fmt.Printf("%s segment contents:\n", s.Type)
+			fmt.Print(hex.Dump(m.Contents(i)))
+		}
 	}
-	return p, nil
 }
 
-func patch(f *os.File, header *Header, segments []Segment, contents []byte) error {
-	// Patches
-	patches := make([]Patch, header.PatchCount)
-	f.Seek(int64(header.PatchOffset), 0)
-	err := binary.Read(f, le, patches)
-	if err != nil {
-		return err
-	}
-
-	for _, p := range patches {
-		dest := p.Dest >> 4
-		seg := int(p.Dest & 0xF)
-		if seg > len(segments) {
-			fmt.Fprintln(os.Stderr, "segment out of range")
-			continue
-		}
-		base := segments[seg].Offset
-		if int(p.Seg) > len(segments) {
-			fmt.Fprintln(os.Stderr, "segment out of range")
-			continue
-		}
-		xbase := segments[p.Seg].Offset
-		switch p.Type {
-		case 2: // Absolute address
-			off := base + dest
-			orig := le.Uint32(contents[off:])
-			if orig != 0 {
-				fmt.Printf("Patch: segment=%d, offset=%x, x=%x+%x\n", seg, dest, orig, p.X)
-			}
-			fmt.Printf("Patch: segment=%d, offset=%x, x=%x+%x\n", seg, dest, xbase, p.X)
-			le.PutUint32(contents[off:], p.X)
-			_ = xbase
-		}
+func printRelocs(relocs []cro.Relocation) {
+	for _, r := range relocs {
+		fmt.Printf("  %v %v seg=%d addend=%x\n", r.Target, r.Type, r.Segment, r.Addend)
 	}
-	return nil
 }
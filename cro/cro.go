@@ -0,0 +1,481 @@
+// Package cro reads CRO modules, the dynamically loaded libraries of the 3DS.
+//
+// A CRO is a header followed by a number of tables, all located by
+// file offsets in the header. Locations within the module are given
+// by segment tags, which combine a segment index and an offset into it.
+package cro
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+var le = binary.LittleEndian
+
+var Magic = [4]byte{'C', 'R', 'O', '0'}
+
+var ErrHeader = errors.New("cro: invalid header")
+
+// HeaderSize is the size of the CRO header, including the hash table.
+const HeaderSize = 0x138
+
+type Header struct {
+	HashTable  [0x80]uint8 // SHA-256 hashes of the module
+	Magic      [4]byte
+	NameOffset uint32
+	NextCRO    uint32 // set by the loader
+	PrevCRO    uint32 // set by the loader
+	FileSize   uint32
+	BSSSize    uint32
+	FixedSize  uint32
+	_          uint32 // always 0
+
+	UnknownSegmentTag      SegmentTag
+	OnLoadSegmentTag       SegmentTag // function called after loading
+	OnExitSegmentTag       SegmentTag // function called before unloading
+	OnUnresolvedSegmentTag SegmentTag // function called for unresolved imports
+
+	CodeOffset       uint32
+	CodeSize         uint32
+	DataOffset       uint32
+	DataSize         uint32
+	ModuleNameOffset uint32
+	ModuleNameSize   uint32
+	SegmentOffset    uint32
+	SegmentCount     uint32
+
+	ExportNamedOffset   uint32
+	ExportNamedCount    uint32
+	ExportIndexedOffset uint32
+	ExportIndexedCount  uint32
+	ExportStringOffset  uint32
+	ExportStringSize    uint32
+	ExportTreeOffset    uint32
+	ExportTreeCount     uint32
+
+	ImportModuleOffset       uint32
+	ImportModuleCount        uint32
+	ExternalRelocationOffset uint32
+	ExternalRelocationCount  uint32
+	ImportNamedOffset        uint32
+	ImportNamedCount         uint32
+	ImportIndexedOffset      uint32
+	ImportIndexedCount       uint32
+	ImportAnonymousOffset    uint32
+	ImportAnonymousCount     uint32
+	ImportStringOffset       uint32
+	ImportStringSize         uint32
+
+	StaticAnonymousOffset    uint32
+	StaticAnonymousCount     uint32
+	InternalRelocationOffset uint32
+	InternalRelocationCount  uint32
+	StaticRelocationOffset   uint32
+	StaticRelocationCount    uint32
+}
+
+// A SegmentTag locates a byte within a module.
+// The low 4 bits are the segment index
+// and the rest are the offset within the segment.
+type SegmentTag uint32
+
+func (t SegmentTag) Segment() int   { return int(t & 0xF) }
+func (t SegmentTag) Offset() uint32 { return uint32(t >> 4) }
+
+func (t SegmentTag) String() string {
+	return fmt.Sprintf("%d:%x", t.Segment(), t.Offset())
+}
+
+// MakeTag returns the segment tag for an offset within a segment.
+func MakeTag(seg int, off uint32) SegmentTag {
+	return SegmentTag(off<<4 | uint32(seg)&0xF)
+}
+
+type SegmentType uint32
+
+const (
+	Text SegmentType = iota
+	ROData
+	Data
+	BSS
+)
+
+func (t SegmentType) String() string {
+	switch t {
+	case Text:
+		return ".text"
+	case ROData:
+		return ".rodata"
+	case Data:
+		return ".data"
+	case BSS:
+		return ".bss"
+	}
+	return fmt.Sprintf("SegmentType(%d)", uint32(t))
+}
+
+type Segment struct {
+	Offset uint32
+	Size   uint32
+	Type   SegmentType
+}
+
+// A Symbol is a named location in a module.
+type Symbol struct {
+	Name string
+	Tag  SegmentTag
+}
+
+// An ExportTreeNode is a node of the crit-bit tree used to look up
+// exports by name.
+type ExportTreeNode struct {
+	TestBit     uint16 // bit to test: byte index << 3 | bit index
+	Left        uint16 // next node if the bit is clear
+	Right       uint16 // next node if the bit is set
+	ExportIndex uint16 // index into the named exports
+}
+
+// Child pointers in the export tree.
+// The high bit marks a leaf.
+const (
+	treeEnd   = 0x8000
+	treeIndex = 0x7FFF
+)
+
+// An ImportModule lists the symbols imported from another module.
+type ImportModule struct {
+	Name      string
+	Indexed   []IndexedImport
+	Anonymous []AnonymousImport
+}
+
+// A NamedImport is a symbol imported by name.
+type NamedImport struct {
+	Name   string
+	Relocs []Relocation
+}
+
+// An IndexedImport is a symbol imported by its index
+// in the other module's indexed export table.
+type IndexedImport struct {
+	Index  uint32
+	Relocs []Relocation
+}
+
+// An AnonymousImport is a location in another module.
+type AnonymousImport struct {
+	Tag    SegmentTag
+	Relocs []Relocation
+}
+
+// A Module is a parsed CRO file.
+type Module struct {
+	Header   Header
+	Name     string
+	Segments []Segment
+
+	Exports        []Symbol     // named exports
+	IndexedExports []SegmentTag // exports by index
+	ExportTree     []ExportTreeNode
+
+	Modules             []ImportModule
+	Imports             []NamedImport
+	IndexedImports      []IndexedImport
+	AnonymousImports    []AnonymousImport
+	ExternalRelocations []Relocation
+
+	// StaticAnonymous is only used by static.crs.
+	// Its relocations are batches in StaticRelocations
+	// rather than in ExternalRelocations.
+	StaticAnonymous     []AnonymousImport
+	InternalRelocations []Relocation
+	StaticRelocations   []Relocation
+
+	// Data is the contents of the file.
+	Data []byte
+}
+
+// Read reads a module from r.
+func Read(r io.Reader) (*Module, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Parse parses a module.
+// The module keeps a reference to b.
+func Parse(b []byte) (*Module, error) {
+	m := &Module{Data: b}
+	if len(b) < HeaderSize {
+		return nil, ErrHeader
+	}
+	err := binary.Read(bytes.NewReader(b), le, &m.Header)
+	if err != nil {
+		return nil, err
+	}
+	h := &m.Header
+	if h.Magic != Magic {
+		return nil, ErrHeader
+	}
+
+	m.Name, err = m.cstring(h.ModuleNameOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Segments = make([]Segment, h.SegmentCount)
+	if err := m.table(h.SegmentOffset, m.Segments); err != nil {
+		return nil, fmt.Errorf("cro: segment table: %v", err)
+	}
+
+	// Exports
+	named := make([]struct {
+		NameOffset uint32
+		Tag        SegmentTag
+	}, h.ExportNamedCount)
+	if err := m.table(h.ExportNamedOffset, named); err != nil {
+		return nil, fmt.Errorf("cro: export table: %v", err)
+	}
+	for _, e := range named {
+		name, err := m.cstring(e.NameOffset)
+		if err != nil {
+			return nil, err
+		}
+		m.Exports = append(m.Exports, Symbol{name, e.Tag})
+	}
+	m.IndexedExports = make([]SegmentTag, h.ExportIndexedCount)
+	if err := m.table(h.ExportIndexedOffset, m.IndexedExports); err != nil {
+		return nil, fmt.Errorf("cro: indexed export table: %v", err)
+	}
+	m.ExportTree = make([]ExportTreeNode, h.ExportTreeCount)
+	if err := m.table(h.ExportTreeOffset, m.ExportTree); err != nil {
+		return nil, fmt.Errorf("cro: export tree: %v", err)
+	}
+
+	// Imports
+	m.ExternalRelocations = make([]Relocation, h.ExternalRelocationCount)
+	if err := m.table(h.ExternalRelocationOffset, m.ExternalRelocations); err != nil {
+		return nil, fmt.Errorf("cro: external relocation table: %v", err)
+	}
+
+	imports := make([]struct {
+		NameOffset  uint32
+		RelocOffset uint32
+	}, h.ImportNamedCount)
+	if err := m.table(h.ImportNamedOffset, imports); err != nil {
+		return nil, fmt.Errorf("cro: import table: %v", err)
+	}
+	for _, e := range imports {
+		name, err := m.cstring(e.NameOffset)
+		if err != nil {
+			return nil, err
+		}
+		relocs, err := m.batch(e.RelocOffset)
+		if err != nil {
+			return nil, err
+		}
+		m.Imports = append(m.Imports, NamedImport{name, relocs})
+	}
+
+	m.IndexedImports, err = m.indexedImports(h.ImportIndexedOffset, h.ImportIndexedCount)
+	if err != nil {
+		return nil, err
+	}
+	m.AnonymousImports, err = m.anonymousImports(h.ImportAnonymousOffset, h.ImportAnonymousCount)
+	if err != nil {
+		return nil, err
+	}
+
+	modules := make([]struct {
+		NameOffset      uint32
+		IndexedOffset   uint32
+		IndexedCount    uint32
+		AnonymousOffset uint32
+		AnonymousCount  uint32
+	}, h.ImportModuleCount)
+	if err := m.table(h.ImportModuleOffset, modules); err != nil {
+		return nil, fmt.Errorf("cro: import module table: %v", err)
+	}
+	for _, e := range modules {
+		var mod ImportModule
+		mod.Name, err = m.cstring(e.NameOffset)
+		if err != nil {
+			return nil, err
+		}
+		mod.Indexed, err = m.indexedImports(e.IndexedOffset, e.IndexedCount)
+		if err != nil {
+			return nil, err
+		}
+		mod.Anonymous, err = m.anonymousImports(e.AnonymousOffset, e.AnonymousCount)
+		if err != nil {
+			return nil, err
+		}
+		m.Modules = append(m.Modules, mod)
+	}
+
+	// Static tables
+	m.InternalRelocations = make([]Relocation, h.InternalRelocationCount)
+	if err := m.table(h.InternalRelocationOffset, m.InternalRelocations); err != nil {
+		return nil, fmt.Errorf("cro: internal relocation table: %v", err)
+	}
+	m.StaticRelocations = make([]Relocation, h.StaticRelocationCount)
+	if err := m.table(h.StaticRelocationOffset, m.StaticRelocations); err != nil {
+		return nil, fmt.Errorf("cro: static relocation table: %v", err)
+	}
+	static := make([]struct {
+		Tag         SegmentTag
+		RelocOffset uint32
+	}, h.StaticAnonymousCount)
+	if err := m.table(h.StaticAnonymousOffset, static); err != nil {
+		return nil, fmt.Errorf("cro: static anonymous table: %v", err)
+	}
+	for _, e := range static {
+		relocs, err := findBatch(m.StaticRelocations, h.StaticRelocationOffset, e.RelocOffset)
+		if err != nil {
+			return nil, err
+		}
+		m.StaticAnonymous = append(m.StaticAnonymous, AnonymousImport{e.Tag, relocs})
+	}
+
+	return m, nil
+}
+
+// table reads a table of fixed-size entries at off into v.
+func (m *Module) table(off uint32, v interface{}) error {
+	size := binary.Size(v)
+	if size < 0 {
+		panic("cro: bad table type")
+	}
+	if size == 0 {
+		return nil
+	}
+	if int64(off)+int64(size) > int64(len(m.Data)) {
+		return io.ErrUnexpectedEOF
+	}
+	return binary.Read(bytes.NewReader(m.Data[off:]), le, v)
+}
+
+// cstring returns the NUL-terminated string at off.
+func (m *Module) cstring(off uint32) (string, error) {
+	if int64(off) >= int64(len(m.Data)) {
+		return "", fmt.Errorf("cro: string offset %#x out of range", off)
+	}
+	s := m.Data[off:]
+	if i := bytes.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return string(s), nil
+}
+
+// batch reads the batch of relocations at off
+// in the external relocation table.
+func (m *Module) batch(off uint32) ([]Relocation, error) {
+	return findBatch(m.ExternalRelocations, m.Header.ExternalRelocationOffset, off)
+}
+
+// findBatch returns the batch of relocations at off
+// in a relocation table which starts at start.
+func findBatch(table []Relocation, start, off uint32) ([]Relocation, error) {
+	if off < start || (off-start)%relocSize != 0 {
+		return nil, fmt.Errorf("cro: bad relocation batch offset %#x", off)
+	}
+	i := int((off - start) / relocSize)
+	for j := i; j < len(table); j++ {
+		if table[j].IsLast() {
+			return table[i : j+1], nil
+		}
+	}
+	return nil, fmt.Errorf("cro: unterminated relocation batch at %#x", off)
+}
+
+func (m *Module) indexedImports(off, n uint32) ([]IndexedImport, error) {
+	raw := make([]struct {
+		Index       uint32
+		RelocOffset uint32
+	}, n)
+	if err := m.table(off, raw); err != nil {
+		return nil, fmt.Errorf("cro: indexed import table: %v", err)
+	}
+	var list []IndexedImport
+	for _, e := range raw {
+		relocs, err := m.batch(e.RelocOffset)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, IndexedImport{e.Index, relocs})
+	}
+	return list, nil
+}
+
+func (m *Module) anonymousImports(off, n uint32) ([]AnonymousImport, error) {
+	raw := make([]struct {
+		Tag         SegmentTag
+		RelocOffset uint32
+	}, n)
+	if err := m.table(off, raw); err != nil {
+		return nil, fmt.Errorf("cro: anonymous import table: %v", err)
+	}
+	var list []AnonymousImport
+	for _, e := range raw {
+		relocs, err := m.batch(e.RelocOffset)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, AnonymousImport{e.Tag, relocs})
+	}
+	return list, nil
+}
+
+// Lookup finds a named export using the export tree.
+func (m *Module) Lookup(name string) (SegmentTag, bool) {
+	tree := m.ExportTree
+	if len(tree) == 0 {
+		for _, e := range m.Exports {
+			if e.Name == name {
+				return e.Tag, true
+			}
+		}
+		return 0, false
+	}
+	next := tree[0].Left
+	for {
+		i := int(next & treeIndex)
+		if i >= len(tree) {
+			return 0, false
+		}
+		node := tree[i]
+		if next&treeEnd != 0 {
+			j := int(node.ExportIndex)
+			if j < len(m.Exports) && m.Exports[j].Name == name {
+				return m.Exports[j].Tag, true
+			}
+			return 0, false
+		}
+		byteIndex := int(node.TestBit >> 3)
+		bit := uint(node.TestBit & 7)
+		if byteIndex < len(name) && name[byteIndex]>>bit&1 != 0 {
+			next = node.Right
+		} else {
+			next = node.Left
+		}
+	}
+}
+
+// Contents returns the bytes of segment i in the file.
+// The BSS segment has no contents.
+func (m *Module) Contents(i int) []byte {
+	if i < 0 || i >= len(m.Segments) {
+		return nil
+	}
+	s := m.Segments[i]
+	if s.Type == BSS || int64(s.Offset)+int64(s.Size) > int64(len(m.Data)) {
+		return nil
+	}
+	return m.Data[s.Offset : s.Offset+s.Size]
+}
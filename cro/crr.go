@@ -0,0 +1,217 @@
+package cro
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+// A CRO's hash table holds the SHA-256 hashes of four regions:
+// the header after the hash table, the code segment,
+// the module name and tables between the code and data segments,
+// and the data segment.
+const hashCount = 4
+
+// Hash is a SHA-256 hash.
+type Hash [sha256.Size]byte
+
+func (h Hash) String() string { return fmt.Sprintf("%x", h[:]) }
+
+// Region returns the bounds of the file covered by hash i.
+func (m *Module) Region(i int) (start, end uint32) {
+	h := &m.Header
+	switch i {
+	case 0:
+		return 0x80, h.CodeOffset
+	case 1:
+		return h.CodeOffset, h.CodeOffset + h.CodeSize
+	case 2:
+		return h.ModuleNameOffset, h.DataOffset
+	case 3:
+		return h.DataOffset, h.DataOffset + h.DataSize
+	}
+	panic("cro: bad hash region")
+}
+
+// Hashes computes the hashes that belong in the module's hash table.
+func (m *Module) Hashes() ([hashCount]Hash, error) {
+	var hs [hashCount]Hash
+	for i := range hs {
+		start, end := m.Region(i)
+		if start > end || int64(end) > int64(len(m.Data)) {
+			return hs, fmt.Errorf("cro: hash region %d (%#x-%#x) out of range", i, start, end)
+		}
+		hs[i] = sha256.Sum256(m.Data[start:end])
+	}
+	return hs, nil
+}
+
+// VerifyHashes checks the module's hash table against its contents.
+func (m *Module) VerifyHashes() error {
+	hs, err := m.Hashes()
+	if err != nil {
+		return err
+	}
+	for i, h := range hs {
+		if !bytes.Equal(h[:], m.Header.HashTable[i*sha256.Size:][:sha256.Size]) {
+			start, end := m.Region(i)
+			return fmt.Errorf("cro: hash mismatch for region %d (%#x-%#x)", i, start, end)
+		}
+	}
+	return nil
+}
+
+// FixHashes recomputes the module's hash table
+// and writes it to both the header and the file data.
+func (m *Module) FixHashes() error {
+	hs, err := m.Hashes()
+	if err != nil {
+		return err
+	}
+	for i, h := range hs {
+		copy(m.Header.HashTable[i*sha256.Size:], h[:])
+	}
+	copy(m.Data, m.Header.HashTable[:])
+	return nil
+}
+
+// ModuleHash returns the hash of the module's hash table,
+// which is what a CRR lists.
+func (m *Module) ModuleHash() Hash {
+	return sha256.Sum256(m.Data[:len(m.Header.HashTable)])
+}
+
+var CRRMagic = [4]byte{'C', 'R', 'R', '0'}
+
+var ErrCRRHeader = errors.New("cro: invalid CRR header")
+
+// CRRHeader is the header of a CRR file, such as static.crr,
+// which lists the modules the loader is allowed to load.
+type CRRHeader struct {
+	Magic           [4]byte
+	_               uint32
+	NextCRR         uint32 // set by the loader
+	PrevCRR         uint32 // set by the loader
+	DebugInfoOffset int32
+	DebugInfoSize   int32
+	_               [8]uint8
+	UniqueIDMask    uint32
+	UniqueIDPattern uint32
+	_               [0x18]uint8
+
+	BodySignModulus    [0x100]uint8
+	BodySignModulusSig [0x100]uint8
+	HeaderSig          [0x100]uint8
+
+	UniqueID    uint32
+	Size        uint32
+	_           [8]uint8
+	HashOffset  uint32
+	HashCount   uint32
+	PlainOffset uint32
+	PlainSize   uint32
+}
+
+// A CRR is a parsed CRR file.
+//
+// The CRR body is signed with RSA, and the signature
+// cannot be regenerated; the loader only accepts a modified CRR
+// when signature checks have been patched out.
+type CRR struct {
+	Header CRRHeader
+	Hashes []Hash // sorted
+
+	// Data is the contents of the file.
+	Data []byte
+}
+
+// ReadCRR reads a CRR file from r.
+func ReadCRR(r io.Reader) (*CRR, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCRR(b)
+}
+
+// ParseCRR parses a CRR file.
+// The CRR keeps a reference to b.
+func ParseCRR(b []byte) (*CRR, error) {
+	c := &CRR{Data: b}
+	err := binary.Read(bytes.NewReader(b), le, &c.Header)
+	if err != nil {
+		return nil, ErrCRRHeader
+	}
+	h := &c.Header
+	if h.Magic != CRRMagic {
+		return nil, ErrCRRHeader
+	}
+	if int64(h.HashOffset)+int64(h.HashCount)*sha256.Size > int64(len(b)) {
+		return nil, fmt.Errorf("cro: CRR hash table out of range")
+	}
+	c.Hashes = make([]Hash, h.HashCount)
+	for i := range c.Hashes {
+		copy(c.Hashes[i][:], b[h.HashOffset+uint32(i)*sha256.Size:])
+	}
+	return c, nil
+}
+
+func (c *CRR) search(h Hash) int {
+	return sort.Search(len(c.Hashes), func(i int) bool {
+		return bytes.Compare(c.Hashes[i][:], h[:]) >= 0
+	})
+}
+
+// Contains reports whether the CRR lists the module hash h.
+// The loader looks hashes up by binary search,
+// so this fails for an unsorted table just as the loader would.
+func (c *CRR) Contains(h Hash) bool {
+	i := c.search(h)
+	return i < len(c.Hashes) && c.Hashes[i] == h
+}
+
+// Verify checks that the CRR lists the module.
+func (c *CRR) Verify(m *Module) error {
+	if !c.Contains(m.ModuleHash()) {
+		return fmt.Errorf("cro: module %s is not listed in the CRR", m.Name)
+	}
+	return nil
+}
+
+// Replace replaces the module hash old with new,
+// keeping the table sorted, and updates the file data.
+func (c *CRR) Replace(old, new Hash) error {
+	i := c.search(old)
+	if i >= len(c.Hashes) || c.Hashes[i] != old {
+		return fmt.Errorf("cro: hash %v is not listed in the CRR", old)
+	}
+	if old == new {
+		return nil
+	}
+	c.Hashes[i] = new
+	sort.Slice(c.Hashes, func(i, j int) bool {
+		return bytes.Compare(c.Hashes[i][:], c.Hashes[j][:]) < 0
+	})
+	off := c.Header.HashOffset
+	for i, h := range c.Hashes {
+		copy(c.Data[off+uint32(i)*sha256.Size:], h[:])
+	}
+	return nil
+}
+
+// Fix recomputes the hash table of a module that has been modified
+// and updates its entry in the CRR.
+// The CRR entry is found using the module's old hash table,
+// so call Fix before changing m.Header.HashTable.
+func (c *CRR) Fix(m *Module) error {
+	old := m.ModuleHash()
+	if err := m.FixHashes(); err != nil {
+		return err
+	}
+	return c.Replace(old, m.ModuleHash())
+}
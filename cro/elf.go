@@ -0,0 +1,274 @@
+package cro
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// WriteELF writes the module to w as an ARM ELF32 relocatable object.
+//
+// Each segment becomes a section.
+// Named exports are global symbols in their segment's section,
+// and imports are undefined symbols.
+// Indexed and anonymous imports are given names of the form
+// module#index and module@segment:offset.
+// Relocations against imports and internal relocations
+// are written as RELA sections,
+// so the object can be linked or disassembled at any address.
+func (m *Module) WriteELF(w io.Writer) error {
+	e := &elfWriter{
+		shstrtab: []byte{0},
+		strtab:   []byte{0},
+		sections: []elf.Section32{{}},
+		data:     [][]byte{nil},
+		syms:     []elf.Sym32{{}},
+	}
+
+	// One section per segment.
+	secIndex := make([]int, len(m.Segments))
+	seen := make(map[string]bool)
+	for i, s := range m.Segments {
+		name := s.Type.String()
+		if seen[name] {
+			name = fmt.Sprintf("%s.%d", name, i)
+		}
+		seen[name] = true
+		sh := elf.Section32{
+			Type:      uint32(elf.SHT_PROGBITS),
+			Flags:     uint32(elf.SHF_ALLOC),
+			Size:      s.Size,
+			Addralign: 4,
+		}
+		switch s.Type {
+		case Text:
+			sh.Flags |= uint32(elf.SHF_EXECINSTR)
+		case Data:
+			sh.Flags |= uint32(elf.SHF_WRITE)
+		case BSS:
+			sh.Flags |= uint32(elf.SHF_WRITE)
+			sh.Type = uint32(elf.SHT_NOBITS)
+		}
+		secIndex[i] = e.section(name, sh, m.Contents(i))
+	}
+
+	// Section symbols, for internal relocations.
+	secSym := make([]int, len(m.Segments))
+	for i := range m.Segments {
+		secSym[i] = e.symbol("", elf.Sym32{
+			Info:  elf.ST_INFO(elf.STB_LOCAL, elf.STT_SECTION),
+			Shndx: uint16(secIndex[i]),
+		})
+	}
+	firstGlobal := len(e.syms)
+
+	for _, x := range m.Exports {
+		seg := x.Tag.Segment()
+		if seg >= len(m.Segments) {
+			return fmt.Errorf("cro: export %s: segment %d out of range", x.Name, seg)
+		}
+		typ := elf.STT_OBJECT
+		if m.Segments[seg].Type == Text {
+			typ = elf.STT_FUNC
+		}
+		e.symbol(x.Name, elf.Sym32{
+			Value: x.Tag.Offset(),
+			Info:  elf.ST_INFO(elf.STB_GLOBAL, typ),
+			Shndx: uint16(secIndex[seg]),
+		})
+	}
+
+	// Imports and their relocations, grouped by the section they patch.
+	relocs := make([][]elf.Rela32, len(m.Segments))
+	add := func(rs []Relocation, sym int) error {
+		for _, r := range rs {
+			seg := r.Target.Segment()
+			if seg >= len(m.Segments) {
+				return fmt.Errorf("cro: relocation at %v: segment %d out of range", r.Target, seg)
+			}
+			relocs[seg] = append(relocs[seg], elf.Rela32{
+				Off:    r.Target.Offset(),
+				Info:   elf.R_INFO32(uint32(sym), uint32(r.Type)),
+				Addend: int32(r.Addend),
+			})
+		}
+		return nil
+	}
+	undef := func(name string) int {
+		return e.symbol(name, elf.Sym32{
+			Info: elf.ST_INFO(elf.STB_GLOBAL, elf.STT_NOTYPE),
+		})
+	}
+	for _, imp := range m.Imports {
+		if err := add(imp.Relocs, undef(imp.Name)); err != nil {
+			return err
+		}
+	}
+	for _, mod := range m.Modules {
+		for _, imp := range mod.Indexed {
+			name := fmt.Sprintf("%s#%d", mod.Name, imp.Index)
+			if err := add(imp.Relocs, undef(name)); err != nil {
+				return err
+			}
+		}
+		for _, imp := range mod.Anonymous {
+			name := fmt.Sprintf("%s@%v", mod.Name, imp.Tag)
+			if err := add(imp.Relocs, undef(name)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, r := range m.InternalRelocations {
+		if int(r.Segment) >= len(m.Segments) {
+			return fmt.Errorf("cro: relocation at %v: segment %d out of range", r.Target, r.Segment)
+		}
+		if err := add([]Relocation{r}, secSym[r.Segment]); err != nil {
+			return err
+		}
+	}
+
+	// The symbol table must come before the relocation sections refer to it,
+	// so reserve its index now.
+	symtab := len(e.sections) + countNonEmpty(relocs)
+	for i, rs := range relocs {
+		if len(rs) == 0 {
+			continue
+		}
+		var buf bytes.Buffer
+		binary.Write(&buf, le, rs)
+		name := ".rela" + e.name(secIndex[i])
+		e.section(name, elf.Section32{
+			Type:      uint32(elf.SHT_RELA),
+			Link:      uint32(symtab),
+			Info:      uint32(secIndex[i]),
+			Addralign: 4,
+			Entsize:   uint32(binary.Size(elf.Rela32{})),
+		}, buf.Bytes())
+	}
+
+	var symbuf bytes.Buffer
+	binary.Write(&symbuf, le, e.syms)
+	e.section(".symtab", elf.Section32{
+		Type:      uint32(elf.SHT_SYMTAB),
+		Link:      uint32(symtab + 1),
+		Info:      uint32(firstGlobal),
+		Addralign: 4,
+		Entsize:   uint32(binary.Size(elf.Sym32{})),
+	}, symbuf.Bytes())
+	e.section(".strtab", elf.Section32{
+		Type:      uint32(elf.SHT_STRTAB),
+		Addralign: 1,
+	}, e.strtab)
+	shstrndx := e.section(".shstrtab", elf.Section32{
+		Type:      uint32(elf.SHT_STRTAB),
+		Addralign: 1,
+	}, nil)
+	e.data[shstrndx] = e.shstrtab
+	e.sections[shstrndx].Size = uint32(len(e.shstrtab))
+
+	return e.write(w, shstrndx)
+}
+
+func countNonEmpty(relocs [][]elf.Rela32) int {
+	n := 0
+	for _, rs := range relocs {
+		if len(rs) != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+type elfWriter struct {
+	shstrtab []byte
+	strtab   []byte
+	sections []elf.Section32
+	data     [][]byte
+	syms     []elf.Sym32
+}
+
+// section adds a section and returns its index.
+func (e *elfWriter) section(name string, sh elf.Section32, data []byte) int {
+	sh.Name = uint32(len(e.shstrtab))
+	e.shstrtab = append(append(e.shstrtab, name...), 0)
+	if sh.Type != uint32(elf.SHT_NOBITS) {
+		sh.Size = uint32(len(data))
+	}
+	e.sections = append(e.sections, sh)
+	e.data = append(e.data, data)
+	return len(e.sections) - 1
+}
+
+// name returns the name of section i.
+func (e *elfWriter) name(i int) string {
+	s := e.shstrtab[e.sections[i].Name:]
+	return string(s[:bytes.IndexByte(s, 0)])
+}
+
+// symbol adds a symbol and returns its index.
+func (e *elfWriter) symbol(name string, sym elf.Sym32) int {
+	if name != "" {
+		sym.Name = uint32(len(e.strtab))
+		e.strtab = append(append(e.strtab, name...), 0)
+	}
+	e.syms = append(e.syms, sym)
+	return len(e.syms) - 1
+}
+
+func (e *elfWriter) write(w io.Writer, shstrndx int) error {
+	hdrSize := binary.Size(elf.Header32{})
+	shSize := binary.Size(elf.Section32{})
+
+	// Lay out the section contents after the header,
+	// followed by the section header table.
+	off := uint32(hdrSize)
+	for i := range e.sections {
+		sh := &e.sections[i]
+		if i == 0 {
+			continue
+		}
+		if a := sh.Addralign; a > 1 {
+			off = (off + a - 1) &^ (a - 1)
+		}
+		sh.Off = off
+		if sh.Type != uint32(elf.SHT_NOBITS) {
+			off += uint32(len(e.data[i]))
+		}
+	}
+	shoff := (off + 3) &^ 3
+
+	var h elf.Header32
+	copy(h.Ident[:], elf.ELFMAG)
+	h.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS32)
+	h.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	h.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	h.Type = uint16(elf.ET_REL)
+	h.Machine = uint16(elf.EM_ARM)
+	h.Version = uint32(elf.EV_CURRENT)
+	h.Flags = 0x05000000 // EABI version 5
+	h.Ehsize = uint16(hdrSize)
+	h.Shoff = shoff
+	h.Shentsize = uint16(shSize)
+	h.Shnum = uint16(len(e.sections))
+	h.Shstrndx = uint16(shstrndx)
+
+	var buf bytes.Buffer
+	binary.Write(&buf, le, &h)
+	for i, sh := range e.sections {
+		if i == 0 || sh.Type == uint32(elf.SHT_NOBITS) {
+			continue
+		}
+		for uint32(buf.Len()) < sh.Off {
+			buf.WriteByte(0)
+		}
+		buf.Write(e.data[i])
+	}
+	for uint32(buf.Len()) < shoff {
+		buf.WriteByte(0)
+	}
+	binary.Write(&buf, le, e.sections)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
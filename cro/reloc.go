@@ -0,0 +1,172 @@
+package cro
+
+import (
+	"fmt"
+)
+
+// RelocType is the type of a relocation.
+// The values are the same as the corresponding ELF relocation types.
+type RelocType uint8
+
+const (
+	R_ARM_NONE     RelocType = 0
+	R_ARM_ABS32    RelocType = 2
+	R_ARM_REL32    RelocType = 3
+	R_ARM_THM_CALL RelocType = 10
+	R_ARM_CALL     RelocType = 28
+	R_ARM_JUMP24   RelocType = 29
+	R_ARM_TARGET1  RelocType = 38
+	R_ARM_PREL31   RelocType = 42
+)
+
+var relocNames = map[RelocType]string{
+	R_ARM_NONE:     "R_ARM_NONE",
+	R_ARM_ABS32:    "R_ARM_ABS32",
+	R_ARM_REL32:    "R_ARM_REL32",
+	R_ARM_THM_CALL: "R_ARM_THM_CALL",
+	R_ARM_CALL:     "R_ARM_CALL",
+	R_ARM_JUMP24:   "R_ARM_JUMP24",
+	R_ARM_TARGET1:  "R_ARM_TARGET1",
+	R_ARM_PREL31:   "R_ARM_PREL31",
+}
+
+func (t RelocType) String() string {
+	if s, ok := relocNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("RelocType(%d)", uint8(t))
+}
+
+const relocSize = 12
+
+// A Relocation patches a location in the module with the address of a symbol.
+type Relocation struct {
+	Target SegmentTag // location to patch
+	Type   RelocType
+
+	// Segment is the segment of the symbol, for internal relocations.
+	// In the external relocation table, and in the static relocation
+	// table of static.crs, it is instead nonzero
+	// on the last relocation of a batch.
+	Segment uint8
+
+	// Resolved is set by the loader on the first relocation of a batch
+	// once the batch has been applied. Only used by external relocations.
+	Resolved uint8
+
+	_      uint8
+	Addend uint32
+}
+
+// IsLast reports whether r ends a batch of external relocations
+// or of static relocations in static.crs.
+func (r Relocation) IsLast() bool { return r.Segment != 0 }
+
+// Layout returns the address of each segment when the module is loaded at base,
+// and the size of the loaded image.
+// Segments are at their file offsets, except for the BSS segment,
+// which is placed after the end of the file.
+func (m *Module) Layout(base uint32) (addrs []uint32, size int) {
+	size = len(m.Data)
+	addrs = make([]uint32, len(m.Segments))
+	for i, s := range m.Segments {
+		if s.Type == BSS && s.Offset == 0 {
+			off := (size + 3) &^ 3
+			addrs[i] = base + uint32(off)
+			size = off + int(s.Size)
+			continue
+		}
+		addrs[i] = base + s.Offset
+	}
+	return addrs, size
+}
+
+// Address returns the address of a segment tag when the module is loaded at base.
+func (m *Module) Address(base uint32, t SegmentTag) (uint32, error) {
+	addrs, _ := m.Layout(base)
+	if t.Segment() >= len(addrs) {
+		return 0, fmt.Errorf("cro: segment %d out of range", t.Segment())
+	}
+	return addrs[t.Segment()] + t.Offset(), nil
+}
+
+// Relocate returns an image of the module loaded at base,
+// with the internal relocations applied.
+// Imports are left unresolved; use Apply to resolve them.
+func (m *Module) Relocate(base uint32) ([]byte, error) {
+	addrs, size := m.Layout(base)
+	img := make([]byte, size)
+	copy(img, m.Data)
+	for _, r := range m.InternalRelocations {
+		if int(r.Segment) >= len(addrs) {
+			return nil, fmt.Errorf("cro: relocation at %v: segment %d out of range", r.Target, r.Segment)
+		}
+		err := m.Apply(img, base, r, addrs[r.Segment])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return img, nil
+}
+
+// Apply applies a single relocation to an image loaded at base,
+// where sym is the address of the symbol.
+func (m *Module) Apply(img []byte, base uint32, r Relocation, sym uint32) error {
+	p, err := m.Address(base, r.Target)
+	if err != nil {
+		return err
+	}
+	off := int64(p) - int64(base)
+	if off < 0 || off+4 > int64(len(img)) {
+		return fmt.Errorf("cro: relocation target %v out of range", r.Target)
+	}
+	b := img[off:]
+	return relocate(b, r.Type, sym+r.Addend, p)
+}
+
+// relocate patches the word at b, which is at address p,
+// to refer to the address s (the symbol address plus addend).
+func relocate(b []byte, t RelocType, s, p uint32) error {
+	switch t {
+	case R_ARM_NONE:
+	case R_ARM_ABS32, R_ARM_TARGET1:
+		le.PutUint32(b, s)
+	case R_ARM_REL32:
+		le.PutUint32(b, s-p)
+	case R_ARM_PREL31:
+		v := le.Uint32(b)
+		le.PutUint32(b, v&0x80000000|(s-p)&0x7FFFFFFF)
+	case R_ARM_CALL, R_ARM_JUMP24:
+		ins := le.Uint32(b)
+		d := s - p
+		if t == R_ARM_CALL && s&1 != 0 {
+			// Calling Thumb code: BL becomes BLX,
+			// with bit 1 of the offset in the H bit.
+			ins = 0xFA000000 | (d>>1&1)<<24
+		}
+		ins = ins&0xFF000000 | d>>2&0x00FFFFFF
+		le.PutUint32(b, ins)
+	case R_ARM_THM_CALL:
+		lower := uint16(0xD000) // BL
+		if s&1 == 0 {
+			// Calling ARM code: BL becomes BLX,
+			// which is relative to the word-aligned PC.
+			lower = 0xC000
+			p &^= 3
+		}
+		d := s - p
+		sign := d >> 24 & 1
+		j1 := (^(d >> 23) ^ sign) & 1
+		j2 := (^(d >> 22) ^ sign) & 1
+		upper := uint16(0xF000 | sign<<10 | d>>12&0x3FF)
+		lower |= uint16(j1<<13 | j2<<11 | d>>1&0x7FF)
+		if s&1 == 0 {
+			lower &^= 1
+		}
+		le.PutUint16(b, upper)
+		le.PutUint16(b[2:], lower)
+	default:
+		return fmt.Errorf("cro: unsupported relocation type %v", t)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+// Usage: damage [flags] romfs attacker move defender
+// Print the damage range of a move, using the base stats and move stats
+// read from the romfs and the type chart from code.bin.
+//
+// For example:
+//
+//	damage -code code.bin -nature Adamant -ev 0,252,0,0,0,0 romfs Garchomp Earthquake Heatran
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"xy/battle"
+	"xy/names"
+	"xy/stats"
+	"xy/types"
+	"xy/util"
+)
+
+var (
+	level     = flag.Int("level", 50, "level of both Pokémon")
+	defLevel  = flag.Int("def-level", 0, "level of the defender, if different")
+	nature    = flag.String("nature", "Hardy", "attacker's nature")
+	defNature = flag.String("def-nature", "Hardy", "defender's nature")
+	ev        = flag.String("ev", "0,0,0,0,0,0", "attacker's EVs: HP,Atk,Def,Spe,SpA,SpD")
+	defEV     = flag.String("def-ev", "0,0,0,0,0,0", "defender's EVs")
+	iv        = flag.Int("iv", 31, "IV for all stats")
+	stage     = flag.Int("stage", 0, "attacker's attacking stat stage")
+	defStage  = flag.Int("def-stage", 0, "defender's defending stat stage")
+	burn      = flag.Bool("burn", false, "attacker is burned")
+	crit      = flag.Bool("crit", false, "critical hit")
+	spread    = flag.Bool("spread", false, "move hits more than one target")
+	weather   = flag.String("weather", "", "weather: sun, rain, sand, or hail")
+	code      = flag.String("code", "", "read the type chart from `code.bin[:offset]`")
+	oras      = flag.Bool("oras", false, "romfs is from Omega Ruby or Alpha Sapphire")
+)
+
+func die(v ...interface{}) {
+	fmt.Fprintln(os.Stderr, v...)
+	os.Exit(1)
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 4 {
+		die("usage: damage [flags] romfs attacker move defender")
+	}
+	romfs, err := util.OpenFS(flag.Arg(0))
+	if err != nil {
+		die(err)
+	}
+	pokemonPath, movePath := "a/2/1/8", "a/2/1/2"
+	if *oras {
+		pokemonPath, movePath = "a/1/9/5", "a/1/8/9"
+	}
+
+	var chart *types.Chart
+	if *code != "" {
+		chart, err = readChart(*code)
+		if err != nil {
+			die(err)
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, "warning: no type chart; ignoring type effectiveness")
+	}
+
+	att, err := newPokemon(romfs, pokemonPath, flag.Arg(1), *level, *nature, *ev)
+	if err != nil {
+		die(err)
+	}
+	dl := *level
+	if *defLevel != 0 {
+		dl = *defLevel
+	}
+	def, err := newPokemon(romfs, pokemonPath, flag.Arg(3), dl, *defNature, *defEV)
+	if err != nil {
+		die(err)
+	}
+	att.Burned = *burn
+
+	moveID, ok := names.MoveID(flag.Arg(2))
+	if !ok {
+		die("unknown move:", flag.Arg(2))
+	}
+	move, err := readMove(romfs, movePath, moveID)
+	if err != nil {
+		die(err)
+	}
+
+	opt := &battle.Options{Crit: *crit, Spread: *spread}
+	switch *weather {
+	case "":
+	case "sun":
+		opt.Weather = battle.Sun
+	case "rain":
+		opt.Weather = battle.Rain
+	case "sand":
+		opt.Weather = battle.Sandstorm
+	case "hail":
+		opt.Weather = battle.Hail
+	default:
+		die("unknown weather:", *weather)
+	}
+
+	atkStat, defStat := battle.Attack, battle.Defense
+	if move.DamageClassCode == battle.Special {
+		atkStat, defStat = battle.SpecialAttack, battle.SpecialDefense
+	}
+	att.Stages[atkStat] = *stage
+	def.Stages[defStat] = *defStage
+
+	r := battle.Damage(&att.Pokemon, &def.Pokemon, move, chart, opt)
+	hp := def.Stat(battle.HP)
+	fmt.Printf("%s %v\n", names.Move(moveID), att.Stats())
+	fmt.Printf("vs %s %v\n", names.Species(def.Index), def.Stats())
+	fmt.Println(r)
+	fmt.Printf("%d-%d (%.1f%%-%.1f%%)\n", r.Min(), r.Max(),
+		100*float64(r.Min())/float64(hp), 100*float64(r.Max())/float64(hp))
+}
+
+type pokemon struct {
+	Index int
+	battle.Pokemon
+}
+
+func newPokemon(romfs fs.FS, path, name string, level int, natureName, evs string) (*pokemon, error) {
+	id, ok := names.SpeciesID(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown Pokémon: %s", name)
+	}
+	p := &pokemon{Index: id}
+	p.Base = new(stats.PokemonStats)
+	if err := readRecord(romfs, path, id, p.Base); err != nil {
+		return nil, err
+	}
+	p.Level = level
+	p.Nature, ok = battle.NatureByName(natureName)
+	if !ok {
+		return nil, fmt.Errorf("unknown nature: %s", natureName)
+	}
+	list := strings.Split(evs, ",")
+	if len(list) != 6 {
+		return nil, fmt.Errorf("need 6 EVs: %s", evs)
+	}
+	for i, s := range list {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		p.EV[i] = n
+		p.IV[i] = *iv
+	}
+	return p, nil
+}
+
+func readRecord(romfs fs.FS, path string, i int, v interface{}) error {
+	g, err := util.OpenGARCFS(romfs, path)
+	if err != nil {
+		return err
+	}
+	defer g.Close()
+	if i < 0 || i >= len(g.Files) {
+		return fmt.Errorf("%s: no record %d", path, i)
+	}
+	return binary.Read(g.Files[i], binary.LittleEndian, v)
+}
+
+func readMove(romfs fs.FS, path string, i int) (*stats.MoveStats, error) {
+	g, err := util.OpenGARCFS(romfs, path)
+	if err != nil {
+		return nil, err
+	}
+	defer g.Close()
+	moves, err := stats.ReadMoveFiles(g.Files)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if i < 0 || i >= len(moves) {
+		return nil, fmt.Errorf("%s: no move %d", path, i)
+	}
+	return &moves[i], nil
+}
+
+func readChart(arg string) (*types.Chart, error) {
+	filename, off := splitOffset(arg)
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if off < 0 {
+		off, err = types.Find(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return types.Read(b, off)
+}
+
+// splitOffset splits "file:offset" into the file name and the offset,
+// which is -1 if there is none. The suffix is only taken as an offset
+// if it is a number, so that Windows paths such as C:\code.bin work.
+func splitOffset(arg string) (string, int) {
+	if i := strings.LastIndex(arg, ":"); i >= 0 {
+		if n, err := strconv.ParseInt(arg[i+1:], 0, 64); err == nil && n >= 0 {
+			return arg[:i], int(n)
+		}
+	}
+	return arg, -1
+}
@@ -0,0 +1,638 @@
+// Usage: diff [-games xy,oras] [-lang en] [-only files,tables,text] [-format text] romfsA romfsB
+// Compare two romfs dumps, such as X and Y, XY and ORAS,
+// or a game and a patched copy of it.
+//
+// Changes are reported at three levels:
+// files, comparing the files in each GARC (or other files whole) by content and size;
+// tables, comparing the decoded base stats, moves, items, learnsets, trainers,
+// and encounters field by field; and text, comparing the lines of
+// the game text in every language.
+// Encounters are only compared between X and Y dumps.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"xy/export"
+	"xy/garc"
+	"xy/lz"
+	"xy/names"
+	"xy/stats"
+	"xy/text"
+	"xy/util"
+	"xy/zone"
+)
+
+var (
+	games  = flag.String("games", "xy", "the games of the dumps: `xy` or oras, or xy,oras to compare different games")
+	lang   = flag.String("lang", "en", "name things in the tables with the game text in `language`")
+	only   = flag.String("only", "files,tables,text", "compare only at the comma-separated `levels`")
+	format = flag.String("format", "text", "output `format`: text, json, csv, or yaml")
+)
+
+func die(v ...interface{}) {
+	fmt.Fprintln(os.Stderr, v...)
+	os.Exit(1)
+}
+
+var versions = map[string]names.Version{
+	"xy":   names.XY,
+	"oras": names.ORAS,
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() < 2 {
+		die("usage: diff [-games xy,oras] [-lang en] [-only files,tables,text] [-format text|json|csv|yaml] romfsA romfsB")
+	}
+	if *format != "text" && !export.IsFormat(*format) {
+		die("unknown format:", *format)
+	}
+	g := strings.Split(*games, ",")
+	if len(g) == 1 {
+		g = append(g, g[0])
+	}
+	if len(g) != 2 {
+		die("-games: want one or two games")
+	}
+
+	var d differ
+	for i, s := range []*side{&d.a, &d.b} {
+		v, ok := versions[g[i]]
+		if !ok {
+			die("unknown game:", g[i])
+		}
+		fsys, err := util.OpenFS(flag.Arg(i))
+		if err != nil {
+			die(err)
+		}
+		s.fs, s.v = fsys, v
+		s.cat, err = names.Load(fsys, v, *lang)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	for _, level := range strings.Split(*only, ",") {
+		var err error
+		switch level {
+		case "files":
+			err = d.files()
+		case "tables":
+			err = d.tables()
+		case "text":
+			err = d.text()
+		default:
+			err = fmt.Errorf("unknown level: %s", level)
+		}
+		if err != nil {
+			die(err)
+		}
+	}
+	if *format != "text" {
+		if err := export.Write(os.Stdout, *format, d.table); err != nil {
+			die(err)
+		}
+	}
+}
+
+// A side is one of the romfs dumps being compared.
+type side struct {
+	fs  fs.FS
+	v   names.Version
+	cat *names.Catalog
+}
+
+type differ struct {
+	a, b  side
+	table []export.Record
+}
+
+// A difference is one line of the report.
+type difference struct {
+	Level  string // files, tables, or text
+	Path   string // romfs path, table name, or language and archive
+	Index  int    // file in a GARC, table index, or text file; -1 if none
+	Line   int    // line of text; -1 if none
+	Name   string // name of the table entry
+	Status string // added, removed, or changed
+	Field  string
+	Old    interface{}
+	New    interface{}
+}
+
+// report prints d, or adds it to the table to be exported.
+func (df *differ) report(d difference) {
+	if *format != "text" {
+		var r export.Record
+		r.Add("level", d.Level)
+		r.Add("path", d.Path)
+		r.Add("index", optional(d.Index))
+		r.Add("line", optional(d.Line))
+		r.Add("name", d.Name)
+		r.Add("status", d.Status)
+		r.Add("field", d.Field)
+		r.Add("old", d.Old)
+		r.Add("new", d.New)
+		df.table = append(df.table, r)
+		return
+	}
+	var b strings.Builder
+	b.WriteString(d.Path)
+	if d.Index >= 0 {
+		fmt.Fprintf(&b, " %d", d.Index)
+	}
+	if d.Line >= 0 {
+		fmt.Fprintf(&b, ".%d", d.Line)
+	}
+	if d.Name != "" {
+		fmt.Fprintf(&b, " (%s)", d.Name)
+	}
+	b.WriteString(": ")
+	if d.Field != "" {
+		b.WriteString(d.Field + ": ")
+	}
+	switch d.Status {
+	case "changed":
+		fmt.Fprintf(&b, "%s -> %s", show(d.Old), show(d.New))
+	case "added":
+		fmt.Fprintf(&b, "added %s", show(d.New))
+	case "removed":
+		fmt.Fprintf(&b, "removed %s", show(d.Old))
+	}
+	fmt.Println(strings.TrimSpace(b.String()))
+}
+
+func optional(n int) interface{} {
+	if n < 0 {
+		return nil
+	}
+	return n
+}
+
+// show formats a value for the text report.
+func show(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case size:
+		return fmt.Sprintf("(%d bytes)", v)
+	case string:
+		return fmt.Sprintf("%q", v)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// size is the size of a file, for the report.
+type size int
+
+// files compares the files of the two dumps.
+func (df *differ) files() error {
+	pa, err := walk(df.a.fs)
+	if err != nil {
+		return err
+	}
+	pb, err := walk(df.b.fs)
+	if err != nil {
+		return err
+	}
+	for _, name := range union(pa, pb) {
+		d := difference{Level: "files", Path: name, Index: -1, Line: -1}
+		if !pa[name] {
+			b, err := fs.ReadFile(df.b.fs, name)
+			if err != nil {
+				return err
+			}
+			d.Status, d.New = "added", size(len(b))
+			df.report(d)
+			continue
+		}
+		a, err := fs.ReadFile(df.a.fs, name)
+		if err != nil {
+			return err
+		}
+		if !pb[name] {
+			d.Status, d.Old = "removed", size(len(a))
+			df.report(d)
+			continue
+		}
+		b, err := fs.ReadFile(df.b.fs, name)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(a, b) {
+			continue
+		}
+		if df.members(name, a, b) {
+			continue
+		}
+		d.Status, d.Old, d.New = "changed", size(len(a)), size(len(b))
+		df.report(d)
+	}
+	return nil
+}
+
+// members compares the files in two versions of a GARC.
+// It returns false if they aren't both GARCs
+// or no file in them differs.
+func (df *differ) members(name string, a, b []byte) bool {
+	fa, err := garc.Files(bytes.NewReader(a))
+	if err != nil {
+		return false
+	}
+	fb, err := garc.Files(bytes.NewReader(b))
+	if err != nil {
+		return false
+	}
+	ea, err := garc.Entries(fa)
+	if err != nil {
+		return false
+	}
+	eb, err := garc.Entries(fb)
+	if err != nil {
+		return false
+	}
+	found := false
+	for i := 0; i < len(ea) || i < len(eb); i++ {
+		d := difference{Level: "files", Path: name, Index: i, Line: -1}
+		switch {
+		case i >= len(ea):
+			d.Status, d.New = "added", size(len(eb[i].Data))
+		case i >= len(eb):
+			d.Status, d.Old = "removed", size(len(ea[i].Data))
+		case !bytes.Equal(ea[i].Data, eb[i].Data):
+			d.Status, d.Old, d.New = "changed", size(len(ea[i].Data)), size(len(eb[i].Data))
+		default:
+			continue
+		}
+		df.report(d)
+		found = true
+	}
+	return found
+}
+
+// walk returns the names of the regular files in fsys.
+func walk(fsys fs.FS) (map[string]bool, error) {
+	m := make(map[string]bool)
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			m[name] = true
+		}
+		return nil
+	})
+	return m, err
+}
+
+func union(a, b map[string]bool) []string {
+	var list []string
+	for name := range a {
+		list = append(list, name)
+	}
+	for name := range b {
+		if !a[name] {
+			list = append(list, name)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// A loader reads one of the data tables for comparison.
+// Entries which are missing or empty are nil.
+type loader func(s *side, files stats.Files) ([]export.Record, error)
+
+var tables = []struct {
+	name string
+	load loader
+}{
+	{"personal", loadPersonal},
+	{"moves", loadMoves},
+	{"items", loadItems},
+	{"learnsets", loadLearnsets},
+	{"trainers", loadTrainers},
+	{"encounters", loadEncounters},
+}
+
+// tables compares the decoded data tables.
+func (df *differ) tables() error {
+	for _, t := range tables {
+		// A table missing from a dump has no entries.
+		// A table one of the games can't be read from is skipped.
+		ta, errA := t.load(&df.a, stats.GameFiles[df.a.v])
+		tb, errB := t.load(&df.b, stats.GameFiles[df.b.v])
+		if errors.Is(errA, zone.ErrEncounterLayout) || errors.Is(errB, zone.ErrEncounterLayout) {
+			log.Printf("skipping %s: %v", t.name, zone.ErrEncounterLayout)
+			continue
+		}
+		for _, err := range []error{errA, errB} {
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("%s: %v", t.name, err)
+			}
+		}
+		for i := 0; i < len(ta) || i < len(tb); i++ {
+			var ra, rb export.Record
+			if i < len(ta) {
+				ra = ta[i]
+			}
+			if i < len(tb) {
+				rb = tb[i]
+			}
+			d := difference{Level: "tables", Path: t.name, Index: i, Line: -1}
+			switch {
+			case ra == nil && rb == nil:
+				continue
+			case ra == nil:
+				d.Name, _ = rb.Get("name").(string)
+				d.Status, d.New = "added", rb
+				df.report(d)
+				continue
+			case rb == nil:
+				d.Name, _ = ra.Get("name").(string)
+				d.Status, d.Old = "removed", ra
+				df.report(d)
+				continue
+			}
+			d.Name, _ = rb.Get("name").(string)
+			for _, c := range export.Compare(ra, rb) {
+				d.Field, d.Old, d.New = c.Field, c.Old, c.New
+				switch {
+				case c.Old == nil:
+					d.Status = "added"
+				case c.New == nil:
+					d.Status = "removed"
+				default:
+					d.Status = "changed"
+				}
+				df.report(d)
+			}
+		}
+	}
+	return nil
+}
+
+func loadPersonal(s *side, files stats.Files) ([]export.Record, error) {
+	g, err := util.OpenGARCFS(s.fs, files.Personal)
+	if err != nil {
+		return nil, err
+	}
+	defer g.Close()
+	// The last file is every Pokémon's stats in one.
+	if len(g.Files) == 0 {
+		return nil, nil
+	}
+	list := make([]stats.PokemonStats, len(g.Files)-1)
+	for i := range list {
+		if err := binary.Read(g.Files[i], binary.LittleEndian, &list[i]); err != nil {
+			return nil, fmt.Errorf("file %d: %v", i, err)
+		}
+	}
+	// Alternate forms come after the species and are named for them.
+	species := make([]int, len(list))
+	for i := range list {
+		if species[i] == 0 {
+			species[i] = i
+		}
+		p := &list[i]
+		for j := 1; j < int(p.FormCount); j++ {
+			if k := int(p.FormStats) + j - 1; p.FormStats != 0 && k < len(list) {
+				species[k] = i
+			}
+		}
+	}
+	table := make([]export.Record, len(list))
+	for i := range list {
+		var r export.Record
+		r.Add("name", s.cat.Species(species[i]))
+		table[i] = append(r, list[i].Record(s.cat)...)
+	}
+	return table, nil
+}
+
+func loadMoves(s *side, files stats.Files) ([]export.Record, error) {
+	g, err := util.OpenGARCFS(s.fs, files.Moves)
+	if err != nil {
+		return nil, err
+	}
+	defer g.Close()
+	var moves []stats.MoveStats
+	if len(g.Files) == 1 {
+		// All the moves are packed into one file.
+		moves, err = stats.ReadMoves(g.Files[0])
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		moves = make([]stats.MoveStats, len(g.Files))
+		for i, f := range g.Files {
+			if err := binary.Read(f, binary.LittleEndian, &moves[i]); err != nil {
+				return nil, fmt.Errorf("file %d: %v", i, err)
+			}
+		}
+	}
+	table := make([]export.Record, len(moves))
+	for i := range moves {
+		var r export.Record
+		r.Add("name", s.cat.Move(i))
+		table[i] = append(r, moves[i].Record(s.cat)...)
+	}
+	return table, nil
+}
+
+func loadItems(s *side, files stats.Files) ([]export.Record, error) {
+	g, err := util.OpenGARCFS(s.fs, files.Items)
+	if err != nil {
+		return nil, err
+	}
+	defer g.Close()
+	table := make([]export.Record, len(g.Files))
+	for i, f := range g.Files {
+		var item stats.ItemStats
+		if err := binary.Read(f, binary.LittleEndian, &item); err != nil {
+			return nil, fmt.Errorf("file %d: %v", i, err)
+		}
+		var r export.Record
+		r.Add("name", s.cat.Item(i))
+		table[i] = append(r, item.Record(s.cat)...)
+	}
+	return table, nil
+}
+
+func loadLearnsets(s *side, files stats.Files) ([]export.Record, error) {
+	g, err := util.OpenGARCFS(s.fs, files.Learnsets)
+	if err != nil {
+		return nil, err
+	}
+	defer g.Close()
+	table := make([]export.Record, len(g.Files))
+	for i, f := range g.Files {
+		l, err := stats.ReadLearnset(f)
+		if err != nil {
+			return nil, fmt.Errorf("file %d: %v", i, err)
+		}
+		var r export.Record
+		r.Add("name", s.cat.Species(i))
+		table[i] = append(r, l.Record(s.cat)...)
+	}
+	return table, nil
+}
+
+func loadTrainers(s *side, files stats.Files) ([]export.Record, error) {
+	trdata, err := util.OpenGARCFS(s.fs, files.Trainers)
+	if err != nil {
+		return nil, err
+	}
+	defer trdata.Close()
+	trpoke, err := util.OpenGARCFS(s.fs, files.TrainerPokemon)
+	if err != nil {
+		return nil, err
+	}
+	defer trpoke.Close()
+	table := make([]export.Record, len(trdata.Files))
+	for i, f := range trdata.Files {
+		// The first trainer is empty.
+		if f.Size() == 0 || i >= len(trpoke.Files) {
+			continue
+		}
+		var t stats.Team
+		t.Trainer, err = stats.ReadTrainer(f)
+		if err != nil {
+			return nil, fmt.Errorf("trainer %d: %v", i, err)
+		}
+		t.Pokemon, err = stats.ReadTeam(trpoke.Files[i], &t.Trainer)
+		if err != nil {
+			return nil, fmt.Errorf("trainer %d: %v", i, err)
+		}
+		var r export.Record
+		r.Add("name", s.cat.TrainerName(i))
+		table[i] = append(r, t.Record(s.cat)...)
+	}
+	return table, nil
+}
+
+func loadEncounters(s *side, files stats.Files) ([]export.Record, error) {
+	if err := zone.CheckEncounters(s.v); err != nil {
+		return nil, err
+	}
+	g, err := util.OpenGARCFS(s.fs, files.Zones)
+	if err != nil {
+		return nil, err
+	}
+	defer g.Close()
+	zones, err := zone.ReadGARC(g.Files)
+	if err != nil {
+		return nil, err
+	}
+	table := make([]export.Record, len(zones))
+	for i := range zones {
+		b, err := lz.Decode(g.Files[i])
+		if err != nil {
+			return nil, fmt.Errorf("zone %d: %v", i, err)
+		}
+		enc, err := zone.ReadEncounter(b)
+		if err != nil {
+			return nil, fmt.Errorf("zone %d: %v", i, err)
+		}
+		if enc == nil {
+			continue
+		}
+		var r export.Record
+		r.Add("name", s.cat.Location(int(zones[i].Location)))
+		table[i] = append(r, enc.Record(s.cat)...)
+	}
+	return table, nil
+}
+
+// text compares the game text in every language.
+func (df *differ) text() error {
+	for _, l := range names.Languages {
+		ca, sa, err := names.TextArchives(df.a.v, l)
+		if err != nil {
+			return err
+		}
+		cb, sb, err := names.TextArchives(df.b.v, l)
+		if err != nil {
+			return err
+		}
+		if err := df.messages(l+" common", ca, cb); err != nil {
+			return err
+		}
+		if err := df.messages(l+" story", sa, sb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// messages compares the lines of two text archives.
+func (df *differ) messages(label, na, nb string) error {
+	ta, err := readText(df.a.fs, na)
+	if err != nil {
+		return err
+	}
+	tb, err := readText(df.b.fs, nb)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(ta) || i < len(tb); i++ {
+		var la, lb []string
+		if i < len(ta) {
+			la = ta[i]
+		}
+		if i < len(tb) {
+			lb = tb[i]
+		}
+		for j := 0; j < len(la) || j < len(lb); j++ {
+			d := difference{Level: "text", Path: label, Index: i, Line: j}
+			switch {
+			case j >= len(la):
+				d.Status, d.New = "added", lb[j]
+			case j >= len(lb):
+				d.Status, d.Old = "removed", la[j]
+			case la[j] != lb[j]:
+				d.Status, d.Old, d.New = "changed", la[j], lb[j]
+			default:
+				continue
+			}
+			df.report(d)
+		}
+	}
+	return nil
+}
+
+// readText reads the lines of every file in a text archive.
+// A missing archive has no lines.
+func readText(fsys fs.FS, name string) ([][]string, error) {
+	g, err := util.OpenGARCFS(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer g.Close()
+	files := make([][]string, len(g.Files))
+	for i, f := range g.Files {
+		files[i], err = text.Read(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: file %d: %v", name, i, err)
+		}
+	}
+	return files, nil
+}
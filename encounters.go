@@ -3,47 +3,23 @@ package main
 import (
 	"bytes"
 	"database/sql"
-	"encoding/binary"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
 
+	"xy/export"
 	"xy/garc"
 	"xy/lz"
 	"xy/names"
-
-	_ "github.com/lib/pq"
+	"xy/veekun"
+	"xy/zone"
 )
 
 const VersionID = 24
 const VersionGroupID = 15
 
-var le = binary.LittleEndian
-
-type Encounter struct {
-	Header [16]byte
-	Grass  [12]Slot
-	Flower [3][12]Slot
-	Rough  [12]Slot
-
-	Water     [5]Slot
-	RockSmash [5]Slot
-
-	Fishing [3][3]Slot
-	Horde   [3][5]Slot
-}
-
-type Slot struct {
-	Pokemon  uint16
-	MinLevel uint8
-	MaxLevel uint8
-}
-
-func (s Slot) Species() int { return int(s.Pokemon & 0x7FF) }
-func (s Slot) Form() int    { return int(s.Pokemon >> 11) }
-
-func (s Slot) PokemonID() int {
+// pokemonID returns the veekun pokemon id of the slot's species and form.
+func pokemonID(s zone.Slot) int {
 	if s.Form() == 0 {
 		return s.Species()
 	}
@@ -70,11 +46,17 @@ func die(v ...interface{}) {
 }
 
 func main() {
-	dburl := flag.String("import", "", "add encounters to `database`")
+	dburl := flag.String("import", "", "add encounters to `database`: a Postgres database name or URL, an SQLite file, or dry-run:database")
+	dumpZones := flag.Bool("zones", false, "print the zone table instead of encounters")
+	showDiff := flag.Bool("diff", false, "with -import, list the encounters added, removed, and changed")
+	format := flag.String("format", "text", "output `format`: text, json, csv, or yaml")
 	flag.Parse()
 
 	if flag.NArg() < 1 {
-		die("usage: encounters [-import database] romfs/a/0/1/2")
+		die("usage: encounters [-import database [-diff]] [-zones] [-format text|json|csv|yaml] romfs/a/0/1/2")
+	}
+	if *format != "text" && !export.IsFormat(*format) {
+		die("unknown format:", *format)
 	}
 
 	f, err := os.Open(flag.Arg(0))
@@ -87,15 +69,33 @@ func main() {
 	if err != nil {
 		die(err)
 	}
-	zonedata, err := ioutil.ReadAll(files[360])
+	zones, err := zone.ReadGARC(files)
 	if err != nil {
 		die(err)
 	}
+	if *dumpZones {
+		if *format != "text" {
+			table := make([]export.Record, len(zones))
+			for i := range zones {
+				table[i] = zoneRecord(i, &zones[i])
+			}
+			if err := export.Write(os.Stdout, *format, table); err != nil {
+				die(err)
+			}
+			return
+		}
+		for i := range zones {
+			printZone(i, &zones[i])
+		}
+		return
+	}
 
-	var db *sql.DB
-	var tx *sql.Tx
+	var db veekun.DB
+	var tx veekun.Tx
+	var records []veekun.Record
+	var table []export.Record
 	if *dburl != "" {
-		db, err = sql.Open("postgres", *dburl)
+		db, err = veekun.Open(*dburl)
 		if err != nil {
 			die(err)
 		}
@@ -106,24 +106,10 @@ func main() {
 			die(err)
 		}
 		defer tx.Rollback()
-
-		/*
-		exec := func(sql string, args ...interface{}) {
-			_, err = tx.Exec(sql, args...)
-			if err != nil {
-				die(fmt.Sprintf("%v: %v", sql, err))
-			}
-		}
-		exec(`DELETE FROM encounters WHERE version_id = $1`, VersionID)
-		exec(`DELETE FROM location_area_prose WHERE location_area_id IN (SELECT la.id FROM location_areas la JOIN locations l ON la.location_id = l.id WHERE l.region_id=6)`)
-		exec(`DELETE FROM location_areas WHERE id IN (SELECT la.id FROM location_areas la JOIN locations l on la.location_id = l.id WHERE l.region_id=6)`)
-		exec(`SELECT setval('location_areas_id_seq', max(id)) FROM location_areas`)
-		exec(`SELECT setval('encounters_id_seq', max(id)) FROM encounters`)
-		*/
 	}
 
 	for i, g := range files {
-		if i == 360 {
+		if i >= len(zones) {
 			continue
 		}
 		b, err := lz.Decode(g)
@@ -131,28 +117,43 @@ func main() {
 			fmt.Fprintf(os.Stderr, "%d: %v\n", i, err)
 			continue
 		}
-		off := int(le.Uint32(b[0x10:]))
-		if off < 0 || off >= len(b) {
-			continue
-		}
-		//fmt.Printf("% x\n", b[off:])
-		var enc Encounter
-		err = binary.Read(bytes.NewReader(b[off:]), le, &enc)
+		enc, err := zone.ReadEncounter(b)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%d: %v\n", i, err)
 			continue
 		}
+		if enc == nil {
+			continue
+		}
 		if tx != nil {
-			err = importEncounter(tx, i, &enc, zonedata[i*56:i*56+56])
+			recs, err := importEncounter(tx, i, enc, &zones[i])
 			if err != nil {
 				tx.Rollback()
 				die(err)
 			}
+			records = append(records, recs...)
+		} else if *format != "text" {
+			table = append(table, encounterRecord(i, enc, &zones[i]))
 		} else {
-			printEncounter(&enc, zonedata[i*56:i*56+56])
+			printEncounter(enc, &zones[i])
+		}
+	}
+	if tx == nil && *format != "text" {
+		if err := export.Write(os.Stdout, *format, table); err != nil {
+			die(err)
 		}
 	}
 	if tx != nil {
+		// Replace all of the version's encounters,
+		// changing only the rows that differ.
+		d, err := veekun.Sync(tx, encounterTable, "version_id = $1", []interface{}{VersionID}, records)
+		if err != nil {
+			tx.Rollback()
+			die(err)
+		}
+		if *showDiff {
+			d.WriteTo(os.Stdout)
+		}
 		err = tx.Commit()
 		if err != nil {
 			die(err)
@@ -160,14 +161,23 @@ func main() {
 	}
 }
 
-func importEncounter(tx *sql.Tx, index int, enc *Encounter, zd []byte) error {
-	loc := int(zd[0x1C])
+var encounterTable = &veekun.Table{
+	Name:    "encounters",
+	Key:     []string{"version_id", "location_area_id", "encounter_slot_id"},
+	Columns: []string{"pokemon_id", "min_level", "max_level"},
+}
+
+// importEncounter returns the encounter rows for a zone,
+// adding its location area if necessary.
+func importEncounter(tx veekun.Tx, index int, enc *zone.Encounter, z *zone.Zone) ([]veekun.Record, error) {
+	loc := int(z.Location)
 	areaID, err := addarea(tx, loc, index)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	do := func(method string, slot []Slot, skip bool) {
+	var records []veekun.Record
+	do := func(method string, slot []zone.Slot, skip bool) {
 		if err != nil {
 			return
 		}
@@ -175,10 +185,15 @@ func importEncounter(tx *sql.Tx, index int, enc *Encounter, zd []byte) error {
 			return
 		}
 		for i, t := range slot {
-			err = addenc(tx, VersionID, areaID, method, i, t)
+			var slotID int
+			slotID, err = encounterSlot(tx, method, i)
 			if err != nil {
 				return
 			}
+			records = append(records, veekun.Record{
+				VersionID, areaID, slotID,
+				pokemonID(t), t.MinLevel, t.MaxLevel,
+			})
 		}
 	}
 
@@ -193,10 +208,10 @@ func importEncounter(tx *sql.Tx, index int, enc *Encounter, zd []byte) error {
 	do("good-rod", enc.Fishing[1][:], enc.Header[8] == 0)
 	do("super-rod", enc.Fishing[2][:], enc.Header[9] == 0)
 
-	return err
+	return records, err
 }
 
-func addarea(tx *sql.Tx, loc, index int) (areaID int, err error) {
+func addarea(tx veekun.Tx, loc, index int) (areaID int, err error) {
 	err = tx.QueryRow(`SELECT id FROM location_areas la JOIN location_game_indices lgi ON la.location_id = lgi.location_id WHERE la.identifier = $1 AND lgi.game_index = $2`,
 		fmt.Sprintf("unknown-area-%d", index), loc).Scan(&areaID)
 	if err == nil {
@@ -221,24 +236,59 @@ func addarea(tx *sql.Tx, loc, index int) (areaID int, err error) {
 	return areaID, nil
 }
 
-func addenc(tx *sql.Tx, versionID, areaID int, method string, index int, slot Slot) error {
-	_, err := tx.Exec(
-		`INSERT INTO encounters
-			(version_id, location_area_id, encounter_slot_id, pokemon_id, min_level, max_level)
-			SELECT $1, $2, es.id, $3, $4, $5
+var slotIDs = make(map[string]int)
+
+// encounterSlot returns the id of an encounter slot.
+func encounterSlot(tx veekun.Tx, method string, index int) (int, error) {
+	k := fmt.Sprint(method, index)
+	if id, ok := slotIDs[k]; ok {
+		return id, nil
+	}
+	var id int
+	err := tx.QueryRow(
+		`SELECT es.id
 			FROM encounter_slots es JOIN encounter_methods em ON es.encounter_method_id = em.id
-			WHERE em.identifier = $6 AND es.version_group_id = $7 AND es.slot = $8`,
-		versionID, areaID, slot.PokemonID(), slot.MinLevel, slot.MaxLevel,
-		method, VersionGroupID, index)
+			WHERE em.identifier = $1 AND es.version_group_id = $2 AND es.slot = $3`,
+		method, VersionGroupID, index).Scan(&id)
 	if err != nil {
-		return fmt.Errorf("During query %v, %v, %v, %v, %v: %v", versionID, areaID, method, index, slot, err)
+		return 0, fmt.Errorf("encounter slot %s %d: %v", method, index, err)
 	}
-	return nil
+	slotIDs[k] = id
+	return id, nil
+}
+
+// encounterRecord returns a zone's encounters for export.
+func encounterRecord(index int, enc *zone.Encounter, z *zone.Zone) export.Record {
+	var r export.Record
+	r.Add("zone", index)
+	r.Add("location", names.Location(int(z.Location)))
+	return append(r, enc.Record(nil)...)
 }
 
-func printEncounter(enc *Encounter, zd []byte) {
+func zoneRecord(index int, z *zone.Zone) export.Record {
+	var r export.Record
+	r.Add("index", index)
+	r.Add("location", names.Location(int(z.Location)))
+	r.Add("map_type", z.MapType)
+	r.Add("map_flags", z.MapFlags)
+	r.Add("map_matrix", z.MapMatrix)
+	r.Add("script", z.ScriptFile)
+	r.Add("text", z.TextFile)
+	r.Add("bgm_day", z.BGMDay)
+	r.Add("bgm_night", z.BGMNight)
+	r.Add("weather", z.Weather)
+	r.Add("battle_background", z.BattleBG)
+	r.Add("can_fly", z.CanFly())
+	r.Add("can_escape", z.CanEscape())
+	r.Add("can_teleport", z.CanTeleport())
+	r.Add("can_bike", z.CanBike())
+	r.Add("fly_destination", z.IsFlyDestination())
+	return r
+}
+
+func printEncounter(enc *zone.Encounter, z *zone.Zone) {
 	var b bytes.Buffer
-	f := func(slots []Slot) string {
+	f := func(slots []zone.Slot) string {
 		b.Truncate(0)
 		for i, t := range slots {
 			if t.Pokemon == 0 {
@@ -259,7 +309,7 @@ func printEncounter(enc *Encounter, zd []byte) {
 		}
 		return b.String()
 	}
-	fmt.Println(names.Location(int(zd[0x1C])))
+	fmt.Println(names.Location(int(z.Location)))
 	fmt.Printf("% x\n", enc.Header)
 	fmt.Println("Grass:", f(enc.Grass[:]))
 	fmt.Println("Yellow flowers:", f(enc.Flower[0][:]))
@@ -276,3 +326,14 @@ func printEncounter(enc *Encounter, zd []byte) {
 	fmt.Println("Horde 3:", f(enc.Horde[2][:]))
 	fmt.Println()
 }
+
+func printZone(index int, z *zone.Zone) {
+	fmt.Printf("%d: %s\n", index, names.Location(int(z.Location)))
+	fmt.Printf("Map: type %d, flags %#x, matrix %d\n", z.MapType, z.MapFlags, z.MapMatrix)
+	fmt.Printf("Script: %d, text: %d\n", z.ScriptFile, z.TextFile)
+	fmt.Printf("BGM: %#x day, %#x night\n", z.BGMDay, z.BGMNight)
+	fmt.Printf("Weather: %d, battle background: %d\n", z.Weather, z.BattleBG)
+	fmt.Printf("Fly: %v, escape: %v, teleport: %v, bike: %v, destination: %v\n",
+		z.CanFly(), z.CanEscape(), z.CanTeleport(), z.CanBike(), z.IsFlyDestination())
+	fmt.Println()
+}
@@ -0,0 +1,88 @@
+// Usage: exefs [-exheader exheader.bin] exefs.bin outdir
+// Extract the files in an ExeFS image.
+// With -exheader, .code is decompressed if necessary
+// and written as code.bin.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"xy/exefs"
+)
+
+var exheaderName = flag.String("exheader", "", "read the code layout from `exheader.bin`")
+
+func main() {
+	flag.Parse()
+	if flag.NArg() < 2 {
+		fmt.Fprintln(os.Stderr, "usage: exefs [-exheader exheader.bin] exefs.bin outdir")
+		os.Exit(1)
+	}
+	if err := extract(flag.Arg(0), flag.Arg(1)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func extract(filename, outdir string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	e, err := exefs.Read(f)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range e.Files {
+		if err := file.Verify(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		fmt.Printf("%-8s %8x\n", file.Name, file.Size())
+		out, err := os.Create(filepath.Join(outdir, file.Name))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, io.NewSectionReader(file, 0, file.Size()))
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if *exheaderName == "" {
+		return nil
+	}
+	hf, err := os.Open(*exheaderName)
+	if err != nil {
+		return err
+	}
+	info, err := exefs.ReadExheader(hf)
+	hf.Close()
+	if err != nil {
+		return err
+	}
+	code, err := exefs.LoadCode(e, info)
+	if err != nil {
+		return err
+	}
+	for _, s := range code.Segments() {
+		fmt.Printf("%08x %5x pages %8x bytes\n", s.Addr, s.Pages, s.Size)
+	}
+	out, err := os.Create(filepath.Join(outdir, "code.bin"))
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(code.Data)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
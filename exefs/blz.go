@@ -0,0 +1,68 @@
+package exefs
+
+import (
+	"errors"
+)
+
+var ErrCorrupt = errors.New("exefs: corrupt compressed data")
+
+// Decompress decompresses code compressed with the backwards LZ77
+// variant used for .code.
+//
+// The data is decompressed from the end towards the start.
+// The last 8 bytes are a footer:
+// a word whose low 24 bits are the length of the compressed region
+// at the end of the data, and whose high byte is the length of the footer
+// and any padding before it;
+// and the number of bytes the data grows by when decompressed.
+// The bytes before the compressed region are stored uncompressed.
+func Decompress(b []byte) ([]byte, error) {
+	if len(b) < 8 {
+		return nil, ErrCorrupt
+	}
+	n := len(b)
+	bottom := le.Uint32(b[n-8:])
+	encLen := int(bottom & 0xFFFFFF)
+	hdrLen := int(bottom >> 24)
+	extra := int(le.Uint32(b[n-4:]))
+	if encLen > n || hdrLen < 8 || hdrLen > encLen {
+		return nil, ErrCorrupt
+	}
+
+	out := make([]byte, n+extra)
+	copy(out, b)
+	src := n - hdrLen
+	dst := len(out)
+	limit := n - encLen
+	for src > limit {
+		src--
+		flags := out[src]
+		for i := 0; i < 8 && src > limit; i++ {
+			if flags&0x80 == 0 {
+				src--
+				dst--
+				if dst < src {
+					return nil, ErrCorrupt
+				}
+				out[dst] = out[src]
+			} else {
+				if src-2 < limit {
+					return nil, ErrCorrupt
+				}
+				src -= 2
+				d := int(out[src]) | int(out[src+1])<<8
+				count := d>>12 + 3
+				disp := d&0xFFF + 3
+				if dst-count < src || dst+disp-1 >= len(out) {
+					return nil, ErrCorrupt
+				}
+				for ; count > 0; count-- {
+					dst--
+					out[dst] = out[dst+disp]
+				}
+			}
+			flags <<= 1
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,140 @@
+package exefs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+const pageSize = 0x1000
+
+// CodeSetInfo is the start of the exheader,
+// which describes how .code is laid out in memory.
+type CodeSetInfo struct {
+	Name            [8]byte
+	_               [5]uint8
+	Flags           uint8
+	RemasterVersion uint16
+	Text            CodeSegment
+	StackSize       uint32
+	ROData          CodeSegment
+	_               uint32
+	Data            CodeSegment
+	BSSSize         uint32
+}
+
+// Flags
+const (
+	FlagCompressed = 1 << 0
+	FlagSDApp      = 1 << 1
+)
+
+type CodeSegment struct {
+	Addr  uint32
+	Pages uint32 // size in memory, in pages
+	Size  uint32 // size in .code, in bytes
+}
+
+// ReadExheader reads the code set info from the start of an exheader.
+func ReadExheader(r io.Reader) (*CodeSetInfo, error) {
+	info := new(CodeSetInfo)
+	err := binary.Read(r, le, info)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Code is the decompressed contents of .code.
+type Code struct {
+	Info CodeSetInfo
+	Data []byte
+}
+
+// LoadCode reads .code from e, decompressing it if the exheader says to.
+func LoadCode(e *ExeFS, info *CodeSetInfo) (*Code, error) {
+	f := e.File(".code")
+	if f == nil {
+		return nil, fmt.Errorf("exefs: no .code")
+	}
+	b, err := ioutil.ReadAll(io.NewSectionReader(f, 0, f.Size()))
+	if err != nil {
+		return nil, err
+	}
+	if info.Flags&FlagCompressed != 0 {
+		b, err = Decompress(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Code{Info: *info, Data: b}, nil
+}
+
+// Segments returns the text, rodata, and data segments.
+func (c *Code) Segments() []CodeSegment {
+	return []CodeSegment{c.Info.Text, c.Info.ROData, c.Info.Data}
+}
+
+// Offset returns the offset in c.Data of the virtual address addr,
+// and the number of bytes from there to the end of its segment.
+// Each segment starts on a page boundary in the decompressed code.
+func (c *Code) Offset(addr uint32) (off, n int, err error) {
+	for _, s := range c.Segments() {
+		if addr >= s.Addr && addr-s.Addr < s.Size {
+			off += int(addr - s.Addr)
+			n = int(s.Size - (addr - s.Addr))
+			if off+n > len(c.Data) {
+				n = len(c.Data) - off
+			}
+			if n <= 0 {
+				break
+			}
+			return off, n, nil
+		}
+		off += int(s.Pages) * pageSize
+	}
+	return 0, 0, fmt.Errorf("exefs: address %#x is not in the code", addr)
+}
+
+// Addr returns the virtual address of an offset in c.Data.
+func (c *Code) Addr(off int) (uint32, error) {
+	start := 0
+	for _, s := range c.Segments() {
+		if off >= start && off-start < int(s.Size) {
+			return s.Addr + uint32(off-start), nil
+		}
+		start += int(s.Pages) * pageSize
+	}
+	return 0, fmt.Errorf("exefs: offset %#x is not in the code", off)
+}
+
+// Bytes returns the n bytes at the virtual address addr.
+func (c *Code) Bytes(addr uint32, n int) ([]byte, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("exefs: negative length")
+	}
+	off, max, err := c.Offset(addr)
+	if err != nil {
+		return nil, err
+	}
+	if n > max {
+		return nil, fmt.Errorf("exefs: %#x bytes at %#x crosses the end of the segment", n, addr)
+	}
+	return c.Data[off : off+n], nil
+}
+
+// Read reads the structure at the virtual address addr into v,
+// as by binary.Read.
+func (c *Code) Read(addr uint32, v interface{}) error {
+	size := binary.Size(v)
+	if size < 0 {
+		return fmt.Errorf("exefs: cannot read %T", v)
+	}
+	b, err := c.Bytes(addr, size)
+	if err != nil {
+		return err
+	}
+	return binary.Read(bytes.NewReader(b), le, v)
+}
@@ -0,0 +1,100 @@
+// Package exefs reads the ExeFS of a 3DS title
+// and the code it contains.
+package exefs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var le = binary.LittleEndian
+
+var ErrHeader = errors.New("exefs: invalid header")
+
+const (
+	maxFiles   = 10
+	headerSize = 0x200
+)
+
+// Header is the ExeFS header.
+// The file data follows the header.
+// Hashes are stored in reverse order:
+// the hash of file i is Hashes[maxFiles-1-i].
+type Header struct {
+	Files  [maxFiles]FileHeader
+	_      [0x20]uint8
+	Hashes [maxFiles][sha256.Size]uint8
+}
+
+type FileHeader struct {
+	Name   [8]byte
+	Offset uint32 // relative to the end of the header
+	Size   uint32
+}
+
+// An ExeFS is a parsed ExeFS image.
+type ExeFS struct {
+	Header Header
+	Files  []*File
+}
+
+type File struct {
+	Name string
+	Hash [sha256.Size]uint8
+	*io.SectionReader
+}
+
+func (f *File) String() string {
+	return f.Name
+}
+
+// Read parses the ExeFS header and returns the files it lists.
+func Read(r io.ReaderAt) (*ExeFS, error) {
+	e := new(ExeFS)
+	err := binary.Read(io.NewSectionReader(r, 0, headerSize), le, &e.Header)
+	if err != nil {
+		return nil, ErrHeader
+	}
+	for i, fh := range e.Header.Files {
+		if fh.Name == [8]byte{} {
+			continue
+		}
+		name := fh.Name[:]
+		if j := bytes.IndexByte(name, 0); j >= 0 {
+			name = name[:j]
+		}
+		e.Files = append(e.Files, &File{
+			Name:          string(name),
+			Hash:          e.Header.Hashes[maxFiles-1-i],
+			SectionReader: io.NewSectionReader(r, headerSize+int64(fh.Offset), int64(fh.Size)),
+		})
+	}
+	return e, nil
+}
+
+// File returns the named file, such as ".code" or "icon",
+// or nil if there is no such file.
+func (e *ExeFS) File(name string) *File {
+	for _, f := range e.Files {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Verify checks the file's contents against its hash.
+func (f *File) Verify() error {
+	h := sha256.New()
+	_, err := io.Copy(h, io.NewSectionReader(f, 0, f.Size()))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(h.Sum(nil), f.Hash[:]) {
+		return errors.New("exefs: hash mismatch for " + f.Name)
+	}
+	return nil
+}
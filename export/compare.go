@@ -0,0 +1,84 @@
+package export
+
+import "strconv"
+
+// A Change is a field whose value differs between two records.
+type Change struct {
+	Field string      // path of the field, e.g. "stats.hp" or "pokemon.2.level"
+	Old   interface{} // nil if the field was added
+	New   interface{} // nil if the field was removed
+}
+
+// Compare returns the fields which differ between a and b,
+// in the order of a's fields followed by those only in b.
+// Nested records are compared field by field
+// and lists of records element by element;
+// other lists are compared as a whole.
+func Compare(a, b Record) []Change {
+	var changes []Change
+	compareRecords(&changes, "", a, b)
+	return changes
+}
+
+func compareRecords(changes *[]Change, prefix string, a, b Record) {
+	seen := make(map[string]bool)
+	for _, f := range a {
+		seen[f.Name] = true
+		compare(changes, prefix+f.Name, f.Value, b.Get(f.Name))
+	}
+	for _, f := range b {
+		if !seen[f.Name] {
+			compare(changes, prefix+f.Name, nil, f.Value)
+		}
+	}
+}
+
+func compare(changes *[]Change, name string, a, b interface{}) {
+	ra, aok := a.(Record)
+	rb, bok := b.(Record)
+	if aok && bok {
+		compareRecords(changes, name+".", ra, rb)
+		return
+	}
+	la, aok := list(a)
+	lb, bok := list(b)
+	if aok && bok && (hasRecords(la) || hasRecords(lb)) {
+		for i := 0; i < len(la) || i < len(lb); i++ {
+			var x, y interface{}
+			if i < len(la) {
+				x = la[i]
+			}
+			if i < len(lb) {
+				y = lb[i]
+			}
+			compare(changes, name+"."+strconv.Itoa(i), x, y)
+		}
+		return
+	}
+	if equal(a, b) {
+		return
+	}
+	*changes = append(*changes, Change{name, a, b})
+}
+
+func hasRecords(l []interface{}) bool {
+	for _, v := range l {
+		if _, ok := v.(Record); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// equal reports whether a and b would be exported the same.
+func equal(a, b interface{}) bool {
+	if (a == nil) != (b == nil) {
+		return false
+	}
+	sa, err := cell(a)
+	if err != nil {
+		return false
+	}
+	sb, err := cell(b)
+	return err == nil && sa == sb
+}
@@ -0,0 +1,74 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// WriteCSV writes the table as CSV with a header row.
+// Fields of nested Records become columns named "parent.child",
+// and lists are written as JSON arrays.
+// The columns are the fields of every record, in the order first seen;
+// missing fields are left empty.
+func WriteCSV(w io.Writer, table []Record) error {
+	var columns []string
+	seen := make(map[string]bool)
+	rows := make([]map[string]string, len(table))
+	for i, r := range table {
+		row := make(map[string]string)
+		err := flatten(row, "", r, func(name string) {
+			if !seen[name] {
+				seen[name] = true
+				columns = append(columns, name)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		rows[i] = row
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write(columns)
+	line := make([]string, len(columns))
+	for _, row := range rows {
+		for i, c := range columns {
+			line[i] = row[c]
+		}
+		cw.Write(line)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func flatten(row map[string]string, prefix string, r Record, column func(string)) error {
+	for _, f := range r {
+		name := prefix + f.Name
+		if sub, ok := f.Value.(Record); ok && len(sub) > 0 {
+			if err := flatten(row, name+".", sub, column); err != nil {
+				return err
+			}
+			continue
+		}
+		column(name)
+		s, err := cell(f.Value)
+		if err != nil {
+			return err
+		}
+		row[name] = s
+	}
+	return nil
+}
+
+func cell(v interface{}) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	if !isScalar(v) {
+		b, err := json.Marshal(jsonValue(v))
+		return string(b), err
+	}
+	return fmt.Sprint(v), nil
+}
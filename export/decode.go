@@ -0,0 +1,265 @@
+package export
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// A Lookup converts a name to a number, such as names.MoveID.
+type Lookup func(name string) (int, bool)
+
+// A Decoder sets values from the fields of a Record.
+// Fields which are missing are skipped, leaving the values unchanged,
+// so that a record need only list the fields being edited.
+// The first error is kept and reported by Err,
+// and every later call does nothing.
+type Decoder struct {
+	r    Record
+	path string
+	err  *error
+}
+
+// NewDecoder returns a Decoder for r.
+// Errors are prefixed with path, e.g. "moves[12]".
+func NewDecoder(r Record, path string) *Decoder {
+	return &Decoder{r, path, new(error)}
+}
+
+// Err returns the first error.
+func (d *Decoder) Err() error { return *d.err }
+
+// Fail records an error in the named field, unless there already is one.
+func (d *Decoder) Fail(name string, err error) {
+	if *d.err == nil {
+		*d.err = fmt.Errorf("%s.%s: %v", d.path, name, err)
+	}
+}
+
+// Has reports whether the record has the named field.
+func (d *Decoder) Has(name string) bool {
+	for _, f := range d.r {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Decoder) get(name string) (interface{}, bool) {
+	if *d.err != nil || !d.Has(name) {
+		return nil, false
+	}
+	return d.r.Get(name), true
+}
+
+// Int sets the integer dst points to.
+// The value must fit in dst's type.
+func (d *Decoder) Int(name string, dst interface{}) {
+	d.Name(name, dst, nil)
+}
+
+// Name is like Int, but also accepts names, which are converted by lookup.
+// An empty name or null is 0.
+func (d *Decoder) Name(name string, dst interface{}, lookup Lookup) {
+	d.ID(name, dst, lookup, 0)
+}
+
+// ID is like Name, but numbers must also be less than n,
+// such as the number of moves in the game.
+// If n is 0, any number that fits in dst is accepted.
+func (d *Decoder) ID(name string, dst interface{}, lookup Lookup, n int) {
+	v, ok := d.get(name)
+	if !ok {
+		return
+	}
+	if err := setInt(reflect.ValueOf(dst).Elem(), v, lookup, n); err != nil {
+		d.Fail(name, err)
+	}
+}
+
+// Ints sets the elements of the array or slice dst points to.
+// The list must have the same length as an array.
+func (d *Decoder) Ints(name string, dst interface{}) {
+	d.Names(name, dst, nil)
+}
+
+// Names is like Ints, but also accepts names.
+// Null is an empty list.
+func (d *Decoder) Names(name string, dst interface{}, lookup Lookup) {
+	d.IDs(name, dst, lookup, 0)
+}
+
+// IDs is like Names, but numbers must also be less than n. See ID.
+func (d *Decoder) IDs(name string, dst interface{}, lookup Lookup, n int) {
+	v, ok := d.get(name)
+	if !ok {
+		return
+	}
+	l, ok := list(v)
+	if !ok && v != nil {
+		d.Fail(name, fmt.Errorf("not a list"))
+		return
+	}
+	a := reflect.ValueOf(dst).Elem()
+	if a.Kind() == reflect.Slice {
+		a.Set(reflect.MakeSlice(a.Type(), len(l), len(l)))
+	} else if a.Len() != len(l) {
+		d.Fail(name, fmt.Errorf("list has %d elements, want %d", len(l), a.Len()))
+		return
+	}
+	for i, e := range l {
+		if err := setInt(a.Index(i), e, lookup, n); err != nil {
+			d.Fail(fmt.Sprintf("%s[%d]", name, i), err)
+			return
+		}
+	}
+}
+
+// Bool sets the bool dst points to.
+func (d *Decoder) Bool(name string, dst *bool) {
+	v, ok := d.get(name)
+	if !ok {
+		return
+	}
+	b, ok := v.(bool)
+	if !ok {
+		d.Fail(name, fmt.Errorf("%v is not a boolean", v))
+		return
+	}
+	*dst = b
+}
+
+// String sets the string dst points to.
+func (d *Decoder) String(name string, dst *string) {
+	v, ok := d.get(name)
+	if !ok {
+		return
+	}
+	s, ok := v.(string)
+	if !ok {
+		d.Fail(name, fmt.Errorf("%v is not a string", v))
+		return
+	}
+	*dst = s
+}
+
+// Strings sets the slice dst points to from a list of strings.
+func (d *Decoder) Strings(name string, dst *[]string) {
+	v, ok := d.get(name)
+	if !ok {
+		return
+	}
+	l, ok := list(v)
+	if !ok && v != nil {
+		d.Fail(name, fmt.Errorf("not a list"))
+		return
+	}
+	s := make([]string, len(l))
+	for i, e := range l {
+		if s[i], ok = e.(string); !ok {
+			s[i] = fmt.Sprint(e)
+		}
+	}
+	*dst = s
+}
+
+// Record returns a Decoder for the nested record in the named field.
+// If there is none, the Decoder has no fields.
+func (d *Decoder) Record(name string) *Decoder {
+	sub := &Decoder{nil, d.path + "." + name, d.err}
+	v, ok := d.get(name)
+	if !ok || v == nil {
+		return sub
+	}
+	r, ok := v.(Record)
+	if !ok {
+		d.Fail(name, fmt.Errorf("not an object"))
+		return sub
+	}
+	sub.r = r
+	return sub
+}
+
+// List returns Decoders for the records in the named list.
+// It returns nil if the field is missing, and an empty list for null.
+func (d *Decoder) List(name string) []*Decoder {
+	v, ok := d.get(name)
+	if !ok {
+		return nil
+	}
+	ds := []*Decoder{}
+	if v == nil {
+		return ds
+	}
+	l, ok := list(v)
+	if !ok {
+		d.Fail(name, fmt.Errorf("not a list"))
+		return nil
+	}
+	for i, e := range l {
+		r, ok := e.(Record)
+		if !ok {
+			d.Fail(fmt.Sprintf("%s[%d]", name, i), fmt.Errorf("not an object"))
+			return nil
+		}
+		ds = append(ds, &Decoder{r, fmt.Sprintf("%s.%s[%d]", d.path, name, i), d.err})
+	}
+	return ds
+}
+
+func toInt(v interface{}, lookup Lookup) (int64, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return int64(v), nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("%v is not an integer", v)
+		}
+		return int64(v), nil
+	case string:
+		if v == "" {
+			return 0, nil
+		}
+		if n, err := strconv.ParseInt(v, 0, 64); err == nil {
+			return n, nil
+		}
+		if lookup == nil {
+			return 0, fmt.Errorf("%q is not a number", v)
+		}
+		n, ok := lookup(v)
+		if !ok {
+			return 0, fmt.Errorf("unknown name %q", v)
+		}
+		return int64(n), nil
+	}
+	return 0, fmt.Errorf("%v is not a number", v)
+}
+
+func setInt(dst reflect.Value, v interface{}, lookup Lookup, limit int) error {
+	n, err := toInt(v, lookup)
+	if err != nil {
+		return err
+	}
+	if limit > 0 && (n < 0 || n >= int64(limit)) {
+		return fmt.Errorf("%d out of range 0-%d", n, limit-1)
+	}
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if dst.OverflowInt(n) {
+			return fmt.Errorf("%d out of range", n)
+		}
+		dst.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n < 0 || dst.OverflowUint(uint64(n)) {
+			return fmt.Errorf("%d out of range", n)
+		}
+		dst.SetUint(uint64(n))
+	default:
+		return fmt.Errorf("cannot set %s", dst.Type())
+	}
+	return nil
+}
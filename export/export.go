@@ -0,0 +1,97 @@
+// Package export writes game data tables as JSON, CSV, or YAML.
+//
+// A table is a list of Records.
+// A Record is a list of named fields whose order is kept in the output,
+// so that every format lists the fields in the same order.
+// Field values may be nil, booleans, numbers, strings,
+// Records, or slices and arrays of those.
+package export
+
+import (
+	"errors"
+	"io"
+	"reflect"
+)
+
+// A Field is a named value in a Record.
+type Field struct {
+	Name  string
+	Value interface{}
+}
+
+// A Record is an object whose fields keep their order.
+type Record []Field
+
+// Add appends a field to r.
+func (r *Record) Add(name string, value interface{}) {
+	*r = append(*r, Field{name, value})
+}
+
+// Get returns the value of the named field, or nil if there is none.
+func (r Record) Get(name string) interface{} {
+	for _, f := range r {
+		if f.Name == name {
+			return f.Value
+		}
+	}
+	return nil
+}
+
+// Formats lists the formats Write accepts.
+var Formats = []string{"json", "csv", "yaml"}
+
+var ErrFormat = errors.New("export: unknown format")
+
+// Write writes the table in the named format.
+func Write(w io.Writer, format string, table []Record) error {
+	switch format {
+	case "json":
+		return WriteJSON(w, table)
+	case "csv":
+		return WriteCSV(w, table)
+	case "yaml":
+		return WriteYAML(w, table)
+	}
+	return ErrFormat
+}
+
+// IsFormat reports whether Write accepts the format.
+func IsFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// list returns the elements of v if it is a slice or array,
+// other than a Record.
+func list(v interface{}) ([]interface{}, bool) {
+	if v == nil {
+		return nil, false
+	}
+	if _, ok := v.(Record); ok {
+		return nil, false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+	default:
+		return nil, false
+	}
+	l := make([]interface{}, rv.Len())
+	for i := range l {
+		l[i] = rv.Index(i).Interface()
+	}
+	return l, true
+}
+
+// isScalar reports whether v is neither a Record nor a list.
+func isScalar(v interface{}) bool {
+	if _, ok := v.(Record); ok {
+		return false
+	}
+	_, ok := list(v)
+	return !ok
+}
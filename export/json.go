@@ -0,0 +1,58 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// MarshalJSON encodes r as a JSON object with the fields in order.
+func (r Record) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range r {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, err := json.Marshal(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(jsonValue(f.Value))
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// WriteJSON writes the table as an indented JSON array.
+func WriteJSON(w io.Writer, table []Record) error {
+	if table == nil {
+		table = []Record{}
+	}
+	b, err := json.MarshalIndent(table, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	_, err = w.Write(b)
+	return err
+}
+
+// jsonValue converts lists to []interface{},
+// so that byte slices are not encoded as base64.
+func jsonValue(v interface{}) interface{} {
+	l, ok := list(v)
+	if !ok {
+		return v
+	}
+	for i := range l {
+		l[i] = jsonValue(l[i])
+	}
+	return l
+}
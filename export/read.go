@@ -0,0 +1,465 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Read reads a table in the named format, which must be json or yaml.
+// Integers are read as int and other numbers as float64;
+// objects are read as Records and arrays as []interface{}.
+func Read(r io.Reader, format string) ([]Record, error) {
+	switch format {
+	case "json":
+		return ReadJSON(r)
+	case "yaml":
+		return ReadYAML(r)
+	}
+	return nil, ErrFormat
+}
+
+// table converts a list of objects to a table.
+func table(v interface{}) ([]Record, error) {
+	l, ok := v.([]interface{})
+	if !ok {
+		if v == nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("export: table is not a list")
+	}
+	t := make([]Record, len(l))
+	for i, e := range l {
+		r, ok := e.(Record)
+		if !ok {
+			return nil, fmt.Errorf("export: table entry %d is not an object", i)
+		}
+		t[i] = r
+	}
+	return t, nil
+}
+
+// number converts the text of a number to an int or float64.
+func number(s string) (interface{}, error) {
+	if n, err := strconv.ParseInt(s, 0, 0); err == nil {
+		return int(n), nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// ReadJSON reads a table written by WriteJSON.
+func ReadJSON(r io.Reader) ([]Record, error) {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	v, err := readJSON(d)
+	if err != nil {
+		return nil, err
+	}
+	return table(v)
+}
+
+func readJSON(d *json.Decoder) (interface{}, error) {
+	t, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := t.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			r := Record{}
+			for d.More() {
+				k, err := d.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := readJSON(d)
+				if err != nil {
+					return nil, err
+				}
+				r.Add(k.(string), v)
+			}
+			_, err := d.Token()
+			return r, err
+		case '[':
+			l := []interface{}{}
+			for d.More() {
+				v, err := readJSON(d)
+				if err != nil {
+					return nil, err
+				}
+				l = append(l, v)
+			}
+			_, err := d.Token()
+			return l, err
+		}
+		return nil, fmt.Errorf("export: unexpected %v", t)
+	case json.Number:
+		return number(string(t))
+	}
+	return t, nil
+}
+
+// ReadYAML reads a table written by WriteYAML.
+//
+// Only a subset of YAML is understood:
+// block mappings and sequences, flow sequences and mappings,
+// plain, single-quoted, and double-quoted scalars on one line, and comments.
+// Anchors, tags, multi-line scalars, and multiple documents are not supported.
+func ReadYAML(r io.Reader) ([]Record, error) {
+	var p yamlParser
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		text := strings.TrimRight(stripComment(s.Text()), " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("export: line %d: tab in indentation", n)
+		}
+		p.lines = append(p.lines, yamlLine{n, len(text) - len(trimmed), trimmed})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+	v, err := p.block(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return table(v)
+}
+
+type yamlLine struct {
+	n      int // line number
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	n := 0
+	if p.pos < len(p.lines) {
+		n = p.lines[p.pos].n
+	} else if len(p.lines) > 0 {
+		n = p.lines[len(p.lines)-1].n
+	}
+	return fmt.Errorf("export: line %d: %s", n, fmt.Sprintf(format, args...))
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// block parses the sequence or mapping starting at the current line.
+func (p *yamlParser) block(indent int) (interface{}, error) {
+	if isSeqItem(p.lines[p.pos].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+// nested parses the value of a key or item with nothing after it on its line.
+// A sequence may be nested at the same indentation as a mapping key.
+func (p *yamlParser) nested(indent int, key bool) (interface{}, error) {
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	l := p.lines[p.pos]
+	if l.indent > indent || key && l.indent == indent && isSeqItem(l.text) {
+		return p.block(l.indent)
+	}
+	return nil, nil
+}
+
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+	list := []interface{}{}
+	for p.pos < len(p.lines) {
+		l := &p.lines[p.pos]
+		if l.indent != indent || !isSeqItem(l.text) {
+			break
+		}
+		rest := strings.TrimLeft(l.text[1:], " ")
+		if rest == "" {
+			p.pos++
+			v, err := p.nested(indent, false)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			continue
+		}
+		if _, _, ok := splitKey(rest); ok || isSeqItem(rest) {
+			// The item is a block whose first line follows the "-".
+			l.indent += len(l.text) - len(rest)
+			l.text = rest
+			v, err := p.block(l.indent)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			continue
+		}
+		v, err := inline(rest)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		p.pos++
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	r := Record{}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent != indent || isSeqItem(l.text) {
+			break
+		}
+		k, rest, ok := splitKey(l.text)
+		if !ok {
+			return nil, p.errorf("expected key: %s", l.text)
+		}
+		key, err := scalar(k)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		p.pos++
+		var v interface{}
+		if rest == "" {
+			v, err = p.nested(indent, true)
+		} else {
+			v, err = inline(rest)
+			if err != nil {
+				err = fmt.Errorf("export: line %d: %v", l.n, err)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.Add(fmt.Sprint(key), v)
+	}
+	return r, nil
+}
+
+// splitKey splits "key: value" at the first colon outside quotes
+// which is followed by a space or ends the line.
+func splitKey(s string) (key, value string, ok bool) {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 {
+				quote = c
+			}
+		case c == '[' || c == '{':
+			if i == 0 {
+				return "", "", false
+			}
+		case c == ':' && (i+1 == len(s) || s[i+1] == ' '):
+			return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// stripComment removes a comment starting with " #" or at the start of the line.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || s[i-1] == ' ' || strings.IndexByte("[{,:-", s[i-1]) >= 0 {
+				quote = c
+			}
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// inline parses a value written on one line.
+func inline(s string) (interface{}, error) {
+	if s[0] != '[' && s[0] != '{' {
+		return scalar(s)
+	}
+	f := &flowParser{s: s}
+	v, err := f.value()
+	if err != nil {
+		return nil, err
+	}
+	f.space()
+	if f.i < len(f.s) {
+		return nil, fmt.Errorf("unexpected %q", f.s[f.i:])
+	}
+	return v, nil
+}
+
+// scalar resolves a plain or quoted scalar.
+func scalar(s string) (interface{}, error) {
+	switch {
+	case s == "":
+		return nil, nil
+	case s[0] == '"':
+		return strconv.Unquote(s)
+	case s[0] == '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return nil, fmt.Errorf("unterminated string %s", s)
+		}
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+	switch s {
+	case "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if c := s[0]; c == '-' || c == '+' || c == '.' || '0' <= c && c <= '9' {
+		if v, err := number(s); err == nil {
+			return v, nil
+		}
+	}
+	return s, nil
+}
+
+// flowParser parses flow sequences and mappings, e.g. [grass, poison].
+type flowParser struct {
+	s string
+	i int
+}
+
+func (f *flowParser) space() {
+	for f.i < len(f.s) && f.s[f.i] == ' ' {
+		f.i++
+	}
+}
+
+func (f *flowParser) value() (interface{}, error) {
+	f.space()
+	if f.i >= len(f.s) {
+		return nil, nil
+	}
+	switch f.s[f.i] {
+	case '[':
+		f.i++
+		list := []interface{}{}
+		for {
+			f.space()
+			if f.i < len(f.s) && f.s[f.i] == ']' {
+				f.i++
+				return list, nil
+			}
+			v, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			if err := f.next(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.i++
+		r := Record{}
+		for {
+			f.space()
+			if f.i < len(f.s) && f.s[f.i] == '}' {
+				f.i++
+				return r, nil
+			}
+			k, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			f.space()
+			if f.i >= len(f.s) || f.s[f.i] != ':' {
+				return nil, fmt.Errorf("missing : after %v", k)
+			}
+			f.i++
+			v, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			r.Add(fmt.Sprint(k), v)
+			if err := f.next('}'); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return f.scalar()
+}
+
+// next skips the comma after an element, leaving a closing bracket.
+func (f *flowParser) next(end byte) error {
+	f.space()
+	switch {
+	case f.i >= len(f.s):
+		return fmt.Errorf("missing %c", end)
+	case f.s[f.i] == ',':
+		f.i++
+	case f.s[f.i] != end:
+		return fmt.Errorf("unexpected %q", f.s[f.i:])
+	}
+	return nil
+}
+
+func (f *flowParser) scalar() (interface{}, error) {
+	start := f.i
+	if c := f.s[f.i]; c == '"' || c == '\'' {
+		f.i++
+		for f.i < len(f.s) {
+			switch {
+			case c == '"' && f.s[f.i] == '\\':
+				f.i++
+			case f.s[f.i] == c:
+				if c == '\'' && f.i+1 < len(f.s) && f.s[f.i+1] == '\'' {
+					f.i++
+					break
+				}
+				f.i++
+				return scalar(f.s[start:f.i])
+			}
+			f.i++
+		}
+		return nil, fmt.Errorf("unterminated string %s", f.s[start:])
+	}
+	for f.i < len(f.s) {
+		c := f.s[f.i]
+		if c == ',' || c == ']' || c == '}' || c == ':' && (f.i+1 == len(f.s) || f.s[f.i+1] == ' ') {
+			break
+		}
+		f.i++
+	}
+	return scalar(strings.TrimSpace(f.s[start:f.i]))
+}
@@ -0,0 +1,136 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteYAML writes the table as a YAML sequence of mappings.
+// Lists of scalars are written in flow style, e.g. [grass, poison].
+func WriteYAML(w io.Writer, table []Record) error {
+	y := &yamlWriter{bufio.NewWriter(w)}
+	if len(table) == 0 {
+		y.line("[]")
+	}
+	for _, r := range table {
+		y.item("", r)
+	}
+	return y.w.Flush()
+}
+
+type yamlWriter struct {
+	w *bufio.Writer
+}
+
+func (y *yamlWriter) line(s string) {
+	y.w.WriteString(s)
+	y.w.WriteByte('\n')
+}
+
+// record writes the fields of r.
+// The first field follows first, and the others are indented by indent.
+func (y *yamlWriter) record(r Record, first, indent string) {
+	for i, f := range r {
+		p := indent
+		if i == 0 {
+			p = first
+		}
+		y.value(p+yamlString(f.Name)+":", indent, f.Value)
+	}
+}
+
+// item writes a sequence entry.
+func (y *yamlWriter) item(indent string, v interface{}) {
+	if r, ok := v.(Record); ok && len(r) > 0 {
+		y.record(r, indent+"- ", indent+"  ")
+		return
+	}
+	y.value(indent+"-", indent+"  ", v)
+}
+
+// value writes v after prefix, which ends in a key or a "-".
+// Nested values are indented by indent.
+func (y *yamlWriter) value(prefix, indent string, v interface{}) {
+	if r, ok := v.(Record); ok {
+		if len(r) == 0 {
+			y.line(prefix + " {}")
+			return
+		}
+		y.line(prefix)
+		y.record(r, indent+"  ", indent+"  ")
+		return
+	}
+	l, ok := list(v)
+	if !ok {
+		y.line(prefix + " " + yamlScalar(v))
+		return
+	}
+	flow := true
+	for _, e := range l {
+		if !isScalar(e) {
+			flow = false
+		}
+	}
+	if flow {
+		s := make([]string, len(l))
+		for i, e := range l {
+			s[i] = yamlScalar(e)
+		}
+		y.line(prefix + " [" + strings.Join(s, ", ") + "]")
+		return
+	}
+	y.line(prefix)
+	for _, e := range l {
+		y.item(indent+"  ", e)
+	}
+}
+
+func yamlScalar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return yamlString(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// yamlString quotes s unless it can be written as a plain scalar
+// which reads back as the same string.
+func yamlString(s string) string {
+	if needsQuote(s) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func needsQuote(s string) bool {
+	if s == "" || s != strings.TrimSpace(s) {
+		return true
+	}
+	switch strings.ToLower(s) {
+	case "~", "null", "true", "false", "yes", "no", "on", "off", "y", "n":
+		return true
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`.+0123456789") {
+		return true
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return true
+	}
+	for _, r := range s {
+		if r < ' ' || r == 0x7f || !strconv.IsPrint(r) {
+			return true
+		}
+	}
+	return strings.ContainsAny(s, ",[]{}")
+}
@@ -0,0 +1,120 @@
+package garc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+// An Entry is a file to be written to a GARC.
+type Entry struct {
+	Major int
+	Minor int
+	Data  []byte
+}
+
+// Entries reads the contents of files,
+// for editing and writing back with Write.
+func Entries(files []*File) ([]Entry, error) {
+	entries := make([]Entry, len(files))
+	for i, f := range files {
+		b, err := ioutil.ReadAll(io.NewSectionReader(&f.SectionReader, 0, f.Size()))
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = Entry{f.Major, f.Minor, b}
+	}
+	return entries, nil
+}
+
+// align is the alignment of the files' data.
+const align = 4
+
+// Write writes a version 4 GARC, as used by Pokémon X and Y
+// and Omega Ruby and Alpha Sapphire, holding the entries.
+// Each major number may have up to 32 minor numbers;
+// majors with no entries are written as empty.
+func Write(w io.Writer, entries []Entry) error {
+	entries = append([]Entry(nil), entries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		return a.Major < b.Major || a.Major == b.Major && a.Minor < b.Minor
+	})
+	nmajor := 0
+	if len(entries) > 0 {
+		nmajor = entries[len(entries)-1].Major + 1
+	}
+
+	// Lay out the data and build the FATB.
+	var fatb, data bytes.Buffer
+	osets := make([]uint32, nmajor)
+	largest := 0
+	for major, i := 0, 0; major < nmajor; major++ {
+		osets[major] = uint32(fatb.Len())
+		var vec uint32
+		var recs []Record
+		for ; i < len(entries) && entries[i].Major == major; i++ {
+			e := &entries[i]
+			vec |= 1 << uint(e.Minor)
+			start := data.Len()
+			data.Write(e.Data)
+			recs = append(recs, Record{uint32(start), uint32(data.Len()), uint32(len(e.Data))})
+			for data.Len()%align != 0 {
+				data.WriteByte(0xFF)
+			}
+			if len(e.Data) > largest {
+				largest = len(e.Data)
+			}
+		}
+		binary.Write(&fatb, binary.LittleEndian, vec)
+		binary.Write(&fatb, binary.LittleEndian, recs)
+	}
+
+	headSize := binary.Size(Header{})
+	fatoSize := binary.Size(FATO{}) + 4*nmajor
+	fatbSize := binary.Size(FATB{}) + fatb.Len()
+	const fimbSize = 12
+	dataOffset := headSize + fatoSize + fatbSize + fimbSize
+
+	head := Header{
+		Magic:      [4]byte{'C', 'R', 'A', 'G'},
+		HeaderSize: uint32(headSize),
+		BOM:        0x0400FEFF,
+		ChunkCount: 4,
+		DataOffset: uint32(dataOffset),
+		Size:       uint32(dataOffset + data.Len()),
+		LastSize:   uint32(largest),
+	}
+	fato := FATO{
+		Magic:       [4]byte{'O', 'T', 'A', 'F'},
+		Size:        uint32(fatoSize),
+		RecordCount: uint16(nmajor),
+	}
+	fatbHead := FATB{
+		Magic:       [4]byte{'B', 'T', 'A', 'F'},
+		Size:        uint32(fatbSize),
+		RecordCount: uint32(nmajor),
+	}
+	fimb := struct {
+		Magic      [4]byte
+		HeaderSize uint32
+		DataSize   uint32
+	}{[4]byte{'B', 'M', 'I', 'F'}, fimbSize, uint32(data.Len())}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, &head)
+	binary.Write(&buf, binary.LittleEndian, &fato)
+	b := buf.Bytes()
+	binary.LittleEndian.PutUint16(b[len(b)-2:], 0xFFFF)
+	binary.Write(&buf, binary.LittleEndian, osets)
+	binary.Write(&buf, binary.LittleEndian, &fatbHead)
+	buf.Write(fatb.Bytes())
+	binary.Write(&buf, binary.LittleEndian, &fimb)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	_, err := w.Write(data.Bytes())
+	return err
+}
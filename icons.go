@@ -1,38 +1,60 @@
+// Usage: icons [-atlas name] [-cols n] [-personal romfs/a/2/1/8] romfs/a/0/9/3 outdir
+// Extract the Pokémon icons as PNGs,
+// or assemble them into a single atlas with a JSON and CSS index.
+//
+// The index labels each icon with its species and form.
+// Alternate forms' icons follow the species' icons;
+// to label those, give the personal GARC, which has the form table.
 package main
 
 import (
-	"bytes"
-	"errors"
 	"encoding/binary"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"image"
+	"image/draw"
 	"image/png"
-	"image/color"
-	"io"
 	"log"
 	"os"
 	"path/filepath"
 
 	"xy/garc"
+	"xy/image/ctr"
 	"xy/lz"
+	"xy/names"
+	"xy/stats"
+	"xy/util"
 )
 
-var le = binary.LittleEndian
+var (
+	atlasName = flag.String("atlas", "", "write a single atlas `name`.png with name.json and name.css")
+	cols      = flag.Int("cols", 32, "number of icons per row in the atlas")
+	personal  = flag.String("personal", "", "label alternate forms using the personal `garc`")
+)
 
-type RGB15 uint16
+type icon struct {
+	Major   int    `json:"index"`
+	Minor   int    `json:"minor"`
+	Species int    `json:"species,omitempty"`
+	Form    int    `json:"form"`
+	Name    string `json:"name,omitempty"`
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+	W       int    `json:"w"`
+	H       int    `json:"h"`
 
-func (c RGB15) NRGBA() color.NRGBA {
-	return color.NRGBA{
-		R: uint8((uint32(c>>11&31)*0xFF + 15) / 31),
-		G: uint8((uint32(c>>6&31)*0xFF + 15) / 31),
-		B: uint8((uint32(c>>1&31)*0xFF + 15) / 31),
-		A: uint8(c&1)*0xFF,
-	}
+	m image.Image
 }
 
 func main() {
-	filename := os.Args[1]
-	outdir := os.Args[2]
+	flag.Parse()
+	if flag.NArg() < 2 {
+		fmt.Fprintln(os.Stderr, "usage: icons [-atlas name] [-cols n] [-personal romfs/a/2/1/8] romfs/a/0/9/3 outdir")
+		os.Exit(1)
+	}
+	filename := flag.Arg(0)
+	outdir := flag.Arg(1)
 	f, err := os.Open(filename)
 	if err != nil {
 		fmt.Println(err)
@@ -45,6 +67,24 @@ func main() {
 		return
 	}
 
+	var forms map[int]form
+	if *personal != "" {
+		forms, err = readForms(*personal)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	// Only number files by minor if the archive has any.
+	hasMinors := false
+	for _, file := range gfiles {
+		if file.Minor != 0 {
+			hasMinors = true
+		}
+	}
+
+	var icons []*icon
 	for _, file := range gfiles {
 		errname := fmt.Sprintf("%s[%d.%d]", filename, file.Major, file.Minor)
 		m, err := decode(file)
@@ -52,105 +92,193 @@ func main() {
 			log.Printf("%s: %s", errname, err)
 			continue
 		}
-		out, err := os.Create(filepath.Join(outdir, fmt.Sprintf("%d.png", file.Major)))
+		if *atlasName != "" {
+			ic := &icon{Major: file.Major, Minor: file.Minor, m: m}
+			if f, ok := forms[file.Major]; ok {
+				ic.Species = f.species
+				ic.Form = f.form
+				ic.Name = names.Species(f.species)
+			} else if name := names.Species(file.Major); name != "" {
+				ic.Species = file.Major
+				ic.Name = name
+			}
+			icons = append(icons, ic)
+			continue
+		}
+		outname := fmt.Sprintf("%d.png", file.Major)
+		if hasMinors {
+			outname = fmt.Sprintf("%d.%d.png", file.Major, file.Minor)
+		}
+		out, err := os.Create(filepath.Join(outdir, outname))
 		if err != nil {
-			log.Printf("%s: %s", err)
+			log.Printf("%s: %s", errname, err)
 			return
 		}
 		png.Encode(out, m)
+		out.Close()
+	}
+
+	if *atlasName != "" {
+		err := writeAtlas(filepath.Join(outdir, *atlasName), icons, *cols)
+		if err != nil {
+			log.Print(err)
+		}
 	}
 }
 
-func decode(f *garc.File) (*image.Paletted, error) {
-	z, err := lz.Decode(f)
+// A form identifies the Pokémon an icon shows.
+type form struct {
+	species int
+	form    int
+}
+
+// readForms maps the icons of alternate forms to their species and form.
+// FormTotal counts the alternate forms of the species before,
+// and the forms' icons begin where the forms' base stats do,
+// after the last species.
+func readForms(name string) (map[int]form, error) {
+	g, err := util.OpenGARC(name)
 	if err != nil {
 		return nil, err
 	}
-	r := bytes.NewReader(z)
-	r.Seek(-0x14, os.SEEK_END)
-
-	var imagHeader struct{
-		Magic [4]byte
-		HeaderSize uint32
-		Width uint16
-		Height uint16
-		_ uint32
-		DataSize uint32
+	defer g.Close()
+	if len(g.Files) == 0 {
+		return nil, fmt.Errorf("%s: empty", name)
 	}
-	err = binary.Read(r, le, &imagHeader)
-	if err != nil {
-		return nil, err
+	// The last file is every Pokémon's stats in one.
+	pokemon := make([]stats.PokemonStats, len(g.Files)-1)
+	for i := range pokemon {
+		err := binary.Read(g.Files[i], binary.LittleEndian, &pokemon[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s: file %d: %v", name, i, err)
+		}
 	}
-	if string(imagHeader.Magic[:]) != "imag" {
-		return nil, errors.New("not an imag")
+	first := 0
+	for _, p := range pokemon {
+		if n := int(p.FormStats); n != 0 && (first == 0 || n < first) {
+			first = n
+		}
 	}
+	if first == 0 {
+		first = len(pokemon)
+	}
+	forms := make(map[int]form)
+	for species := 1; species < first && species < len(pokemon); species++ {
+		p := &pokemon[species]
+		for j := 1; j < int(p.FormCount); j++ {
+			forms[first+int(p.FormTotal)+j-1] = form{species, j}
+		}
+	}
+	return forms, nil
+}
 
-	w := int(imagHeader.Width)
-	h := int(imagHeader.Height)
-
-	w = (w+31) &^ 31
-	h = (h+31) &^ 31
-
-	r.Seek(0, os.SEEK_SET)
-	var paletteHdr [2]uint16
-	err = binary.Read(r, le, &paletteHdr)
+func decode(f *garc.File) (image.Image, error) {
+	z, err := lz.Decode(f)
 	if err != nil {
 		return nil, err
 	}
-	paletteCount := paletteHdr[1]
-	colors := make([]RGB15, paletteCount)
-	err = binary.Read(r, le, colors)
+	// Most icons are paletted, but some are stored
+	// in one of the ordinary texture formats, such as ETC1.
+	foot, err := ctr.ReadFooter(z)
 	if err != nil {
 		return nil, err
 	}
-
-	pal := make(color.Palette, paletteCount)
-	for i, c := range colors {
-		pal[i] = c.NRGBA()
+	w, h := int(foot.Imag.Width), int(foot.Imag.Height)
+	if len(z)-foot.Size() == ctr.DataSize(w, h, foot.Imag.Format) {
+		return ctr.Decode(z)
 	}
+	return ctr.DecodePaletted(z)
+}
 
-	rect := image.Rect(0, 0, int(imagHeader.Width), int(imagHeader.Height))
-	m := image.NewPaletted(rect, pal)
-	// os.Stdin.Read(m.Pix)
+// writeAtlas lays out the icons in a grid of equal cells
+// and writes name.png, name.json, and name.css.
+func writeAtlas(name string, icons []*icon, cols int) error {
+	if len(icons) == 0 {
+		return fmt.Errorf("no icons")
+	}
+	if cols <= 0 {
+		cols = 1
+	}
+	cw, ch := 0, 0
+	for _, ic := range icons {
+		r := ic.m.Bounds()
+		if r.Dx() > cw {
+			cw = r.Dx()
+		}
+		if r.Dy() > ch {
+			ch = r.Dy()
+		}
+	}
+	rows := (len(icons) + cols - 1) / cols
+	if len(icons) < cols {
+		cols = len(icons)
+	}
+	atlas := image.NewNRGBA(image.Rect(0, 0, cols*cw, rows*ch))
+	for i, ic := range icons {
+		r := ic.m.Bounds()
+		ic.X = i % cols * cw
+		ic.Y = i / cols * ch
+		ic.W = r.Dx()
+		ic.H = r.Dy()
+		dr := image.Rect(ic.X, ic.Y, ic.X+ic.W, ic.Y+ic.H)
+		draw.Draw(atlas, dr, ic.m, r.Min, draw.Src)
+	}
 
-	var buf = make([]byte, int(imagHeader.DataSize) - 4 - len(pal)*2)
-	_, err = io.ReadFull(r, buf)
+	err := writeFile(name+".png", func(f *os.File) error {
+		return png.Encode(f, atlas)
+	})
 	if err != nil {
-		return nil, err
+		return err
 	}
-	ti := 0
-	const T = 8
-	var tileBuf [T*T]uint8
-	var tile []uint8
-	for y := 0; y < h; y += T {
-		for x := 0; x < w; x += T {
-			if paletteCount <= 16 {
-				for i := 0; i < T*T; i += 2 {
-					p := buf[ti*T*T/2 + i/2]
-					tileBuf[i] = p>>4
-					tileBuf[i+1] = p&0xF
-				}
-				tile = tileBuf[:]
-			} else {
-				tile = buf[ti*T*T:]
+	err = writeFile(name+".json", func(f *os.File) error {
+		b, err := json.MarshalIndent(icons, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(append(b, '\n'))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return writeFile(name+".css", func(f *os.File) error {
+		fmt.Fprintf(f, ".icon { display: inline-block; background: url(%s.png) no-repeat; width: %dpx; height: %dpx; }\n",
+			filepath.Base(name), cw, ch)
+		seen := make(map[string]bool)
+		for _, ic := range icons {
+			class := fmt.Sprintf("icon-%d", ic.Major)
+			if ic.Minor != 0 {
+				class += fmt.Sprintf("-%d", ic.Minor)
 			}
-			for ty := 0; ty < T; ty++ {
-				for tx := 0; tx < T; tx++ {
-					si := mingle(ty, tx)
-					m.SetColorIndex(x+tx, y+ty, tile[si])
+			sel := "." + class
+			// Also name the first icon of each species and form.
+			if ic.Name != "" && ic.Minor == 0 {
+				slug := "icon-" + names.Ident(ic.Name)
+				if ic.Form != 0 {
+					slug += fmt.Sprintf("-%d", ic.Form)
+				}
+				if !seen[slug] {
+					seen[slug] = true
+					sel += ", ." + slug
 				}
 			}
-			ti++
+			_, err := fmt.Fprintf(f, "%s { background-position: -%dpx -%dpx; }\n", sel, ic.X, ic.Y)
+			if err != nil {
+				return err
+			}
 		}
-	}
-	return m, nil
+		return nil
+	})
 }
 
-// Mingle interleaves the lower 4 bits of x and y
-func mingle(x, y int) int {
-	x = (x | x<<2) & 0x33
-	x = (x | x<<1) & 0x55
-	y = (y | y<<2) & 0x33
-	y = (y | y<<1) & 0x55
-	return x<<1 | y
+func writeFile(name string, write func(f *os.File) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
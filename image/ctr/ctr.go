@@ -0,0 +1,357 @@
+// Package ctr decodes the texture formats used by the Nintendo 3DS,
+// as found in BCLIM files and the "imag" footer of the games' icons.
+//
+// Textures are stored in 8x8 tiles, left to right and top to bottom.
+// The pixels within a tile are in Morton (Z) order.
+// The stored size of a texture is rounded up to a power of two;
+// decoded images are cropped to the size given in the header.
+package ctr
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+
+	"xy/image/etc1"
+)
+
+var le = binary.LittleEndian
+
+var (
+	ErrHeader = errors.New("ctr: invalid header")
+	ErrFormat = errors.New("ctr: unknown pixel format")
+	ErrShort  = errors.New("ctr: not enough pixel data")
+)
+
+// Format is a pixel format, numbered as in BCLIM files.
+type Format uint32
+
+const (
+	L8 Format = iota
+	A8
+	LA4
+	LA8
+	HILO8
+	RGB565
+	RGB8
+	RGBA5551
+	RGBA4444
+	RGBA8
+	ETC1
+	ETC1A4
+	L4
+	A4
+)
+
+var formatNames = []string{
+	L8:       "L8",
+	A8:       "A8",
+	LA4:      "LA4",
+	LA8:      "LA8",
+	HILO8:    "HILO8",
+	RGB565:   "RGB565",
+	RGB8:     "RGB8",
+	RGBA5551: "RGBA5551",
+	RGBA4444: "RGBA4444",
+	RGBA8:    "RGBA8",
+	ETC1:     "ETC1",
+	ETC1A4:   "ETC1A4",
+	L4:       "L4",
+	A4:       "A4",
+}
+
+var formatBits = []int{
+	L8:       8,
+	A8:       8,
+	LA4:      8,
+	LA8:      16,
+	HILO8:    16,
+	RGB565:   16,
+	RGB8:     24,
+	RGBA5551: 16,
+	RGBA4444: 16,
+	RGBA8:    32,
+	ETC1:     4,
+	ETC1A4:   8,
+	L4:       4,
+	A4:       4,
+}
+
+func (f Format) String() string {
+	if int(f) < len(formatNames) {
+		return formatNames[f]
+	}
+	return fmt.Sprintf("Format(%d)", uint32(f))
+}
+
+// Bits returns the number of bits per pixel, or 0 for an unknown format.
+func (f Format) Bits() int {
+	if int(f) < len(formatBits) {
+		return formatBits[f]
+	}
+	return 0
+}
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(s string) (Format, error) {
+	for i, name := range formatNames {
+		if name == s {
+			return Format(i), nil
+		}
+	}
+	return 0, ErrFormat
+}
+
+// CLIMHeader is the header at the start of a BCLIM footer.
+type CLIMHeader struct {
+	Magic      [4]byte // "CLIM"
+	BOM        uint16  // 0xFEFF
+	HeaderSize uint16  // 0x14
+	Version    uint32
+	FileSize   uint32
+	BlockCount uint32
+}
+
+// ImagHeader is the "imag" block which ends every texture.
+type ImagHeader struct {
+	Magic      [4]byte // "imag"
+	HeaderSize uint32  // 0x10
+	Width      uint16
+	Height     uint16
+	Format     Format
+	DataSize   uint32
+}
+
+const (
+	climSize = 0x14
+	imagSize = 0x14
+
+	// FooterSize is the size of a BCLIM footer.
+	FooterSize = climSize + imagSize
+)
+
+// Footer is the metadata at the end of a texture file.
+type Footer struct {
+	CLIM *CLIMHeader // nil if the file has no CLIM header
+	Imag ImagHeader
+}
+
+// ReadFooter parses the footer at the end of b.
+func ReadFooter(b []byte) (*Footer, error) {
+	if len(b) < imagSize {
+		return nil, ErrHeader
+	}
+	var f Footer
+	p := b[len(b)-imagSize:]
+	copy(f.Imag.Magic[:], p)
+	f.Imag.HeaderSize = le.Uint32(p[4:])
+	f.Imag.Width = le.Uint16(p[8:])
+	f.Imag.Height = le.Uint16(p[10:])
+	f.Imag.Format = Format(le.Uint32(p[12:]))
+	f.Imag.DataSize = le.Uint32(p[16:])
+	if string(f.Imag.Magic[:]) != "imag" {
+		return nil, ErrHeader
+	}
+	if len(b) >= FooterSize {
+		p := b[len(b)-FooterSize:]
+		if string(p[:4]) == "CLIM" {
+			var c CLIMHeader
+			copy(c.Magic[:], p)
+			c.BOM = le.Uint16(p[4:])
+			c.HeaderSize = le.Uint16(p[6:])
+			c.Version = le.Uint32(p[8:])
+			c.FileSize = le.Uint32(p[12:])
+			c.BlockCount = le.Uint32(p[16:])
+			f.CLIM = &c
+		}
+	}
+	return &f, nil
+}
+
+// Size returns the size of the footer in bytes.
+func (f *Footer) Size() int {
+	if f.CLIM != nil {
+		return FooterSize
+	}
+	return imagSize
+}
+
+// Decode decodes a texture file ending in a BCLIM or imag footer.
+func Decode(b []byte) (image.Image, error) {
+	f, err := ReadFooter(b)
+	if err != nil {
+		return nil, err
+	}
+	data := b[:len(b)-f.Size()]
+	return DecodeData(data, int(f.Imag.Width), int(f.Imag.Height), f.Imag.Format)
+}
+
+// DecodeConfig returns the dimensions and color model of a texture
+// without decoding it.
+func DecodeConfig(b []byte) (image.Config, Format, error) {
+	f, err := ReadFooter(b)
+	if err != nil {
+		return image.Config{}, 0, err
+	}
+	return image.Config{
+		ColorModel: color.NRGBAModel,
+		Width:      int(f.Imag.Width),
+		Height:     int(f.Imag.Height),
+	}, f.Imag.Format, nil
+}
+
+// PaddedSize returns the stored dimensions of a w×h texture.
+func PaddedSize(w, h int) (int, int) {
+	return pow2(w), pow2(h)
+}
+
+// DataSize returns the number of bytes of pixel data
+// in a w×h texture of format f.
+func DataSize(w, h int, f Format) int {
+	pw, ph := PaddedSize(w, h)
+	return pw * ph * f.Bits() / 8
+}
+
+func pow2(n int) int {
+	p := 8
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+// DecodeData decodes the tiled pixel data of a w×h texture.
+func DecodeData(data []byte, w, h int, f Format) (image.Image, error) {
+	if f.Bits() == 0 {
+		return nil, ErrFormat
+	}
+	pw, ph := PaddedSize(w, h)
+	if len(data) < pw*ph*f.Bits()/8 {
+		return nil, ErrShort
+	}
+	var m *image.NRGBA
+	switch f {
+	case ETC1, ETC1A4:
+		var err error
+		m, err = etc1.Decode(data, pw, ph, f == ETC1A4)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		m = image.NewNRGBA(image.Rect(0, 0, pw, ph))
+		decodeTiles(m, data, f)
+	}
+	return m.SubImage(image.Rect(0, 0, w, h)), nil
+}
+
+// decodeTiles decodes the formats which store whole pixels in Morton order.
+func decodeTiles(m *image.NRGBA, data []byte, f Format) {
+	const T = 8
+	bits := f.Bits()
+	w, h := m.Rect.Dx(), m.Rect.Dy()
+	i := 0 // pixel index
+	for y := 0; y < h; y += T {
+		for x := 0; x < w; x += T {
+			for j := 0; j < T*T; j++ {
+				tx, ty := demingle(j)
+				var c color.NRGBA
+				if bits == 4 {
+					p := data[i/2] >> uint(i%2*4) & 0xF
+					c = decode4(p, f)
+				} else {
+					c = decodePixel(data[i*bits/8:], f)
+				}
+				m.SetNRGBA(x+tx, y+ty, c)
+				i++
+			}
+		}
+	}
+}
+
+func decode4(p uint8, f Format) color.NRGBA {
+	v := p<<4 | p
+	switch f {
+	case L4:
+		return color.NRGBA{v, v, v, 0xFF}
+	case A4:
+		return color.NRGBA{0xFF, 0xFF, 0xFF, v}
+	}
+	return color.NRGBA{}
+}
+
+func decodePixel(p []byte, f Format) color.NRGBA {
+	switch f {
+	case L8:
+		return color.NRGBA{p[0], p[0], p[0], 0xFF}
+	case A8:
+		return color.NRGBA{0xFF, 0xFF, 0xFF, p[0]}
+	case LA4:
+		l := p[0]>>4 | p[0]&0xF0
+		a := p[0]<<4 | p[0]&0x0F
+		return color.NRGBA{l, l, l, a}
+	case LA8:
+		return color.NRGBA{p[1], p[1], p[1], p[0]}
+	case HILO8:
+		return color.NRGBA{p[1], p[0], 0, 0xFF}
+	case RGB565:
+		v := le.Uint16(p)
+		return color.NRGBA{
+			scale(uint32(v>>11&31), 31),
+			scale(uint32(v>>5&63), 63),
+			scale(uint32(v&31), 31),
+			0xFF,
+		}
+	case RGB8:
+		return color.NRGBA{p[2], p[1], p[0], 0xFF}
+	case RGBA5551:
+		return RGBA5551Color(le.Uint16(p)).NRGBA()
+	case RGBA4444:
+		v := le.Uint16(p)
+		return color.NRGBA{
+			scale(uint32(v>>12&15), 15),
+			scale(uint32(v>>8&15), 15),
+			scale(uint32(v>>4&15), 15),
+			scale(uint32(v&15), 15),
+		}
+	case RGBA8:
+		return color.NRGBA{p[3], p[2], p[1], p[0]}
+	}
+	return color.NRGBA{}
+}
+
+// scale expands a value in [0, max] to [0, 255].
+func scale(v, max uint32) uint8 {
+	return uint8((v*0xFF + max/2) / max)
+}
+
+// RGBA5551Color is a 16-bit color with 5 bits per channel and 1 bit of alpha.
+// Palettes use the same layout, sometimes called RGB15.
+type RGBA5551Color uint16
+
+func (c RGBA5551Color) NRGBA() color.NRGBA {
+	return color.NRGBA{
+		R: scale(uint32(c>>11&31), 31),
+		G: scale(uint32(c>>6&31), 31),
+		B: scale(uint32(c>>1&31), 31),
+		A: uint8(c&1) * 0xFF,
+	}
+}
+
+func (c RGBA5551Color) RGBA() (r, g, b, a uint32) {
+	return c.NRGBA().RGBA()
+}
+
+// Demingle splits the index of a pixel within a tile
+// into its x and y coordinates.
+// The bits of x and y are interleaved, starting with x.
+func demingle(i int) (x, y int) {
+	x = i & 0x55
+	x = (x | x>>1) & 0x33
+	x = (x | x>>2) & 0x0F
+	y = i >> 1 & 0x55
+	y = (y | y>>1) & 0x33
+	y = (y | y>>2) & 0x0F
+	return x, y
+}
@@ -0,0 +1,240 @@
+package ctr
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+
+	"xy/image/etc1"
+	"xy/lz"
+)
+
+// Options are the encoding parameters.
+type Options struct {
+	// Quality is the ETC1 encoding quality.
+	Quality etc1.Quality
+
+	// CLIM adds a CLIM header before the imag block, as in BCLIM files.
+	// Without it only the imag block is written, as in the icon archives.
+	CLIM bool
+
+	// Compress compresses the output with LZ11,
+	// as the files in GARC archives are.
+	Compress bool
+
+	// Colors is the maximum number of colors in the palette
+	// written by EncodePaletted. The default is 16.
+	Colors int
+
+	// PaletteHeader is the first word of the palette header
+	// and ImagFormat the format field of the imag block
+	// written by EncodePaletted.
+	// Their meaning is unknown; copy them from the file being replaced.
+	PaletteHeader uint16
+	ImagFormat    Format
+}
+
+// Encode writes m to w as a texture of format f, followed by its footer.
+// A nil *Options means the defaults.
+func Encode(w io.Writer, m image.Image, f Format, o *Options) error {
+	if o == nil {
+		o = new(Options)
+	}
+	data, err := EncodeData(m, f, o.Quality)
+	if err != nil {
+		return err
+	}
+	r := m.Bounds()
+	b := appendFooter(data, r.Dx(), r.Dy(), f, len(data), o.CLIM)
+	return write(w, b, o.Compress)
+}
+
+// EncodeData returns the tiled pixel data of m in format f,
+// padded to the texture's stored size.
+func EncodeData(m image.Image, f Format, q etc1.Quality) ([]byte, error) {
+	if f.Bits() == 0 {
+		return nil, ErrFormat
+	}
+	r := m.Bounds()
+	pw, ph := PaddedSize(r.Dx(), r.Dy())
+	padded := image.NewNRGBA(image.Rect(0, 0, pw, ph))
+	draw.Draw(padded, r.Sub(r.Min), m, r.Min, draw.Src)
+
+	switch f {
+	case ETC1, ETC1A4:
+		return etc1.Encode(padded, f == ETC1A4, &etc1.Options{Quality: q}), nil
+	}
+
+	const T = 8
+	bits := f.Bits()
+	data := make([]byte, pw*ph*bits/8)
+	i := 0
+	for y := 0; y < ph; y += T {
+		for x := 0; x < pw; x += T {
+			for j := 0; j < T*T; j++ {
+				tx, ty := demingle(j)
+				c := padded.NRGBAAt(x+tx, y+ty)
+				if bits == 4 {
+					data[i/2] |= encode4(c, f) << uint(i%2*4)
+				} else {
+					encodePixel(data[i*bits/8:], c, f)
+				}
+				i++
+			}
+		}
+	}
+	return data, nil
+}
+
+func encode4(c color.NRGBA, f Format) uint8 {
+	switch f {
+	case L4:
+		return quant(luma(c), 15)
+	case A4:
+		return quant(c.A, 15)
+	}
+	return 0
+}
+
+func encodePixel(p []byte, c color.NRGBA, f Format) {
+	switch f {
+	case L8:
+		p[0] = luma(c)
+	case A8:
+		p[0] = c.A
+	case LA4:
+		p[0] = quant(luma(c), 15)<<4 | quant(c.A, 15)
+	case LA8:
+		p[0] = c.A
+		p[1] = luma(c)
+	case HILO8:
+		p[0] = c.G
+		p[1] = c.R
+	case RGB565:
+		le.PutUint16(p, uint16(quant(c.R, 31))<<11|uint16(quant(c.G, 63))<<5|uint16(quant(c.B, 31)))
+	case RGB8:
+		p[0], p[1], p[2] = c.B, c.G, c.R
+	case RGBA5551:
+		le.PutUint16(p, uint16(ToRGBA5551(c)))
+	case RGBA4444:
+		le.PutUint16(p, uint16(quant(c.R, 15))<<12|uint16(quant(c.G, 15))<<8|uint16(quant(c.B, 15))<<4|uint16(quant(c.A, 15)))
+	case RGBA8:
+		p[0], p[1], p[2], p[3] = c.A, c.B, c.G, c.R
+	}
+}
+
+// quant reduces v from [0, 255] to [0, max].
+func quant(v uint8, max uint32) uint8 {
+	return uint8((uint32(v)*max + 127) / 255)
+}
+
+func luma(c color.NRGBA) uint8 {
+	return uint8((299*uint32(c.R) + 587*uint32(c.G) + 114*uint32(c.B) + 500) / 1000)
+}
+
+// ToRGBA5551 converts c to the nearest RGBA5551 color.
+func ToRGBA5551(c color.Color) RGBA5551Color {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	v := uint16(quant(n.R, 31))<<11 | uint16(quant(n.G, 31))<<6 | uint16(quant(n.B, 31))<<1
+	if n.A >= 0x80 {
+		v |= 1
+	}
+	return RGBA5551Color(v)
+}
+
+// EncodePaletted writes m to w in the paletted format read by DecodePaletted.
+// If m is not an *image.Paletted, or has too many colors,
+// its colors are reduced first.
+func EncodePaletted(w io.Writer, m image.Image, o *Options) error {
+	if o == nil {
+		o = new(Options)
+	}
+	max := o.Colors
+	if max <= 0 {
+		max = 16
+	}
+	if max > 256 {
+		max = 256
+	}
+	r := m.Bounds()
+	pm, ok := m.(*image.Paletted)
+	if !ok || len(pm.Palette) > max {
+		pal := Quantize(m, max)
+		pm = image.NewPaletted(r, pal)
+		draw.Draw(pm, r, m, r.Min, draw.Src)
+	}
+
+	bits := 8
+	if len(pm.Palette) <= 16 {
+		bits = 4
+	}
+	pw, ph := PaddedSize(r.Dx(), r.Dy())
+
+	var buf bytes.Buffer
+	var hdr [4]byte
+	le.PutUint16(hdr[0:], o.PaletteHeader)
+	le.PutUint16(hdr[2:], uint16(len(pm.Palette)))
+	buf.Write(hdr[:])
+	for _, c := range pm.Palette {
+		var b [2]byte
+		le.PutUint16(b[:], uint16(ToRGBA5551(c)))
+		buf.Write(b[:])
+	}
+
+	pix := make([]byte, pw*ph*bits/8)
+	const T = 8
+	i := 0
+	for y := 0; y < ph; y += T {
+		for x := 0; x < pw; x += T {
+			for j := 0; j < T*T; j++ {
+				tx, ty := demingle(j)
+				var p uint8
+				if image.Pt(x+tx, y+ty).In(r.Sub(r.Min)) {
+					p = pm.ColorIndexAt(r.Min.X+x+tx, r.Min.Y+y+ty)
+				}
+				if bits == 4 {
+					pix[i/2] |= p & 0xF << uint(4-i%2*4)
+				} else {
+					pix[i] = p
+				}
+				i++
+			}
+		}
+	}
+	buf.Write(pix)
+
+	data := buf.Bytes()
+	b := appendFooter(data, r.Dx(), r.Dy(), o.ImagFormat, len(data), o.CLIM)
+	return write(w, b, o.Compress)
+}
+
+func appendFooter(b []byte, w, h int, f Format, dataSize int, clim bool) []byte {
+	var foot [FooterSize]byte
+	p := foot[:]
+	if clim {
+		copy(p, "CLIM")
+		le.PutUint16(p[4:], 0xFEFF)
+		le.PutUint16(p[6:], climSize)
+		le.PutUint32(p[8:], 0x02020000)
+		le.PutUint32(p[12:], uint32(len(b)+FooterSize))
+		le.PutUint32(p[16:], 1)
+		b = append(b, p[:climSize]...)
+	}
+	copy(p, "imag")
+	le.PutUint32(p[4:], 0x10)
+	le.PutUint16(p[8:], uint16(w))
+	le.PutUint16(p[10:], uint16(h))
+	le.PutUint32(p[12:], uint32(f))
+	le.PutUint32(p[16:], uint32(dataSize))
+	return append(b, p[:imagSize]...)
+}
+
+func write(w io.Writer, b []byte, compress bool) error {
+	if compress {
+		return lz.Encode(w, b)
+	}
+	_, err := w.Write(b)
+	return err
+}
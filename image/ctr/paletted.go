@@ -0,0 +1,79 @@
+package ctr
+
+import (
+	"image"
+	"image/color"
+)
+
+// DecodePaletted decodes a paletted texture, as used by the Pokémon icons.
+//
+// The pixel data is preceded by a header of two uint16s,
+// the second of which is the number of colors,
+// and a palette of RGBA5551 colors.
+// Textures with 16 colors or fewer use 4 bits per pixel,
+// high nibble first; others use 8.
+// The file ends with an imag footer, whose format field is ignored.
+func DecodePaletted(b []byte) (*image.Paletted, error) {
+	f, err := ReadFooter(b)
+	if err != nil {
+		return nil, err
+	}
+	data := b[:len(b)-f.Size()]
+	if len(data) < 4 {
+		return nil, ErrShort
+	}
+	n := int(le.Uint16(data[2:]))
+	data = data[4:]
+	if len(data) < n*2 {
+		return nil, ErrShort
+	}
+	pal := make(color.Palette, n)
+	for i := range pal {
+		pal[i] = RGBA5551Color(le.Uint16(data[i*2:])).NRGBA()
+	}
+	data = data[n*2:]
+
+	w, h := int(f.Imag.Width), int(f.Imag.Height)
+	pw, ph := PaddedSize(w, h)
+	bits := 8
+	if n <= 16 {
+		bits = 4
+	}
+	if len(data) < pw*ph*bits/8 {
+		return nil, ErrShort
+	}
+
+	m := image.NewPaletted(image.Rect(0, 0, pw, ph), pal)
+	const T = 8
+	i := 0
+	for y := 0; y < ph; y += T {
+		for x := 0; x < pw; x += T {
+			for j := 0; j < T*T; j++ {
+				tx, ty := demingle(j)
+				var p uint8
+				if bits == 4 {
+					p = data[i/2] >> uint(4-i%2*4) & 0xF
+				} else {
+					p = data[i]
+				}
+				m.SetColorIndex(x+tx, y+ty, p)
+				i++
+			}
+		}
+	}
+	return m.SubImage(image.Rect(0, 0, w, h)).(*image.Paletted), nil
+}
+
+// PaletteHeader returns the first word of a paletted texture's header
+// and the format field of its imag block,
+// for passing to EncodePaletted in Options.
+func PaletteHeader(b []byte) (uint16, Format, error) {
+	f, err := ReadFooter(b)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(b)-f.Size() < 4 {
+		return 0, 0, ErrShort
+	}
+	return le.Uint16(b), f.Imag.Format, nil
+}
@@ -0,0 +1,142 @@
+package ctr
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// Quantize chooses a palette of at most n colors for m
+// using the median cut algorithm.
+// Colors are reduced to RGBA5551 precision first.
+// If m has any transparent pixels
+// the first color of the palette is transparent.
+func Quantize(m image.Image, n int) color.Palette {
+	hist := make(map[RGBA5551Color]int)
+	transparent := false
+	r := m.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := ToRGBA5551(m.At(x, y))
+			if c&1 == 0 {
+				transparent = true
+				continue
+			}
+			hist[c]++
+		}
+	}
+
+	var pal color.Palette
+	if transparent {
+		pal = append(pal, color.NRGBA{})
+		n--
+	}
+	if n <= 0 {
+		return pal
+	}
+
+	all := make([]entry, 0, len(hist))
+	for c, count := range hist {
+		n := c.NRGBA()
+		all = append(all, entry{[3]int{int(n.R), int(n.G), int(n.B)}, count})
+	}
+	// Make the result independent of map order.
+	sort.Slice(all, func(i, j int) bool { return all[i].less(all[j]) })
+
+	boxes := []box{{all}}
+	for len(boxes) < n {
+		// Split the box with the most pixels which can be split.
+		bi := -1
+		for i, b := range boxes {
+			if len(b.entries) < 2 {
+				continue
+			}
+			if bi < 0 || b.count() > boxes[bi].count() {
+				bi = i
+			}
+		}
+		if bi < 0 {
+			break
+		}
+		a, b := boxes[bi].split()
+		boxes[bi] = a
+		boxes = append(boxes, b)
+	}
+	for _, b := range boxes {
+		if len(b.entries) > 0 {
+			pal = append(pal, b.average())
+		}
+	}
+	return pal
+}
+
+type entry struct {
+	c     [3]int
+	count int
+}
+
+func (e entry) less(f entry) bool {
+	for i := range e.c {
+		if e.c[i] != f.c[i] {
+			return e.c[i] < f.c[i]
+		}
+	}
+	return false
+}
+
+type box struct {
+	entries []entry
+}
+
+func (b box) count() int {
+	n := 0
+	for _, e := range b.entries {
+		n += e.count
+	}
+	return n
+}
+
+// split divides b at the weighted median of its widest channel.
+func (b box) split() (box, box) {
+	ch, width := 0, -1
+	for i := 0; i < 3; i++ {
+		lo, hi := 255, 0
+		for _, e := range b.entries {
+			if e.c[i] < lo {
+				lo = e.c[i]
+			}
+			if e.c[i] > hi {
+				hi = e.c[i]
+			}
+		}
+		if hi-lo > width {
+			ch, width = i, hi-lo
+		}
+	}
+	es := b.entries
+	sort.SliceStable(es, func(i, j int) bool { return es[i].c[ch] < es[j].c[ch] })
+	half := b.count() / 2
+	k, sum := 1, es[0].count
+	for k < len(es)-1 && sum < half {
+		sum += es[k].count
+		k++
+	}
+	return box{es[:k:k]}, box{es[k:]}
+}
+
+func (b box) average() color.NRGBA {
+	var sum [3]int
+	n := 0
+	for _, e := range b.entries {
+		for i := range sum {
+			sum[i] += e.c[i] * e.count
+		}
+		n += e.count
+	}
+	return color.NRGBA{
+		R: uint8((sum[0] + n/2) / n),
+		G: uint8((sum[1] + n/2) / n),
+		B: uint8((sum[2] + n/2) / n),
+		A: 0xFF,
+	}
+}
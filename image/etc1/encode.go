@@ -0,0 +1,302 @@
+package etc1
+
+import (
+	"image"
+	"image/color"
+)
+
+// Quality controls how hard the encoder searches for the best encoding.
+type Quality int
+
+const (
+	// Low tries only the average color of each half block.
+	Low Quality = iota
+	// Medium also tries both block orientations and both color modes.
+	Medium
+	// High also searches the colors next to the averages.
+	High
+)
+
+// Options are the encoding parameters.
+type Options struct {
+	Quality Quality
+}
+
+// Encode encodes m as an ETC1 texture, or ETC1A4 if alpha is true.
+// The image is padded with transparent black to a multiple of 8 pixels.
+// A nil *Options is equivalent to Medium quality.
+func Encode(m image.Image, alpha bool, o *Options) []byte {
+	q := Medium
+	if o != nil {
+		q = o.Quality
+	}
+	r := m.Bounds()
+	w := (r.Dx() + 7) &^ 7
+	h := (r.Dy() + 7) &^ 7
+	out := make([]byte, 0, DataSize(w, h, alpha))
+	var buf [8]byte
+	for y := 0; y < h; y += 8 {
+		for x := 0; x < w; x += 8 {
+			for b := 0; b < 4; b++ {
+				bx := x + b%2*4
+				by := y + b/2*4
+				var px [16]color.NRGBA
+				for i := range px {
+					p := image.Pt(r.Min.X+bx+i/4, r.Min.Y+by+i%4)
+					if p.In(r) {
+						px[i] = color.NRGBAModel.Convert(m.At(p.X, p.Y)).(color.NRGBA)
+					}
+				}
+				if alpha {
+					le.PutUint64(buf[:], EncodeAlpha(&px))
+					out = append(out, buf[:]...)
+				}
+				le.PutUint64(buf[:], EncodeBlock(&px, q))
+				out = append(out, buf[:]...)
+			}
+		}
+	}
+	return out
+}
+
+// EncodeAlpha packs the alpha of 16 pixels, in column-major order,
+// into the 4-bit format of ETC1A4.
+func EncodeAlpha(px *[16]color.NRGBA) uint64 {
+	var a uint64
+	for i, c := range px {
+		a |= uint64((int(c.A)*15+127)/255) << uint(i*4)
+	}
+	return a
+}
+
+// EncodeBlock encodes 16 pixels, in column-major order, as an ETC1 block.
+// Alpha is ignored.
+func EncodeBlock(px *[16]color.NRGBA, q Quality) uint64 {
+	best := candidate{err: -1}
+	flips := []bool{false, true}
+	if q == Low {
+		flips = flips[:1]
+	}
+	for _, flip := range flips {
+		var avg [2][3]int
+		var n [2]int
+		for i, c := range px {
+			s := subblock(i, flip)
+			avg[s][0] += int(c.R)
+			avg[s][1] += int(c.G)
+			avg[s][2] += int(c.B)
+			n[s]++
+		}
+		for s := range avg {
+			for c := range avg[s] {
+				avg[s][c] = (avg[s][c] + n[s]/2) / n[s]
+			}
+		}
+
+		if c, ok := tryDifferential(px, flip, avg, q); ok {
+			best = best.min(c)
+		}
+		if q > Low || best.err < 0 {
+			best = best.min(tryIndividual(px, flip, avg, q))
+		}
+	}
+	return best.pack()
+}
+
+type candidate struct {
+	err   int
+	diff  bool
+	flip  bool
+	base  [2][3]int // quantized: 4 bits, or 5 bits in differential mode
+	table [2]int
+	index [16]int // 0: +a, 1: +b, 2: -a, 3: -b
+}
+
+func (c candidate) min(d candidate) candidate {
+	if c.err < 0 || d.err >= 0 && d.err < c.err {
+		return d
+	}
+	return c
+}
+
+func (c *candidate) pack() uint64 {
+	var hi, lo uint32
+	if c.diff {
+		for ch := 0; ch < 3; ch++ {
+			shift := uint(27 - ch*8)
+			d := c.base[1][ch] - c.base[0][ch]
+			hi |= uint32(c.base[0][ch]) << shift
+			hi |= uint32(d&7) << (shift - 3)
+		}
+		hi |= 2
+	} else {
+		for ch := 0; ch < 3; ch++ {
+			shift := uint(28 - ch*8)
+			hi |= uint32(c.base[0][ch]) << shift
+			hi |= uint32(c.base[1][ch]) << (shift - 4)
+		}
+	}
+	hi |= uint32(c.table[0])<<5 | uint32(c.table[1])<<2
+	if c.flip {
+		hi |= 1
+	}
+	for i, idx := range c.index {
+		lo |= uint32(idx&1) << uint(i)
+		lo |= uint32(idx>>1) << uint(i+16)
+	}
+	return uint64(hi)<<32 | uint64(lo)
+}
+
+// neighbors returns the quantized colors to try around q.
+func neighbors(q [3]int, max int, quality Quality) [][3]int {
+	if quality < High {
+		return [][3]int{q}
+	}
+	var list [][3]int
+	for dr := -1; dr <= 1; dr++ {
+		for dg := -1; dg <= 1; dg++ {
+			for db := -1; db <= 1; db++ {
+				c := [3]int{q[0] + dr, q[1] + dg, q[2] + db}
+				if inRange(c, max) {
+					list = append(list, c)
+				}
+			}
+		}
+	}
+	return list
+}
+
+func inRange(c [3]int, max int) bool {
+	for _, v := range c {
+		if v < 0 || v > max {
+			return false
+		}
+	}
+	return true
+}
+
+func quantize(avg [3]int, max int) [3]int {
+	var q [3]int
+	for i, v := range avg {
+		q[i] = (v*max + 127) / 255
+	}
+	return q
+}
+
+func tryIndividual(px *[16]color.NRGBA, flip bool, avg [2][3]int, q Quality) candidate {
+	c := candidate{flip: flip}
+	for s := 0; s < 2; s++ {
+		bestErr := -1
+		for _, base := range neighbors(quantize(avg[s], 15), 15, q) {
+			e, table, index := fitSubblock(px, flip, s, expand(base, expand4))
+			if bestErr < 0 || e < bestErr {
+				bestErr = e
+				c.base[s] = base
+				c.table[s] = table
+				setIndex(&c.index, flip, s, index)
+			}
+		}
+		c.err += bestErr
+	}
+	return c
+}
+
+func tryDifferential(px *[16]color.NRGBA, flip bool, avg [2][3]int, q Quality) (candidate, bool) {
+	c := candidate{diff: true, flip: flip, err: -1}
+	bases0 := neighbors(quantize(avg[0], 31), 31, q)
+	bases1 := neighbors(quantize(avg[1], 31), 31, q)
+
+	// Fit each half independently, then pick the best valid pair.
+	type fit struct {
+		base  [3]int
+		err   int
+		table int
+		index [16]int
+	}
+	fits := func(s int, bases [][3]int) []fit {
+		list := make([]fit, len(bases))
+		for i, base := range bases {
+			e, table, index := fitSubblock(px, flip, s, expand(base, expand5))
+			list[i] = fit{base, e, table, index}
+		}
+		return list
+	}
+	f0 := fits(0, bases0)
+	f1 := fits(1, bases1)
+	for _, a := range f0 {
+		for _, b := range f1 {
+			if !validDiff(a.base, b.base) {
+				continue
+			}
+			if c.err < 0 || a.err+b.err < c.err {
+				c.err = a.err + b.err
+				c.base = [2][3]int{a.base, b.base}
+				c.table = [2]int{a.table, b.table}
+				setIndex(&c.index, flip, 0, a.index)
+				setIndex(&c.index, flip, 1, b.index)
+			}
+		}
+	}
+	return c, c.err >= 0
+}
+
+func validDiff(a, b [3]int) bool {
+	for i := range a {
+		d := b[i] - a[i]
+		if d < -4 || d > 3 {
+			return false
+		}
+	}
+	return true
+}
+
+func expand(q [3]int, f func(int) int) [3]int {
+	return [3]int{f(q[0]), f(q[1]), f(q[2])}
+}
+
+func setIndex(dst *[16]int, flip bool, s int, src [16]int) {
+	for i := range dst {
+		if subblock(i, flip) == s {
+			dst[i] = src[i]
+		}
+	}
+}
+
+// fitSubblock finds the modifier table and pixel indices
+// which best fit half s of the block to the base color.
+func fitSubblock(px *[16]color.NRGBA, flip bool, s int, base [3]int) (err, table int, index [16]int) {
+	err = -1
+	for t := range modifiers {
+		var e int
+		var idx [16]int
+		for i, c := range px {
+			if subblock(i, flip) != s {
+				continue
+			}
+			best := -1
+			for k := 0; k < 4; k++ {
+				mod := modifiers[t][k&1]
+				if k >= 2 {
+					mod = -mod
+				}
+				d := distance(c, base, mod)
+				if best < 0 || d < best {
+					best = d
+					idx[i] = k
+				}
+			}
+			e += best
+		}
+		if err < 0 || e < err {
+			err, table, index = e, t, idx
+		}
+	}
+	return err, table, index
+}
+
+func distance(c color.NRGBA, base [3]int, mod int) int {
+	dr := int(c.R) - int(clamp(base[0]+mod))
+	dg := int(c.G) - int(clamp(base[1]+mod))
+	db := int(c.B) - int(clamp(base[2]+mod))
+	return dr*dr + dg*dg + db*db
+}
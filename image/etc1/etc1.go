@@ -0,0 +1,148 @@
+// Package etc1 implements the ETC1 texture compression format
+// as stored by the Nintendo 3DS.
+//
+// The 3DS divides a texture into 8x8 tiles, left to right and top to bottom,
+// and each tile into four 4x4 blocks in Z order.
+// Each block is a 64-bit word stored little-endian,
+// the reverse of the usual ETC1 byte order.
+// In the ETC1A4 variant each block is preceded by 64 bits of alpha,
+// 4 bits per pixel, in column-major order.
+package etc1
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+)
+
+var le = binary.LittleEndian
+
+var (
+	ErrSize  = errors.New("etc1: image size is not a multiple of 8")
+	ErrShort = errors.New("etc1: not enough data")
+)
+
+var modifiers = [8][2]int{
+	{2, 8},
+	{5, 17},
+	{9, 29},
+	{13, 42},
+	{18, 60},
+	{24, 80},
+	{33, 106},
+	{47, 183},
+}
+
+// BlockSize returns the size in bytes of an encoded 4x4 block.
+func BlockSize(alpha bool) int {
+	if alpha {
+		return 16
+	}
+	return 8
+}
+
+// DataSize returns the size in bytes of an encoded w×h texture.
+func DataSize(w, h int, alpha bool) int {
+	return w * h / 16 * BlockSize(alpha)
+}
+
+// Decode decodes a w×h texture.
+// The width and height must be multiples of 8.
+func Decode(data []byte, w, h int, alpha bool) (*image.NRGBA, error) {
+	if w%8 != 0 || h%8 != 0 {
+		return nil, ErrSize
+	}
+	if len(data) < DataSize(w, h, alpha) {
+		return nil, ErrShort
+	}
+	m := image.NewNRGBA(image.Rect(0, 0, w, h))
+	off := 0
+	for y := 0; y < h; y += 8 {
+		for x := 0; x < w; x += 8 {
+			for b := 0; b < 4; b++ {
+				bx := x + b%2*4
+				by := y + b/2*4
+				a := ^uint64(0)
+				p := data[off:]
+				if alpha {
+					a = le.Uint64(p)
+					p = p[8:]
+				}
+				block := DecodeBlock(le.Uint64(p))
+				for i, c := range block {
+					v := uint8(a >> uint(i*4) & 0xF)
+					c.A = v<<4 | v
+					m.SetNRGBA(bx+i/4, by+i%4, c)
+				}
+				off += BlockSize(alpha)
+			}
+		}
+	}
+	return m, nil
+}
+
+// DecodeBlock decodes an ETC1 block into 16 opaque pixels in column-major order.
+func DecodeBlock(v uint64) [16]color.NRGBA {
+	var out [16]color.NRGBA
+	hi := uint32(v >> 32)
+	lo := uint32(v)
+
+	var base [2][3]int
+	if hi&2 != 0 {
+		// differential mode
+		for c := 0; c < 3; c++ {
+			shift := uint(27 - c*8)
+			b := int(hi >> shift & 31)
+			d := int(int8(hi>>(shift-3)&7<<5) >> 5) // sign extend
+			base[0][c] = expand5(b)
+			base[1][c] = expand5((b + d) & 31)
+		}
+	} else {
+		// individual mode
+		for c := 0; c < 3; c++ {
+			shift := uint(28 - c*8)
+			base[0][c] = expand4(int(hi >> shift & 15))
+			base[1][c] = expand4(int(hi >> (shift - 4) & 15))
+		}
+	}
+	table := [2]int{int(hi >> 5 & 7), int(hi >> 2 & 7)}
+	flip := hi&1 != 0
+
+	for i := 0; i < 16; i++ {
+		sub := subblock(i, flip)
+		mod := modifiers[table[sub]][lo>>uint(i)&1]
+		if lo>>uint(i+16)&1 != 0 {
+			mod = -mod
+		}
+		out[i] = color.NRGBA{
+			R: clamp(base[sub][0] + mod),
+			G: clamp(base[sub][1] + mod),
+			B: clamp(base[sub][2] + mod),
+			A: 0xFF,
+		}
+	}
+	return out
+}
+
+// subblock returns which half of a block the i'th pixel belongs to.
+func subblock(i int, flip bool) int {
+	x, y := i/4, i%4
+	if flip && y >= 2 || !flip && x >= 2 {
+		return 1
+	}
+	return 0
+}
+
+func expand5(v int) int { return v<<3 | v>>2 }
+func expand4(v int) int { return v<<4 | v }
+
+func clamp(v int) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
@@ -0,0 +1,486 @@
+// Usage: importdb [-diff] [-code code.bin] database version romfs [moves|items|machines|learnsets]...
+// Import moves, items, machines, and level-up learnsets into veekun's database.
+// The romfs can be a directory or a RomFS image;
+// the TM list is read from the decompressed code.bin.
+// With no tables given, everything is imported.
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"xy/names"
+	"xy/stats"
+	"xy/util"
+	"xy/veekun"
+)
+
+// games maps version group identifiers to games.
+var games = map[string]names.Version{
+	"x-y":                       names.XY,
+	"omega-ruby-alpha-sapphire": names.ORAS,
+}
+
+var (
+	gen     int
+	pair    int
+	version int
+)
+
+func die(v ...interface{}) {
+	fmt.Fprintln(os.Stderr, v...)
+	os.Exit(1)
+}
+
+func main() {
+	showDiff := flag.Bool("diff", false, "list the rows added, removed, and changed")
+	codename := flag.String("code", "", "read the TM list from the decompressed `code.bin`")
+	flag.Parse()
+	if flag.NArg() < 3 {
+		fmt.Fprintln(os.Stderr, "usage: importdb [-diff] [-code code.bin] database version romfs [moves|items|machines|learnsets]...")
+		fmt.Fprintln(os.Stderr, "database is a Postgres database name or URL, an SQLite file, or dry-run:database")
+		os.Exit(1)
+	}
+	dbname := flag.Arg(0)
+	versionname := flag.Arg(1)
+	tables := flag.Args()[3:]
+	if len(tables) == 0 {
+		tables = []string{"moves", "items", "machines", "learnsets"}
+		if *codename == "" {
+			fmt.Fprintln(os.Stderr, "no code.bin given; skipping machines")
+			tables = []string{"moves", "items", "learnsets"}
+		}
+	}
+
+	romfs, err := util.OpenFS(flag.Arg(2))
+	if err != nil {
+		die(err)
+	}
+
+	db, err := veekun.Open(dbname)
+	if err != nil {
+		die(err)
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		die(err)
+	}
+	defer tx.Rollback()
+
+	var pairname string
+	err = tx.QueryRow(
+		`SELECT v.id, vg.id, vg.identifier, g.id
+		FROM versions v
+		JOIN version_groups vg on v.version_group_id = vg.id
+		JOIN generations g on vg.generation_id = g.id
+		WHERE v.identifier = $1`,
+		versionname).Scan(&version, &pair, &pairname, &gen)
+	if err != nil {
+		die(err)
+	}
+	v, ok := games[pairname]
+	if !ok {
+		die("unsupported version group:", pairname)
+	}
+	game := stats.GameFiles[v]
+
+	for _, table := range tables {
+		var diffs []*veekun.Diff
+		switch table {
+		case "moves":
+			diffs, err = importMoves(tx, romfs, game.Moves)
+		case "items":
+			diffs, err = importItems(tx, romfs, game.Items)
+		case "machines":
+			if *codename == "" {
+				die("machines: no code.bin given")
+			}
+			diffs, err = importMachines(tx, *codename, game.HMs)
+		case "learnsets":
+			diffs, err = importLearnsets(tx, romfs, game.Learnsets)
+		default:
+			die("unknown table:", table)
+		}
+		if *showDiff {
+			for _, d := range diffs {
+				d.WriteTo(os.Stdout)
+			}
+		}
+		if err != nil {
+			die(table+":", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		die(err)
+	}
+}
+
+var (
+	newMoveTable = &veekun.Table{
+		Name:    "moves",
+		Key:     []string{"id"},
+		Columns: []string{"identifier", "generation_id", "type_id", "target_id", "damage_class_id", "effect_id"},
+	}
+	moveTable = &veekun.Table{
+		Name:    "moves",
+		Key:     []string{"id"},
+		Columns: []string{"type_id", "power", "pp", "accuracy", "priority", "damage_class_id"},
+	}
+	moveMetaTable = &veekun.Table{
+		Name: "move_meta",
+		Key:  []string{"move_id"},
+		Columns: []string{"meta_category_id", "meta_ailment_id", "min_hits", "max_hits", "min_turns", "max_turns",
+			"drain", "healing", "crit_rate", "ailment_chance", "flinch_chance", "stat_chance"},
+	}
+	moveFlagTable = &veekun.Table{
+		Name: "move_flag_map",
+		Key:  []string{"move_id", "move_flag_id"},
+	}
+	itemTable = &veekun.Table{
+		Name:    "items",
+		Key:     []string{"id"},
+		Columns: []string{"cost", "fling_power"},
+	}
+	itemFlagTable = &veekun.Table{
+		Name: "item_flag_map",
+		Key:  []string{"item_id", "item_flag_id"},
+	}
+	machineTable = &veekun.Table{
+		Name:    "machines",
+		Key:     []string{"machine_number", "version_group_id"},
+		Columns: []string{"item_id", "move_id"},
+	}
+	learnsetTable = &veekun.Table{
+		Name:    "pokemon_moves",
+		Key:     []string{"pokemon_id", "version_group_id", "move_id", "pokemon_move_method_id", "level"},
+		Columns: []string{`"order"`},
+	}
+)
+
+// moveFlags maps the names in stats.MoveFlagNames
+// to veekun's move flag identifiers where they differ.
+var moveFlags = map[string]string{
+	"heals": "heal",
+}
+
+// lookup returns the id found by a query.
+func lookup(tx veekun.Tx, query string, args ...interface{}) (int, error) {
+	var id int
+	err := tx.QueryRow(query, args...).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %v", args, err)
+	}
+	return id, nil
+}
+
+// lookupAll returns the pairs of ids returned by a query as a map.
+func lookupAll(tx veekun.Tx, query string, args ...interface{}) (map[int]int, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	m := make(map[int]int)
+	for rows.Next() {
+		var k, v int
+		if err := rows.Scan(&k, &v); err != nil {
+			return nil, err
+		}
+		m[k] = v
+	}
+	return m, rows.Err()
+}
+
+// nullable returns nil for zero values,
+// which veekun stores as NULL.
+func nullable(v int) interface{} {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+func importMoves(tx veekun.Tx, romfs fs.FS, name string) ([]*veekun.Diff, error) {
+	g, err := util.OpenGARCFS(romfs, name)
+	if err != nil {
+		return nil, err
+	}
+	defer g.Close()
+	moves, err := stats.ReadMoveFiles(g.Files)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	typeIDs, err := lookupAll(tx, `SELECT game_index, type_id FROM type_game_indices WHERE generation_id = $1`, gen)
+	if err != nil {
+		return nil, err
+	}
+	have, err := lookupAll(tx, `SELECT id, id FROM moves`)
+	if err != nil {
+		return nil, err
+	}
+
+	var newMoves, records, meta, flags []veekun.Record
+	for i := 1; i < len(moves); i++ {
+		m := &moves[i]
+		typeID, ok := typeIDs[int(m.Type)]
+		if !ok {
+			return nil, fmt.Errorf("move %d: unknown type %d", i, m.Type)
+		}
+		damageClass := int(m.DamageClassCode) + 1
+		if _, ok := have[i]; !ok {
+			// The targets and effects have no known mapping,
+			// so new moves get placeholders: selected-pokemon and regular damage.
+			newMoves = append(newMoves, veekun.Record{
+				i, names.Ident(names.Move(i)), gen, typeID, 10, damageClass, 1,
+			})
+		}
+
+		// Power 1 means the power varies, and accuracy 101 never misses.
+		power := int(m.Power)
+		if power == 1 {
+			power = 0
+		}
+		accuracy := int(m.Accuracy)
+		if accuracy == 101 {
+			accuracy = 0
+		}
+		records = append(records, veekun.Record{
+			i, typeID, nullable(power), m.PP, nullable(accuracy), m.Priority, damageClass,
+		})
+
+		var minHits, maxHits, minTurns, maxTurns interface{}
+		if m.MultiHit != 0 {
+			minHits, maxHits = int(m.MultiHit&0xf), int(m.MultiHit>>4)
+		}
+		if m.EffectMinTurns != 0 {
+			minTurns, maxTurns = m.EffectMinTurns, m.EffectMaxTurns
+		}
+		meta = append(meta, veekun.Record{
+			i, m.Category, m.StatusCode, minHits, maxHits, minTurns, maxTurns,
+			m.Recoil, m.Heal, m.Crit, m.StatusChance, m.Flinch, m.StatChance[0],
+		})
+	}
+
+	var diffs []*veekun.Diff
+	sync := func(what string, d *veekun.Diff, err error) error {
+		if err != nil {
+			return fmt.Errorf("%s: %v", what, err)
+		}
+		diffs = append(diffs, d)
+		return nil
+	}
+
+	d, err := veekun.Sync(tx, newMoveTable, "", nil, newMoves)
+	if err := sync("adding moves", d, err); err != nil {
+		return diffs, err
+	}
+	d, err = veekun.Sync(tx, moveTable, "", nil, records)
+	if err := sync("updating moves", d, err); err != nil {
+		return diffs, err
+	}
+	d, err = veekun.Sync(tx, moveMetaTable, "", nil, meta)
+	if err := sync("updating move meta", d, err); err != nil {
+		return diffs, err
+	}
+
+	// Only the flags the game has are replaced;
+	// veekun has others, like bite and pulse, which are left alone.
+	var flagIDs []string
+	for bit, name := range stats.MoveFlagNames {
+		if name == "" {
+			continue
+		}
+		if s, ok := moveFlags[name]; ok {
+			name = s
+		}
+		flagID, err := lookup(tx, `SELECT id FROM move_flags WHERE identifier = $1`, name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "skipping move flag", name)
+			continue
+		}
+		flagIDs = append(flagIDs, fmt.Sprint(flagID))
+		for i := 1; i < len(moves); i++ {
+			if moves[i].Flags&(1<<uint(bit)) != 0 {
+				flags = append(flags, veekun.Record{i, flagID})
+			}
+		}
+	}
+	if len(flagIDs) > 0 {
+		where := "move_flag_id IN (" + strings.Join(flagIDs, ", ") + ")"
+		d, err = veekun.Sync(tx, moveFlagTable, where, nil, flags)
+		if err := sync("updating move flags", d, err); err != nil {
+			return diffs, err
+		}
+	}
+	return diffs, nil
+}
+
+func importItems(tx veekun.Tx, romfs fs.FS, name string) ([]*veekun.Diff, error) {
+	g, err := util.OpenGARCFS(romfs, name)
+	if err != nil {
+		return nil, err
+	}
+	defer g.Close()
+
+	itemIDs, err := lookupAll(tx, `SELECT game_index, item_id FROM item_game_indices WHERE generation_id = $1`, gen)
+	if err != nil {
+		return nil, err
+	}
+
+	countable, err := lookup(tx, `SELECT id FROM item_flags WHERE identifier = $1`, "countable")
+	if err != nil {
+		return nil, err
+	}
+	usableInBattle, err := lookup(tx, `SELECT id FROM item_flags WHERE identifier = $1`, "usable-in-battle")
+	if err != nil {
+		return nil, err
+	}
+
+	// Items without a game index can't be added,
+	// since their categories aren't in the item data.
+	var records, flags []veekun.Record
+	for i, f := range g.Files {
+		itemID, ok := itemIDs[i]
+		if !ok {
+			continue
+		}
+		var item stats.ItemStats
+		if err := binary.Read(f, binary.LittleEndian, &item); err != nil {
+			return nil, fmt.Errorf("item %d: %v", i, err)
+		}
+		records = append(records, veekun.Record{itemID, item.Price(), nullable(int(item.FlingPower))})
+
+		// Key items and machines are the ones without a count.
+		// Berries share the machine flag, but have a natural gift.
+		bits := item.Flags()
+		machine := bits&stats.ItemMachineOrBerry != 0 && item.NaturalGiftPower == 0
+		if bits&stats.ItemKey == 0 && !machine {
+			flags = append(flags, veekun.Record{itemID, countable})
+		}
+		if bits&(stats.ItemBall|stats.ItemBattle|stats.ItemRestoresHP|stats.ItemRestoresStatus) != 0 {
+			flags = append(flags, veekun.Record{itemID, usableInBattle})
+		}
+	}
+
+	var diffs []*veekun.Diff
+	d, err := veekun.Sync(tx, itemTable, "", nil, records)
+	if err != nil {
+		return nil, fmt.Errorf("updating items: %v", err)
+	}
+	diffs = append(diffs, d)
+
+	// Only the flags the item data determines are replaced,
+	// and only for this generation's items.
+	// Flags like usable-overworld and holdable are left alone.
+	where := fmt.Sprintf("item_flag_id IN (%d, %d)", countable, usableInBattle) +
+		" AND item_id IN (SELECT item_id FROM item_game_indices WHERE generation_id = $1)"
+	d, err = veekun.Sync(tx, itemFlagTable, where, []interface{}{gen}, flags)
+	if err != nil {
+		return diffs, fmt.Errorf("updating item flags: %v", err)
+	}
+	return append(diffs, d), nil
+}
+
+func importMachines(tx veekun.Tx, codename string, hms int) ([]*veekun.Diff, error) {
+	code, err := ioutil.ReadFile(codename)
+	if err != nil {
+		return nil, err
+	}
+	m, err := stats.FindMachines(code, hms)
+	if err != nil {
+		return nil, err
+	}
+
+	itemIDs, err := lookupAll(tx, `SELECT game_index, item_id FROM item_game_indices WHERE generation_id = $1`, gen)
+	if err != nil {
+		return nil, err
+	}
+
+	// HMs are numbered from 101.
+	var records []veekun.Record
+	add := func(number int, hm bool, move uint16) error {
+		index := stats.MachineItem(number, hm)
+		itemID, ok := itemIDs[index]
+		if !ok {
+			return fmt.Errorf("machine %d: no item with game index %d", number, index)
+		}
+		if hm {
+			number += 100
+		}
+		records = append(records, veekun.Record{number, pair, itemID, move})
+		return nil
+	}
+	for i, move := range m.TM {
+		if err := add(i+1, false, move); err != nil {
+			return nil, err
+		}
+	}
+	for i, move := range m.HM {
+		if err := add(i+1, true, move); err != nil {
+			return nil, err
+		}
+	}
+	d, err := veekun.Sync(tx, machineTable, "version_group_id = $1", []interface{}{pair}, records)
+	if err != nil {
+		return nil, err
+	}
+	return []*veekun.Diff{d}, nil
+}
+
+func importLearnsets(tx veekun.Tx, romfs fs.FS, name string) ([]*veekun.Diff, error) {
+	const levelUp = 1 // pokemon_move_methods
+	const numSpecies = 721
+
+	g, err := util.OpenGARCFS(romfs, name)
+	if err != nil {
+		return nil, err
+	}
+	defer g.Close()
+
+	// Alternate forms are found by the game index addpokemon gave them.
+	pokemonIDs, err := lookupAll(tx, `SELECT game_index, pokemon_id FROM pokemon_game_indices WHERE version_id = $1`, version)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []veekun.Record
+	for i, f := range g.Files {
+		if i == 0 {
+			continue
+		}
+		pokemonID, ok := pokemonIDs[i]
+		if !ok {
+			if i > numSpecies {
+				fmt.Fprintf(os.Stderr, "skipping learnset %d: no pokemon with that game index\n", i)
+				continue
+			}
+			pokemonID = i
+		}
+		moves, err := stats.ReadLearnset(f)
+		if err != nil {
+			return nil, fmt.Errorf("learnset %d: %v", i, err)
+		}
+		seen := make(map[stats.LevelMove]bool)
+		for j, m := range moves {
+			if seen[m] {
+				continue
+			}
+			seen[m] = true
+			records = append(records, veekun.Record{pokemonID, pair, m.Move, levelUp, m.Level, j + 1})
+		}
+	}
+	d, err := veekun.Sync(tx, learnsetTable, "version_group_id = $1 AND pokemon_move_method_id = $2",
+		[]interface{}{pair, levelUp}, records)
+	if err != nil {
+		return nil, err
+	}
+	return []*veekun.Diff{d}, nil
+}
@@ -0,0 +1,113 @@
+package lz
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	maxSize  = 0xFFFFFF
+	minMatch = 3
+	maxMatch = 0x10110
+	maxDist  = 0x1000
+	hashBits = 14
+	maxChain = 256
+)
+
+var ErrTooBig = errors.New("lz: data too large to compress")
+
+// Encode compresses data using the LZ11 variant of the algorithm
+// and writes it to w.
+func Encode(w io.Writer, data []byte) error {
+	b, err := Compress11(data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// Compress11 returns the LZ11 compression of data.
+func Compress11(data []byte) ([]byte, error) {
+	if len(data) > maxSize {
+		return nil, ErrTooBig
+	}
+	out := make([]byte, 4, 4+len(data)+len(data)/8+1)
+	out[0] = 0x11
+	out[1] = byte(len(data))
+	out[2] = byte(len(data) >> 8)
+	out[3] = byte(len(data) >> 16)
+
+	var (
+		head = make([]int, 1<<hashBits)
+		prev = make([]int, len(data))
+	)
+	for i := range head {
+		head[i] = -1
+	}
+	insert := func(i int) {
+		if i+minMatch > len(data) {
+			return
+		}
+		h := hash(data[i:])
+		prev[i] = head[h]
+		head[h] = i
+	}
+
+	flagPos := 0
+	bit := 0
+	for i := 0; i < len(data); {
+		if bit == 0 {
+			flagPos = len(out)
+			out = append(out, 0)
+			bit = 8
+		}
+		bit--
+
+		count, dist := 0, 0
+		if i+minMatch <= len(data) {
+			for j, n := head[hash(data[i:])], 0; j >= 0 && i-j <= maxDist && n < maxChain; j, n = prev[j], n+1 {
+				k := 0
+				for k < maxMatch && i+k < len(data) && data[j+k] == data[i+k] {
+					k++
+				}
+				if k > count {
+					count, dist = k, i-j
+					if k == maxMatch {
+						break
+					}
+				}
+			}
+		}
+
+		if count < minMatch {
+			out = append(out, data[i])
+			insert(i)
+			i++
+			continue
+		}
+
+		out[flagPos] |= 1 << uint(bit)
+		d := dist - 1
+		switch {
+		case count <= 0x10:
+			out = append(out, byte((count-1)<<4|d>>8), byte(d))
+		case count <= 0x110:
+			c := count - 0x11
+			out = append(out, byte(c>>4), byte(c<<4|d>>8), byte(d))
+		default:
+			c := count - 0x111
+			out = append(out, byte(0x10|c>>12), byte(c>>4), byte(c<<4|d>>8), byte(d))
+		}
+		for k := 0; k < count; k++ {
+			insert(i + k)
+		}
+		i += count
+	}
+	return out, nil
+}
+
+func hash(b []byte) int {
+	h := uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+	return int((h * 2654435761) >> (32 - hashBits))
+}
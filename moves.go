@@ -1,59 +1,84 @@
+// Usage: moves [-format text|json|csv|yaml] romfs/a/2/1/4
+// Print the level-up moves of every Pokémon.
 package main
 
-import "garc"
 import (
-	"bufio"
-	"encoding/binary"
+	"flag"
 	"fmt"
-	"io"
 	"os"
+
+	"xy/export"
+	"xy/garc"
+	"xy/stats"
 )
 
-var littleEndian = binary.LittleEndian
+var format = flag.String("format", "text", "output `format`: text, json, csv, or yaml")
+
+func die(v ...interface{}) {
+	fmt.Fprintln(os.Stderr, v...)
+	os.Exit(1)
+}
+
+func moveName(move uint16) string {
+	if name := movenames[int16(move)]; name != "" {
+		return name
+	}
+	return fmt.Sprint(move)
+}
 
 func main() {
-	f, err := os.Open(os.Args[1]) // a/2/1/4
+	flag.Parse()
+	if flag.NArg() < 1 {
+		die("usage: moves [-format text|json|csv|yaml] romfs/a/2/1/4")
+	}
+	if *format != "text" && !export.IsFormat(*format) {
+		die("unknown format:", *format)
+	}
+	f, err := os.Open(flag.Arg(0)) // a/2/1/4
 	if err != nil {
-		fmt.Println(err)
-		return
+		die(err)
 	}
 	defer f.Close()
 	files, err := garc.Files(f)
 	if err != nil {
-		fmt.Println(err)
-		return
+		die(err)
 	}
-	br := bufio.NewReader(nil)
+	var table []export.Record
 	for i, f := range files {
-		br.Reset(f)
+		moves, err := stats.ReadLearnset(f)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, i, err)
+		}
+		if *format != "text" {
+			list := []export.Record{}
+			for _, m := range moves {
+				var r export.Record
+				r.Add("level", m.Level)
+				r.Add("move", moveName(m.Move))
+				list = append(list, r)
+			}
+			var r export.Record
+			r.Add("index", i)
+			r.Add("pokemon", pokemonnames[i])
+			r.Add("moves", list)
+			table = append(table, r)
+			continue
+		}
 		if name := pokemonnames[i]; name != "" {
 			fmt.Print(i, "-", name, "\n")
 		} else {
 			fmt.Print(i, "\n")
 		}
-		for {
-			var b [4]byte
-			_, err := br.Read(b[:])
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				fmt.Println(err)
-				continue
-			}
-			move := int16(littleEndian.Uint16(b[0:2]))
-			level := int16(littleEndian.Uint16(b[2:4]))
-			if move == -1 {
-				break
-			}
-			if name := movenames[move]; name != "" {
-				fmt.Print("    ", level, "-", name, "\n")
-			} else {
-				fmt.Print("    ", level, "-", move, "\n")
-			}
+		for _, m := range moves {
+			fmt.Print("    ", m.Level, "-", moveName(m.Move), "\n")
 		}
 		fmt.Print("\n")
 	}
+	if *format != "text" {
+		if err := export.Write(os.Stdout, *format, table); err != nil {
+			die(err)
+		}
+	}
 }
 
 var movenames = map[int16]string{
@@ -0,0 +1,226 @@
+package names
+
+import (
+	"fmt"
+	"io/fs"
+	"unicode/utf16"
+
+	"xy/text"
+	"xy/util"
+)
+
+// A Version identifies the games a romfs belongs to.
+type Version int
+
+const (
+	XY   Version = iota // Pokémon X and Y
+	ORAS                // Pokémon Omega Ruby and Alpha Sapphire
+)
+
+// Languages lists the languages of the games' message archives, in order.
+var Languages = []string{"ja-kana", "ja-kanji", "en", "fr", "it", "de", "es", "ko"}
+
+// messageFiles gives the location of the name lists in the game text.
+type messageFiles struct {
+	archive int // archive number of the first language, e.g. 72 for a/0/7/2
+	story   int // archive number of the first language's story text
+
+	species        int
+	moves          int
+	items          int
+	abilities      int
+	types          int
+	locations      int
+	trainerClasses int
+	trainerNames   int
+}
+
+var gameText = map[Version]messageFiles{
+	XY: {
+		archive:        72,
+		story:          80,
+		species:        80,
+		moves:          13,
+		items:          96,
+		abilities:      34,
+		types:          17,
+		locations:      72,
+		trainerClasses: 20,
+		trainerNames:   21,
+	},
+	ORAS: {
+		archive:        71,
+		story:          79,
+		species:        98,
+		moves:          14,
+		items:          114,
+		abilities:      37,
+		types:          18,
+		locations:      90,
+		trainerClasses: 21,
+		trainerNames:   22,
+	},
+}
+
+// A Catalog holds the names used by the game text in one language.
+//
+// The methods of a nil Catalog, or of a Catalog missing a name,
+// fall back to the package-level functions.
+type Catalog struct {
+	Lang string
+
+	species        []string
+	moves          []string
+	items          []string
+	abilities      []string
+	types          []string
+	locations      []string
+	trainerClasses []string
+	trainerNames   []string
+}
+
+// Load reads the names for the given language from a romfs.
+func Load(romfs fs.FS, v Version, lang string) (*Catalog, error) {
+	mf, li, err := messages(v, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	name := archivePath(mf.archive + li)
+	g, err := util.OpenGARCFS(romfs, name)
+	if err != nil {
+		return nil, err
+	}
+	defer g.Close()
+
+	c := &Catalog{Lang: lang}
+	for _, x := range []struct {
+		list *[]string
+		n    int
+	}{
+		{&c.species, mf.species},
+		{&c.moves, mf.moves},
+		{&c.items, mf.items},
+		{&c.abilities, mf.abilities},
+		{&c.types, mf.types},
+		{&c.locations, mf.locations},
+		{&c.trainerClasses, mf.trainerClasses},
+		{&c.trainerNames, mf.trainerNames},
+	} {
+		if x.n >= len(g.Files) {
+			return nil, fmt.Errorf("names: %s: no file %d", name, x.n)
+		}
+		lines, err := text.ReadRaw(g.Files[x.n])
+		if err != nil {
+			return nil, fmt.Errorf("names: %s: file %d: %v", name, x.n, err)
+		}
+		*x.list = make([]string, len(lines))
+		for i, s := range lines {
+			(*x.list)[i] = decodeName(s)
+		}
+	}
+	return c, nil
+}
+
+// TextArchives returns the romfs paths of the game text in a language:
+// the common messages, which hold the name lists, and the story text.
+func TextArchives(v Version, lang string) (common, story string, err error) {
+	mf, li, err := messages(v, lang)
+	if err != nil {
+		return "", "", err
+	}
+	return archivePath(mf.archive + li), archivePath(mf.story + li), nil
+}
+
+func messages(v Version, lang string) (messageFiles, int, error) {
+	mf, ok := gameText[v]
+	if !ok {
+		return mf, 0, fmt.Errorf("names: unknown version %d", v)
+	}
+	for i, l := range Languages {
+		if l == lang {
+			return mf, i, nil
+		}
+	}
+	return mf, 0, fmt.Errorf("names: unknown language %q", lang)
+}
+
+// archivePath returns the romfs path of the numbered archive,
+// e.g. a/0/7/4 for 74.
+func archivePath(n int) string {
+	return fmt.Sprintf("a/%d/%d/%d", n/100, n/10%10, n%10)
+}
+
+// decodeName converts a line of game text to a string.
+// Lines containing control codes are escaped.
+func decodeName(s []uint16) string {
+	for _, c := range s {
+		if c == 0x10 {
+			return text.Escape(s)
+		}
+	}
+	return string(utf16.Decode(s))
+}
+
+func lookup(list []string, n int, fallback func(int) string) string {
+	if 0 <= n && n < len(list) && list[n] != "" {
+		return list[n]
+	}
+	return fallback(n)
+}
+
+func (c *Catalog) Species(n int) string {
+	if c == nil || n == 0 {
+		return Species(n)
+	}
+	return lookup(c.species, n, Species)
+}
+
+func (c *Catalog) Move(n int) string {
+	if c == nil || n == 0 {
+		return Move(n)
+	}
+	return lookup(c.moves, n, Move)
+}
+
+func (c *Catalog) Item(n int) string {
+	if c == nil || n == 0 {
+		return Item(n)
+	}
+	return lookup(c.items, n, Item)
+}
+
+func (c *Catalog) Ability(n int) string {
+	if c == nil || n == 0 {
+		return Ability(n)
+	}
+	return lookup(c.abilities, n, Ability)
+}
+
+func (c *Catalog) Type(n int) string {
+	if c == nil {
+		return Type(n)
+	}
+	return lookup(c.types, n, Type)
+}
+
+func (c *Catalog) Location(n int) string {
+	if c == nil || n == 0 {
+		return Location(n)
+	}
+	return lookup(c.locations, n, Location)
+}
+
+func (c *Catalog) TrainerClass(n int) string {
+	if c == nil {
+		return TrainerClass(n)
+	}
+	return lookup(c.trainerClasses, n, TrainerClass)
+}
+
+func (c *Catalog) TrainerName(n int) string {
+	if c == nil {
+		return TrainerName(n)
+	}
+	return lookup(c.trainerNames, n, TrainerName)
+}
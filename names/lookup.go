@@ -0,0 +1,201 @@
+package names
+
+import (
+	"io/fs"
+	"strings"
+	"sync"
+)
+
+var (
+	mu       sync.RWMutex
+	catalogs = make(map[string]*Catalog)
+)
+
+// Register makes c available through In under its language.
+func Register(c *Catalog) {
+	mu.Lock()
+	defer mu.Unlock()
+	catalogs[c.Lang] = c
+}
+
+// In returns the catalog registered for lang.
+// If there is none, In returns a nil *Catalog,
+// whose methods return the built-in English names.
+func In(lang string) *Catalog {
+	mu.RLock()
+	defer mu.RUnlock()
+	return catalogs[lang]
+}
+
+// LoadAll loads and registers a catalog for every language in Languages.
+func LoadAll(romfs fs.FS, v Version) error {
+	for _, lang := range Languages {
+		c, err := Load(romfs, v, lang)
+		if err != nil {
+			return err
+		}
+		Register(c)
+	}
+	return nil
+}
+
+// Ident converts a name to an identifier in the style of veekun's database,
+// e.g. "Farfetch'd" to "farfetchd" and "Nidoran♀" to "nidoran-f".
+func Ident(s string) string {
+	s = strings.ToLower(s)
+	s = identReplacer.Replace(s)
+	s = strings.Trim(s, "-")
+	for strings.Contains(s, "--") {
+		s = strings.Replace(s, "--", "-", -1)
+	}
+	return s
+}
+
+var identReplacer = strings.NewReplacer(
+	"♀", "-f",
+	"♂", "-m",
+	" ", "-",
+	"_", "-",
+	".", "",
+	",", "",
+	"'", "",
+	"’", "",
+	":", "",
+	"à", "a", "á", "a", "â", "a", "ä", "a",
+	"è", "e", "é", "e", "ê", "e", "ë", "e",
+	"ì", "i", "í", "i", "î", "i", "ï", "i",
+	"ò", "o", "ó", "o", "ô", "o", "ö", "o",
+	"ù", "u", "ú", "u", "û", "u", "ü", "u",
+	"ç", "c",
+	"ñ", "n",
+	"œ", "oe",
+	"ß", "ss",
+)
+
+// find returns the index of the first name in list, starting at start,
+// which has the same identifier as name.
+func find(list []string, start int, name string) (int, bool) {
+	id := Ident(name)
+	if id == "" {
+		return 0, false
+	}
+	for i := start; i < len(list); i++ {
+		if Ident(list[i]) == id {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// SpeciesID returns the national dex number of the named species.
+// The match ignores case and diacritics,
+// and identifiers such as "nidoran-f" are also accepted.
+func SpeciesID(name string) (int, bool) { return find(speciesNames, 1, name) }
+
+// MoveID returns the index of the named move. See SpeciesID.
+func MoveID(name string) (int, bool) { return find(moveNames, 1, name) }
+
+// ItemID returns the index of the named item. See SpeciesID.
+func ItemID(name string) (int, bool) { return find(itemNames, 1, name) }
+
+// AbilityID returns the index of the named ability. See SpeciesID.
+func AbilityID(name string) (int, bool) { return find(abilityNames, 1, name) }
+
+// TypeID returns the index of the named type. See SpeciesID.
+func TypeID(name string) (int, bool) { return find(typeNames, 0, name) }
+
+// SpeciesID is like the package-level SpeciesID
+// but also matches the names in c.
+func (c *Catalog) SpeciesID(name string) (int, bool) {
+	if c != nil {
+		if n, ok := find(c.species, 1, name); ok {
+			return n, true
+		}
+	}
+	return SpeciesID(name)
+}
+
+func (c *Catalog) MoveID(name string) (int, bool) {
+	if c != nil {
+		if n, ok := find(c.moves, 1, name); ok {
+			return n, true
+		}
+	}
+	return MoveID(name)
+}
+
+func (c *Catalog) ItemID(name string) (int, bool) {
+	if c != nil {
+		if n, ok := find(c.items, 1, name); ok {
+			return n, true
+		}
+	}
+	return ItemID(name)
+}
+
+func (c *Catalog) AbilityID(name string) (int, bool) {
+	if c != nil {
+		if n, ok := find(c.abilities, 1, name); ok {
+			return n, true
+		}
+	}
+	return AbilityID(name)
+}
+
+func (c *Catalog) TypeID(name string) (int, bool) {
+	if c != nil {
+		if n, ok := find(c.types, 0, name); ok {
+			return n, true
+		}
+	}
+	return TypeID(name)
+}
+
+// SpeciesCount returns the number of species numbers in c, counting 0,
+// so that valid numbers are less than it.
+// A nil Catalog, or one without names, counts the package-level names.
+func (c *Catalog) SpeciesCount() int {
+	if c == nil {
+		return count(nil, speciesNames)
+	}
+	return count(c.species, speciesNames)
+}
+
+// MoveCount returns the number of move numbers in c. See SpeciesCount.
+func (c *Catalog) MoveCount() int {
+	if c == nil {
+		return count(nil, moveNames)
+	}
+	return count(c.moves, moveNames)
+}
+
+// ItemCount returns the number of item numbers in c. See SpeciesCount.
+func (c *Catalog) ItemCount() int {
+	if c == nil {
+		return count(nil, itemNames)
+	}
+	return count(c.items, itemNames)
+}
+
+// AbilityCount returns the number of ability numbers in c. See SpeciesCount.
+func (c *Catalog) AbilityCount() int {
+	if c == nil {
+		return count(nil, abilityNames)
+	}
+	return count(c.abilities, abilityNames)
+}
+
+// TypeCount returns the number of type numbers in c. See SpeciesCount.
+func (c *Catalog) TypeCount() int {
+	if c == nil {
+		return count(nil, typeNames)
+	}
+	return count(c.types, typeNames)
+}
+
+func count(list, fallback []string) int {
+	if len(list) > 0 {
+		return len(list)
+	}
+	return len(fallback)
+}
@@ -0,0 +1,176 @@
+// Package pages renders the HTML tables of base stats, moves, and items
+// written by the pokemon-stats, move-stats, and item-stats tools
+// and served by serve.
+package pages
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"reflect"
+
+	"xy/names"
+)
+
+var (
+	errBadComparisonType = errors.New("invalid type for comparison")
+	errBadComparison     = errors.New("incompatible types for comparison")
+	errNoComparison      = errors.New("missing argument for comparison")
+)
+
+var funcs = template.FuncMap{
+	"eq": eq,
+	"ne": ne,
+
+	"item":    func(n uint16) string { return names.Item(int(n)) },
+	"ability": func(n uint8) string { return names.Ability(int(n)) },
+	"bin":     bin,
+	"flags":   flags,
+}
+
+func bin(v interface{}) (string, error) {
+	var b []byte
+	switch v := v.(type) {
+	case uint8:
+		b = formatbin(b, uint64(v), 8)
+	case uint16:
+		b = formatbin(b, uint64(v), 16)
+	case uint32:
+		b = formatbin(b, uint64(v), 32)
+	case uint64:
+		b = formatbin(b, v, 64)
+	default:
+		if v := reflect.ValueOf(v); (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() == reflect.Uint8 {
+			n := v.Len()
+			for i := 0; i < n; i++ {
+				b = formatbin(b, v.Index(i).Uint(), 8)
+			}
+			return string(b), nil
+		}
+		return "", fmt.Errorf("bad type %T", v)
+	}
+	return string(b), nil
+}
+
+func formatbin(b []byte, v uint64, n int) []byte {
+	for i := 0; i < n; i++ {
+		if v&1 == 0 {
+			b = append(b, '0')
+		} else {
+			b = append(b, '1')
+		}
+		v >>= 1
+	}
+	return b
+}
+
+func flags(v interface{}, s string) string {
+	switch v := v.(type) {
+	case uint16:
+		return formatFlags(uint32(v), s)
+	}
+	return "error"
+}
+
+func formatFlags(u uint32, s string) string {
+	var b [64]byte
+	for i := 0; i < len(s); i++ {
+		if u&1 == 0 {
+			b[i] = '-'
+		} else {
+			b[i] = s[i]
+		}
+		u = u >> 1
+	}
+	return string(b[:len(s)])
+}
+
+type kind int
+
+const (
+	invalidKind kind = iota
+	boolKind
+	complexKind
+	intKind
+	floatKind
+	integerKind
+	stringKind
+	uintKind
+)
+
+func basicKind(v reflect.Value) (kind, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		return boolKind, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intKind, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return uintKind, nil
+	case reflect.Float32, reflect.Float64:
+		return floatKind, nil
+	case reflect.Complex64, reflect.Complex128:
+		return complexKind, nil
+	case reflect.String:
+		return stringKind, nil
+	}
+	return invalidKind, errBadComparisonType
+}
+
+// eq evaluates the comparison a == b || a == c || ...
+func eq(arg1 interface{}, arg2 ...interface{}) (bool, error) {
+	v1 := reflect.ValueOf(arg1)
+	k1, err := basicKind(v1)
+	if err != nil {
+		return false, err
+	}
+	if len(arg2) == 0 {
+		return false, errNoComparison
+	}
+	for _, arg := range arg2 {
+		v2 := reflect.ValueOf(arg)
+		k2, err := basicKind(v2)
+		if err != nil {
+			return false, err
+		}
+		truth := false
+		if k1 != k2 {
+			// Special case: Can compare integer values regardless of type's sign.
+			switch {
+			case k1 == intKind && k2 == uintKind:
+				truth = v1.Int() >= 0 && uint64(v1.Int()) == v2.Uint()
+			case k1 == uintKind && k2 == intKind:
+				truth = v2.Int() >= 0 && v1.Uint() == uint64(v2.Int())
+			default:
+				return false, errBadComparison
+			}
+		} else {
+			switch k1 {
+			case boolKind:
+				truth = v1.Bool() == v2.Bool()
+			case complexKind:
+				truth = v1.Complex() == v2.Complex()
+			case floatKind:
+				truth = v1.Float() == v2.Float()
+			case intKind:
+				truth = v1.Int() == v2.Int()
+			case stringKind:
+				truth = v1.String() == v2.String()
+			case uintKind:
+				truth = v1.Uint() == v2.Uint()
+			default:
+				panic("invalid kind")
+			}
+		}
+		if truth {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ne evaluates the comparison a != b.
+func ne(arg1, arg2 interface{}) (bool, error) {
+	// != is the inverse of ==.
+	equal, err := eq(arg1, arg2)
+	return !equal, err
+}
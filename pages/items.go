@@ -0,0 +1,159 @@
+package pages
+
+import (
+	"encoding/binary"
+	"fmt"
+	"html/template"
+	"io"
+
+	"xy/export"
+	"xy/garc"
+	"xy/names"
+	"xy/stats"
+)
+
+// Item is a row of the items page.
+// Icon is the number of the item's icon, or -1 if it isn't known.
+type Item struct {
+	Index int
+	Name  string
+	Icon  int
+	stats.ItemStats
+}
+
+func (m *Item) NaturalGiftTypeName() string { return names.Type(m.NaturalGiftType()) }
+
+// Flags:
+// 	2
+// 	3 berry / tm
+// 	4 key item
+//	5 nothing
+// 	6 ball
+// 	7 battle item
+// 	8 restores HP or PP
+// 	9 restores status
+
+func (m *Item) Record() export.Record {
+	var r export.Record
+	r.Add("index", m.Index)
+	r.Add("name", m.Name)
+	return append(r, m.ItemStats.Record(nil)...)
+}
+
+// ReadItems reads the stats of every item from the items GARC.
+// The icon map, if not nil, gives the icon of each item.
+func ReadItems(files []*garc.File, iconmap []uint32) ([]Item, error) {
+	items := make([]Item, len(files))
+	for i, file := range files {
+		item := &items[i]
+		err := binary.Read(file, binary.LittleEndian, &item.ItemStats)
+		if err != nil {
+			return nil, fmt.Errorf("file %d: %v", i, err)
+		}
+		item.Index = i
+		item.Name = names.Item(i)
+		if i < len(iconmap) {
+			item.Icon = int(iconmap[i])
+		} else {
+			item.Icon = -1
+		}
+	}
+	return items, nil
+}
+
+// WriteItems writes the items page.
+// The icons are linked as items/n.png.
+func WriteItems(w io.Writer, items []Item) error {
+	return itemsTemplate.Execute(w, items)
+}
+
+var itemsTemplate = template.Must(template.New("items").Funcs(funcs).Parse(itemsText))
+
+var itemsText = `<!DOCTYPE html>
+<meta charset="utf-8">
+<title>OR/AS item struct</title>
+<style type="text/css">
+  body { font-family: sans-serif; font-size: 16px; line-height: 1em; }
+  table { border-collapse: collapse; white-space: nowrap; }
+  tbody { border: 2px solid black; }
+  tbody td, tbody th { border: 1px solid black; }
+  td, th { padding: 0.3em; }
+  td { text-align: right; }
+  td.str { text-align: left; }
+  td.list { text-align: left; }
+  td.int { text-align: right; }
+  td.hex { font-family: monospace; }
+  td.icon { padding: 0; text-align: center; }
+  td img { vertical-align: middle; }
+  tr:hover { background: #DEE6F5; }
+</style>
+
+<table>
+  <thead>
+    <tr>
+      <th>#</th>
+      <th>Name</th>
+      <th>Icon</th>
+
+      <th>Price</th>
+      <th>Effect</th>
+      <th>Arg</th>
+      <th>Ntl.Gift<br>effect</th>
+      <th>Fling<br>effect</th>
+      <th>Fling<br>power</th>
+      <th>Ntl.Gift<br>power</th>
+      <th>Ntl.Gift<br>type</th>
+      <th>Flags</th>
+      <th>0A</th>
+      <th>0B</th>
+      <th>0C</th>
+      <th>0D</th>
+      <th>0E</th>
+      <th>Order</th>
+      <th>Status</th>
+      <th>Effort</th>
+      <th>HP</th>
+      <th>PP</th>
+      <th>Friendship</th>
+
+      <th>Name</th>
+      <th>#</th>
+    </tr>
+  </thead>
+
+  <tbody>
+    {{range .}}
+      <tr>
+        <th>{{.Index}}</th>
+        <th class=str>{{.Name}}</th>
+        <td class=icon>{{if ne .Icon -1}}<img src="items/{{.Icon}}.png">{{end}}</td>
+
+        <td>{{.Price}}</td>
+        <td>{{.Effect}}</td>
+        <td>{{.EffectArg}}</td>
+        <td>{{.NaturalGiftEffect}}</td>
+        <td>{{.FlingEffect}}</td>
+        <td>{{.FlingPower}}</td>
+        <td>{{.NaturalGiftPower}}</td>
+        <td class=str>{{if ne .NaturalGiftType 31}}{{.NaturalGiftTypeName}}{{end}}</td>
+        <td class=hex>{{flags .Flags "012mk%bths%"}}</td>
+        <td class=hex>{{printf "%x" .Unknown0A}}</td>
+        <td>{{.Unknown0B}}</td>
+        <td>{{.Unknown0C}}</td>
+        <td>{{.Unknown0D}}</td>
+        <td>{{.Unknown0E}}</td>
+        <td>{{.Order}}</td>
+        <td class=hex>{{printf "%014x" .Status}}</td>
+        <td class=list>{{.Effort}}</td>
+        <td>{{.HP}}</td>
+        <td>{{.PP}}</td>
+        <td class=list>{{.Friendship}}</td>
+
+        <th class=str>{{.Name}}</th>
+        <th>{{.Index}}</th>
+      </tr>
+    {{end}}
+  </tbody>
+</table>
+
+`
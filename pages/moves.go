@@ -0,0 +1,222 @@
+package pages
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+
+	"xy/export"
+	"xy/garc"
+	"xy/names"
+	"xy/stats"
+)
+
+// Move is a row of the moves page.
+type Move struct {
+	Index int
+	Name  string
+	stats.MoveStats
+}
+
+func (m *Move) MultiHitMin() int { return int(m.MultiHit & 0xf) }
+func (m *Move) MultiHitMax() int { return int(m.MultiHit >> 4) }
+func (m *Move) IsMultiHit() bool { return m.MultiHit == 0 }
+
+func (m *Move) TypeName() string { return names.Type(int(m.Type)) }
+
+func (m *Move) DamageClass() string {
+	switch m.DamageClassCode {
+	case 0:
+		return "status"
+	case 1:
+		return "physical"
+	case 2:
+		return "special"
+	}
+	return "unknown"
+}
+
+func (m *Move) Status() string {
+	switch m.StatusCode {
+	case 0:
+		return ""
+	case 1:
+		return "paralyze"
+	case 2:
+		return "sleep"
+	case 3:
+		return "freeze"
+	case 4:
+		return "burn"
+	case 5:
+		return "poison"
+	case 6:
+		return "confuse"
+	case 7:
+		return "infatuate"
+	case 8:
+		return "trap"
+	}
+	return fmt.Sprint(m.StatusCode)
+}
+
+func (m *Move) Record() export.Record {
+	var r export.Record
+	r.Add("index", m.Index)
+	r.Add("name", m.Name)
+	return append(r, m.MoveStats.Record(nil)...)
+}
+
+// ReadMoves reads the stats of every move from the moves GARC.
+func ReadMoves(files []*garc.File) ([]Move, error) {
+	list, err := stats.ReadMoveFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	moves := make([]Move, len(list))
+	for i := range moves {
+		moves[i] = Move{Index: i, Name: names.Move(i), MoveStats: list[i]}
+	}
+	return moves, nil
+}
+
+// WriteMoves writes the moves page,
+// which ends with the moves having each flag.
+func WriteMoves(w io.Writer, moves []Move) error {
+	type flag struct {
+		Index int
+		Name  string
+		Has   []*Move
+		Hasnt []*Move
+	}
+	flags := make([]flag, 16)
+	for i := range flags {
+		f := &flags[i]
+		f.Index = i
+		f.Name = stats.MoveFlagNames[i]
+		mask := uint32(1) << uint(i)
+		for i, m := range moves {
+			if m.Flags&mask != 0 {
+				f.Has = append(f.Has, &moves[i])
+			} else if i != 0 {
+				f.Hasnt = append(f.Hasnt, &moves[i])
+			}
+		}
+	}
+
+	context := map[string]interface{}{
+		"flags": flags,
+		"moves": moves,
+	}
+
+	return movesTemplate.Execute(w, context)
+}
+
+var movesTemplate = template.Must(template.New("moves").Funcs(funcs).Parse(movesText))
+
+var movesText = `<!DOCTYPE html>
+<meta charset="utf-8">
+<title>OR/AS moves</title>
+<style type="text/css">
+  body { font-family: sans-serif; font-size: 16px; line-height: 1em; }
+  table { border-collapse: collapse; white-space: nowrap; }
+  thead { background: #B6C8E9; }
+  tbody { border: 2px solid black; }
+  tbody td, tbody th { border: 1px solid black; }
+  td, th { padding: 0.3em; }
+  td { text-align: right; }
+  td.str { text-align: left; }
+  td.list { text-align: left; }
+  td.int { text-align: right; }
+  td.hex { font-family: monospace; }
+  tbody tr:hover { background: #DEE6F5; }
+</style>
+
+<table>
+  <thead>
+    <tr>
+      <th>#</th>
+      <th>Name</th>
+
+      <th>Type</th>
+      <th>Category</th>
+      <th>Damage Class</th>
+      <th>Power</th>
+      <th>Acc.</th>
+      <th>PP</th>
+      <th>Pri.</th>
+      <th>Hits</th>
+      <th>Status</th>
+      <th>Status<br>Chance</th>
+      <th>Status<br>Length</th>
+      <th>Status<br>Turns</th>
+      <th>Crit.</th>
+      <th>Flinch</th>
+      <th>Effect</th>
+      <th>Recoil</th>
+      <th>Heal</th>
+      <th>Target</th>
+      <th>Stat Type</th>
+      <th>Stat Stage</th>
+      <th>Stat Chance</th>
+      <th>Unknown</th>
+      <th>Flags</th>
+
+      <th>Name</th>
+      <th>#</th>
+    </tr>
+  </thead>
+
+  <tbody>
+    {{range .moves}}
+      <tr>
+        <th>{{.Index}}</th>
+        <th class=str>{{.Name}}</th>
+
+        <td class=str>{{.TypeName}}</td>
+        <td>{{.Category}}</td>
+        <td class=str>{{.DamageClass}}</td>
+        <td>{{.Power}}</td>
+        <td>{{.Accuracy}}</td>
+        <td>{{.PP}}</td>
+        <td>{{if ne .Priority 0}}{{.Priority}}{{end}}</td>
+        <td>{{if not .IsMultiHit}}{{.MultiHitMin}}-{{.MultiHitMax}}{{end}}</td>
+        <td class=str>{{.Status}}</td>
+        <td>{{if ne .StatusChance 0}}{{.StatusChance}}%{{end}}</td>
+        <td>{{if ne .EffectLength 0}}{{.EffectLength}}{{end}}</td>
+        <td>{{if ne .EffectMinTurns 0}}{{.EffectMinTurns}}-{{.EffectMaxTurns}}{{end}}</td>
+        <td>{{if ne .Crit 0 }}{{printf "%+d" .Crit}}{{end}}</td>
+        <td>{{if ne .Flinch 0}}{{.Flinch}}%{{end}}</td>
+        <td>{{.Effect}}</td>
+        <td>{{if ne .Recoil 0}}{{.Recoil}}%{{end}}</td>
+        <td>{{if ne .Heal 0}}{{.Heal}}%{{end}}</td>
+        <td>{{.Target}}</td>
+        <td class=list>{{.StatType}}</td>
+        <td class=list>{{.StatStage}}</td>
+        <td class=list>{{.StatChance}}</td>
+        <td class=hex>{{printf "% x" .Unknown1E}}</td>
+        <td class=hex>{{printf "%b" .Flags}}</td>
+
+        <th class=str>{{.Name}}</th>
+        <th>{{.Index}}</th>
+      </tr>
+    {{end}}
+  </tbody>
+</table>
+
+{{range .flags}}
+  <h1>Flag {{.Index}} {{.Name}} - {{len .Has}} moves</h1>
+  {{if and (len .Has) (lt (len .Has) 400)}}
+    <p>{{range $i, $_ := .Has}}{{if $i}}, {{end}}{{.Name}}{{end}}.</p>
+  {{end}}
+  {{if and (len .Hasnt) (lt (len .Hasnt) 400)}}
+    <p>Every move <strong>except</strong>:
+    <p>{{range $i, $_ := .Hasnt}}{{if $i}}, {{end}}{{.Name}}{{end}}.</p>
+  {{end}}
+{{end}}
+
+<script src="https://cdnjs.cloudflare.com/ajax/libs/jquery/2.1.4/jquery.js"></script>
+<script src="https://cdnjs.cloudflare.com/ajax/libs/floatthead/1.2.10/jquery.floatThead.js"></script>
+<script>$('table').floatThead();</script>
+
+`
@@ -0,0 +1,616 @@
+package pages
+
+import (
+	"encoding/binary"
+	"fmt"
+	"html/template"
+	"io"
+
+	"xy/export"
+	"xy/garc"
+	"xy/names"
+	"xy/stats"
+)
+
+// Pokemon is a row of the base stats page.
+type Pokemon struct {
+	Index    int
+	Name     string
+	FormName string
+	FullName string
+	stats.PokemonStats
+}
+
+func (p Pokemon) HP() uint8             { return p.Stat[0] }
+func (p Pokemon) Attack() uint8         { return p.Stat[1] }
+func (p Pokemon) Defense() uint8        { return p.Stat[2] }
+func (p Pokemon) Speed() uint8          { return p.Stat[3] }
+func (p Pokemon) SpecialAttack() uint8  { return p.Stat[4] }
+func (p Pokemon) SpecialDefense() uint8 { return p.Stat[5] }
+func (p Pokemon) Form() uint16          { return p.FormStats }
+func (p Pokemon) FormNameIndex() uint16 { return p.FormTotal }
+
+func (p Pokemon) TypeText() string {
+	if p.Type[0] == p.Type[1] {
+		return names.Type(int(p.Type[0]))
+	}
+	return names.Type(int(p.Type[0])) + "/" + names.Type(int(p.Type[1]))
+}
+
+func (p Pokemon) EffortText() string {
+	return fmt.Sprintf("%d/%d/%d/%d/%d/%d", p.RawEffort&3, p.RawEffort>>2&3, p.RawEffort>>4&3, p.RawEffort>>6&3, p.RawEffort>>8&3, p.RawEffort>>10&3)
+}
+
+func (p Pokemon) EggText() string {
+	if p.EggGroup[0] == p.EggGroup[1] {
+		return stats.EggGroups[p.EggGroup[0]]
+	}
+	return stats.EggGroups[p.EggGroup[0]] + "/" + stats.EggGroups[p.EggGroup[1]]
+}
+
+func (p Pokemon) Record() export.Record {
+	var r export.Record
+	r.Add("index", p.Index)
+	r.Add("name", p.Name)
+	r.Add("form_name", p.FormName)
+	r.Add("full_name", p.FullName)
+	return append(r, p.PokemonStats.Record(nil)...)
+}
+
+// ReadPokemon reads the base stats of every Pokémon from the personal GARC
+// and names the alternate forms.
+func ReadPokemon(files []*garc.File) ([]Pokemon, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	// The last file is every Pokémon's stats in one.
+	pokemon := make([]Pokemon, len(files)-1)
+	for i := range pokemon {
+		p := &pokemon[i]
+		err := binary.Read(files[i], binary.LittleEndian, &p.PokemonStats)
+		if err != nil {
+			return nil, fmt.Errorf("file %d: %v", i, err)
+		}
+		p.Index = i
+		p.Name = names.Species(i)
+		if p.Index < len(formNames2) {
+			p.FormName = formNames2[p.Index]
+		}
+	}
+
+	for _, p := range pokemon {
+		if p.FormStats != 0 {
+			for j := 1; j < int(p.FormCount); j++ {
+				k := int(p.FormStats) + j - 1
+				if k >= len(pokemon) || int(p.FormTotal)+j-1 >= len(formNames) {
+					continue
+				}
+				pokemon[k].Name = p.Name
+				pokemon[k].FormName = formNames[int(p.FormTotal)+j-1]
+				pokemon[k].FullName = fullNames[int(p.FormTotal)+j-1]
+			}
+		}
+	}
+	return pokemon, nil
+}
+
+// WritePokemon writes the base stats page.
+func WritePokemon(w io.Writer, pokemon []Pokemon) error {
+	return pokemonTemplate.Execute(w, pokemon)
+}
+
+var pokemonTemplate = template.Must(template.New("pokemon").Funcs(funcs).Parse(pokemonText))
+
+var formNames2 = [...]string{
+	201: "One form",
+	351: "Normal",
+	382: "Kyogre",
+	383: "Groudon",
+	386: "Normal Forme",
+	412: "Plant Cloak",
+	413: "Plant Cloak",
+	421: "Overcast Form",
+	422: "West Sea",
+	423: "West Sea",
+	479: "Rotom",
+	487: "Altered Forme",
+	492: "Land Forme",
+	493: "Arceus",
+	550: "Red-Striped Form",
+	555: "Standard Mode",
+	585: "Spring Form",
+	586: "Spring Form",
+	641: "Incarnate Forme",
+	642: "Incarnate Forme",
+	645: "Incarnate Forme",
+	646: "Kyurem",
+	647: "Ordinary Form",
+	648: "Aria Forme",
+	649: "Genesect",
+	666: "Icy Snow Pattern",
+	669: "Red Flower",
+	670: "Red Flower",
+	671: "Red Flower",
+	676: "Natural Form",
+	678: "Male",
+	681: "Shield Forme",
+	710: "Average Size",
+	711: "Average Size",
+	716: "Neutral Mode",
+	720: "Hoopa Confined",
+}
+
+var formNames = []string{
+	"Mega Venusaur",
+	"Mega Charizard X",
+	"Mega Charizard Y",
+	"Mega Blastoise",
+	"Mega Beedrill",
+	"Mega Pidgeot",
+	"Pikachu Rock Star",
+	"Pikachu Belle",
+	"Pikachu Pop Star",
+	"Pikachu, Ph.D.",
+	"Pikachu Libre",
+	"Cosplay Pikachu",
+	"Mega Alakazam",
+	"Mega Slowbro",
+	"Mega Gengar",
+	"Mega Kangaskhan",
+	"Mega Pinsir",
+	"Mega Gyarados",
+	"Mega Aerodactyl",
+	"Mega Mewtwo X",
+	"Mega Mewtwo Y",
+	"Mega Ampharos",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"One form",
+	"Mega Steelix",
+	"Mega Scizor",
+	"Mega Heracross",
+	"Mega Houndoom",
+	"Mega Tyranitar",
+	"Mega Sceptile",
+	"Mega Blaziken",
+	"Mega Swampert",
+	"Mega Gardevoir",
+	"Mega Sableye",
+	"Mega Mawile",
+	"Mega Aggron",
+	"Mega Medicham",
+	"Mega Manectric",
+	"Mega Sharpedo",
+	"Mega Camerupt",
+	"Mega Altaria",
+	"Sunny Form",
+	"Rainy Form",
+	"Snowy Form",
+	"Mega Banette",
+	"Mega Absol",
+	"Mega Glalie",
+	"Mega Salamence",
+	"Mega Metagross",
+	"Mega Latias",
+	"Mega Latios",
+	"Primal Reversion",
+	"Primal Reversion",
+	"Mega Rayquaza",
+	"Attack Forme",
+	"Defense Forme",
+	"Speed Forme",
+	"Sandy Cloak",
+	"Trash Cloak",
+	"Sandy Cloak",
+	"Trash Cloak",
+	"Sunshine Form",
+	"East Sea",
+	"East Sea",
+	"Mega Lopunny",
+	"Mega Garchomp",
+	"Mega Lucario",
+	"Mega Abomasnow",
+	"Mega Gallade",
+	"Heat Rotom",
+	"Wash Rotom",
+	"Frost Rotom",
+	"Fan Rotom",
+	"Mow Rotom",
+	"Origin Forme",
+	"Sky Forme",
+	// Arceus
+	"Mega Audino",
+	"Blue-Striped Form",
+	"Zen Mode",
+	"Summer Form",
+	"Autumn Form",
+	"Winter Form",
+	"Summer Form",
+	"Autumn Form",
+	"Winter Form",
+	"Therian Forme",
+	"Therian Forme",
+	"Therian Forme",
+	"White Kyurem",
+	"Black Kyurem",
+	"Resolute Form",
+	"Pirouette Forme",
+	"Genesect",
+	"Genesect",
+	"Genesect",
+	"Genesect",
+	"Polar Pattern",
+	"Tundra Pattern",
+	"Continental Pattern",
+	"Garden Pattern",
+	"Elegant Pattern",
+	"Meadow Pattern",
+	"Modern Pattern",
+	"Marine Pattern",
+	"Archipelago Pattern",
+	"High Plains Pattern",
+	"Sandstorm Pattern",
+	"River Pattern",
+	"Monsoon Pattern",
+	"Savanna Pattern",
+	"Sun Pattern",
+	"Ocean Pattern",
+	"Jungle Pattern",
+	"Fancy Pattern",
+	"Poké Ball Pattern",
+	"Yellow Flower",
+	"Orange Flower",
+	"Blue Flower",
+	"White Flower",
+	"Yellow Flower",
+	"Orange Flower",
+	"Blue Flower",
+	"White Flower",
+	"Eternal Flower",
+	"Yellow Flower",
+	"Orange Flower",
+	"Blue Flower",
+	"White Flower",
+	"Heart Trim",
+	"Star Trim",
+	"Diamond Trim",
+	"Debutante Trim",
+	"Matron Trim",
+	"Dandy Trim",
+	"La Reine Trim",
+	"Kabuki Trim",
+	"Pharaoh Trim",
+	"Female",
+	"Blade Forme",
+	"Small Size",
+	"Large Size",
+	"Super Size",
+	"Small Size",
+	"Large Size",
+	"Super Size",
+	"Active Mode",
+	"Mega Diancie",
+	"Hoopa Unbound",
+}
+
+var fullNames = []string{
+	"Mega Venusaur",
+	"Mega Charizard X",
+	"Mega Charizard Y",
+	"Mega Blastoise",
+	"Mega Beedrill",
+	"Mega Pidgeot",
+	"Pikachu Rock Star",
+	"Pikachu Belle",
+	"Pikachu Pop Star",
+	"Pikachu, Ph.D.",
+	"Pikachu Libre",
+	"Cosplay Pikachu",
+	"Mega Alakazam",
+	"Mega Slowbro",
+	"Mega Gengar",
+	"Mega Kangaskhan",
+	"Mega Pinsir",
+	"Mega Gyarados",
+	"Mega Aerodactyl",
+	"Mega Mewtwo X",
+	"Mega Mewtwo Y",
+	"Mega Ampharos",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Unown",
+	"Mega Steelix",
+	"Mega Scizor",
+	"Mega Heracross",
+	"Mega Houndoom",
+	"Mega Tyranitar",
+	"Mega Sceptile",
+	"Mega Blaziken",
+	"Mega Swampert",
+	"Mega Gardevoir",
+	"Mega Sableye",
+	"Mega Mawile",
+	"Mega Aggron",
+	"Mega Medicham",
+	"Mega Manectric",
+	"Mega Sharpedo",
+	"Mega Camerupt",
+	"Mega Altaria",
+	"Sunny Castform",
+	"Rainy Castform",
+	"Snowy Castform",
+	"Mega Banette",
+	"Mega Absol",
+	"Mega Glalie",
+	"Mega Salamence",
+	"Mega Metagross",
+	"Mega Latias",
+	"Mega Latios",
+	"Primal Kyogre",
+	"Primal Groudon",
+	"Mega Rayquaza",
+	"Attack Deoxys",
+	"Defense Deoxys",
+	"Speed Deoxys",
+	"Sandy Burmy",
+	"Trash Burmy",
+	"Sandy Wormadam",
+	"Trash Wormadam",
+	"Sunshine Cherrim",
+	"East Shellos",
+	"East Gastrodon",
+	"Mega Lopunny",
+	"Mega Garchomp",
+	"Mega Lucario",
+	"Mega Abomasnow",
+	"Mega Gallade",
+	"Heat Rotom",
+	"Wash Rotom",
+	"Frost Rotom",
+	"Fan Rotom",
+	"Mow Rotom",
+	"Origin Giratina",
+	"Sky Shaymin",
+	// Arceus
+	"Mega Audino",
+	"Blue-Striped Form",
+	"Zen Mode",
+	"Summer Deerling",
+	"Autumn Deerling",
+	"Winter Deerling",
+	"Summer Sawsbuck",
+	"Autumn Sawsbuck",
+	"Winter Sawsbuck",
+	"Therian Forme",
+	"Therian Forme",
+	"Therian Forme",
+	"White Kyurem",
+	"Black Kyurem",
+	"Resolute Form",
+	"Pirouette Forme",
+	"Genesect",
+	"Genesect",
+	"Genesect",
+	"Genesect",
+	"Polar Pattern",
+	"Tundra Pattern",
+	"Continental Pattern",
+	"Garden Pattern",
+	"Elegant Pattern",
+	"Meadow Pattern",
+	"Modern Pattern",
+	"Marine Pattern",
+	"Archipelago Pattern",
+	"High Plains Pattern",
+	"Sandstorm Pattern",
+	"River Pattern",
+	"Monsoon Pattern",
+	"Savanna Pattern",
+	"Sun Pattern",
+	"Ocean Pattern",
+	"Jungle Pattern",
+	"Fancy Pattern",
+	"Poké Ball Pattern",
+	"Yellow Flabébé",
+	"Orange Flabébé",
+	"Blue Flabébé",
+	"White Flabébé",
+	"Yellow Floette",
+	"Orange Floette",
+	"Blue Floette",
+	"White Floette",
+	"Eternal Floette",
+	"Yellow Florges",
+	"Orange Florges",
+	"Blue Florges",
+	"White Florges",
+	"Heart Trim",
+	"Star Trim",
+	"Diamond Trim",
+	"Debutante Trim",
+	"Matron Trim",
+	"Dandy Trim",
+	"La Reine Trim",
+	"Kabuki Trim",
+	"Pharaoh Trim",
+	"Female",
+	"Blade Forme",
+	"Small Size",
+	"Large Size",
+	"Super Size",
+	"Small Size",
+	"Large Size",
+	"Super Size",
+	"Active Xernias",
+	"Mega Diancie",
+	"Hoopa Unbound",
+}
+
+var pokemonText = `<!DOCTYPE html>
+<meta charset="utf-8">
+<title>OR/AS pokémon base stats</title>
+<style type="text/css">
+  body { font-family: sans-serif; font-size: 16px; line-height: 1em; }
+  table { border-collapse: collapse; white-space: nowrap; }
+  thead { background: #B6C8E9; }
+  tbody { border: 2px solid black; }
+  tbody td, tbody th { border: 1px solid black; }
+  td, th { padding: 0.3em; }
+  td { text-align: right; }
+  td.str { text-align: center; }
+  td.list { text-align: left; }
+  td.int { text-align: right; }
+  td.hex { text-align: left; font-family: monospace; }
+  tbody tr:hover { background: #DEE6F5; }
+</style>
+
+<table>
+  <thead>
+    <tr>
+      <th>#</th>
+      <th>Name</th>
+      <th>Form Name</th>
+      <th>Full Name</th>
+
+      <th>HP</th>
+      <th>Atk</th>
+      <th>Def</th>
+      <th>Spd</th>
+      <th>SAtk</th>
+      <th>SDef</th>
+      <th>Type</th>
+      <th>Catch</th>
+      <th>Old</th>
+      <th>Effort</th>
+      <th>Item (50%)</th>
+      <th>Item (5%)</th>
+      <th>-</th>
+      <th>♀</th>
+      <th><img src="egg.png" alt="Egg"></th>
+      <th>:3</th>
+      <th>Egg Groups</th>
+      <th>Growth</th>
+      <th>Ability 0</th>
+      <th>Ability 1</th>
+      <th>Hidden Ability</th>
+      <th>?</th>
+      <th>Form</th>
+      <th>Form</th>
+      <th>#</th>
+      <th>Color</th>
+      <th>Exp.</th>
+      <th>Height</th>
+      <th>Weight</th>
+      {{/*<th>TMs</th>*/}}
+      {{/*<th>Tutors 0</th>*/}}
+      <th>Height 2</th>
+      <th>?</th>
+      {{/*<th>Extra</th>*/}}
+
+      <th>Name</th>
+      <th>#</th>
+    </tr>
+  </thead>
+
+  <tbody>
+    {{range .}}
+      <tr>
+        <th>{{.Index}}</th>
+        <th class=str>{{.Name}}</th>
+        <td class=str>{{.FormName}}</th>
+        <td class=str>{{.FullName}}</th>
+
+        <td>{{.HP}}</td>
+        <td>{{.Attack}}</td>
+        <td>{{.Defense}}
+        <td>{{.Speed}}</td>
+        <td>{{.SpecialAttack}}</td>
+        <td>{{.SpecialDefense}}</td>
+        <td class=str>{{.TypeText}}</td>
+        <td>{{.CatchRate}}</td>
+        <td>{{.ExpStage}}</td>
+        <td class=str>{{.EffortText}}</td>
+        <td class=str>{{item (index .Item 0)}}</td>
+        <td class=str>{{item (index .Item 1)}}</td>
+        <td class=str>{{item (index .Item 2)}}</td>
+        <td>{{.FemaleRate}}</td>
+        <td>{{.Hatch}}</td>
+        <td>{{.Friendship}}</td>
+        <td class=str>{{.EggText}}</td>
+        <td>{{.GrowthRate}}</td>
+        <td class=str>{{index .Ability 0 | ability}}</td>
+        <td class=str>{{if ne (index .Ability 0) (index .Ability 1)}}{{index .Ability 1 | ability}}{{end}}</td>
+        <td class=str>{{index .Ability 2 | ability}}</td>
+        <td>{{.Unknown1B}}</td>
+        <td>{{.Form}}</td>
+        <td>{{.FormNameIndex}}</td>
+        <td>{{.FormCount}}</td>
+        <td>{{.Color}}</td>
+        <td>{{.Exp}}</td>
+        <td>{{.Height}}</td>
+        <td>{{.Weight}}</td>
+        {{/*<td class=hex>{{bin .TM}}</td>*/}}
+        {{/*<td class=hex>{{bin .Tutor0}}</td>*/}}
+        <td>{{.Height2}}</td>
+        <td>{{.Unknown3E}}</td>
+        {{/*<td class=hex>{{printf "% x" .Extra}}</td>*/}}
+
+        <th class=str>{{.Name}}</th>
+        <th>{{.Index}}</th>
+      </tr>
+    {{end}}
+  </tbody>
+</table>
+
+<script src="https://cdnjs.cloudflare.com/ajax/libs/jquery/2.1.4/jquery.js"></script>
+<script src="https://cdnjs.cloudflare.com/ajax/libs/floatthead/1.2.10/jquery.floatThead.js"></script>
+<script>$('table').floatThead();</script>
+`
@@ -0,0 +1,339 @@
+// Usage: patch [-oras] [-lang en] [-o outdir] romfs table edits.yaml
+// Apply edited records to one of the game's tables
+// and write the rebuilt GARCs under outdir at their romfs paths,
+// so that outdir can be used as a LayeredFS directory or merged with romfs -o.
+//
+// The tables are personal, moves, items, learnsets, trainers, and encounters.
+// The edits are JSON or YAML in the format written by the data tools' -format flag;
+// records are matched by their index (zone for encounters),
+// and fields which are left out keep their values.
+// Encounters can only be patched in X and Y.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"xy/export"
+	"xy/garc"
+	"xy/lz"
+	"xy/names"
+	"xy/stats"
+	"xy/util"
+	"xy/zone"
+)
+
+var (
+	oras   = flag.Bool("oras", false, "the romfs is from Omega Ruby or Alpha Sapphire")
+	lang   = flag.String("lang", "en", "accept names from the game text in `language`")
+	outdir = flag.String("o", ".", "write the GARCs under `dir`")
+	format = flag.String("format", "", "read the edits as `format` json or yaml, instead of by the file extension")
+)
+
+func die(v ...interface{}) {
+	fmt.Fprintln(os.Stderr, v...)
+	os.Exit(1)
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() < 3 {
+		die("usage: patch [-oras] [-lang en] [-o outdir] romfs personal|moves|items|learnsets|trainers|encounters edits.yaml")
+	}
+	table, editname := flag.Arg(1), flag.Arg(2)
+
+	romfs, err := util.OpenFS(flag.Arg(0))
+	if err != nil {
+		die(err)
+	}
+	v := names.XY
+	if *oras {
+		v = names.ORAS
+	}
+	cat, err := names.Load(romfs, v, *lang)
+	if err != nil {
+		log.Println(err)
+	}
+
+	f := *format
+	if f == "" {
+		f = strings.TrimPrefix(filepath.Ext(editname), ".")
+		if f == "yml" {
+			f = "yaml"
+		}
+	}
+	ef, err := os.Open(editname)
+	if err != nil {
+		die(err)
+	}
+	edits, err := export.Read(ef, f)
+	ef.Close()
+	if err != nil {
+		die(editname+":", err)
+	}
+
+	p := &patcher{romfs: romfs, cat: cat, garcs: make(map[string][]garc.Entry)}
+	game := stats.GameFiles[v]
+	var fn func(d *export.Decoder, index int) error
+	key := "index"
+	switch table {
+	case "personal":
+		fn = p.personal(game.Personal)
+	case "moves":
+		fn = p.moves(game.Moves)
+	case "items":
+		fn = p.items(game.Items)
+	case "learnsets":
+		fn = p.learnsets(game.Learnsets)
+	case "trainers":
+		fn = p.trainers(game.Trainers, game.TrainerPokemon)
+	case "encounters":
+		if err := zone.CheckEncounters(v); err != nil {
+			die(err)
+		}
+		fn = p.encounters(game.Zones)
+		key = "zone"
+	default:
+		die("unknown table:", table)
+	}
+	if p.err != nil {
+		die(p.err)
+	}
+
+	failed := false
+	for i, r := range edits {
+		d := export.NewDecoder(r, fmt.Sprintf("%s[%d]", table, i))
+		index := -1
+		d.Int(key, &index)
+		if d.Err() == nil && index < 0 {
+			d.Fail(key, fmt.Errorf("missing"))
+		}
+		if d.Err() == nil {
+			if err := fn(d, index); err != nil {
+				d.Fail(key, err)
+			}
+		}
+		if err := d.Err(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+
+	for name, entries := range p.garcs {
+		if err := writeGARC(filepath.Join(*outdir, filepath.FromSlash(name)), entries); err != nil {
+			die(err)
+		}
+	}
+}
+
+// patcher holds the contents of the GARCs being edited.
+type patcher struct {
+	romfs fs.FS
+	cat   *names.Catalog
+	garcs map[string][]garc.Entry
+	err   error
+}
+
+// open returns the files of a GARC, reading it the first time.
+func (p *patcher) open(name string) []garc.Entry {
+	if entries, ok := p.garcs[name]; ok || p.err != nil {
+		return entries
+	}
+	g, err := util.OpenGARCFS(p.romfs, name)
+	if err != nil {
+		p.err = err
+		return nil
+	}
+	defer g.Close()
+	entries, err := garc.Entries(g.Files)
+	if err != nil {
+		p.err = fmt.Errorf("%s: %v", name, err)
+		return nil
+	}
+	p.garcs[name] = entries
+	return entries
+}
+
+func entry(entries []garc.Entry, index int) (*garc.Entry, error) {
+	if index >= len(entries) {
+		return nil, fmt.Errorf("no file %d", index)
+	}
+	return &entries[index], nil
+}
+
+func (p *patcher) personal(name string) func(*export.Decoder, int) error {
+	entries := p.open(name)
+	size := binary.Size(stats.PokemonStats{})
+	return func(d *export.Decoder, index int) error {
+		// The last file is every Pokémon's stats in one.
+		if index >= len(entries)-1 {
+			return fmt.Errorf("no Pokémon %d", index)
+		}
+		e := &entries[index]
+		var s stats.PokemonStats
+		if err := binary.Read(bytes.NewReader(e.Data), binary.LittleEndian, &s); err != nil {
+			return err
+		}
+		s.Decode(d, p.cat)
+		e.Data = stats.Encode(e.Data, &s)
+		if all := &entries[len(entries)-1]; len(all.Data) >= (index+1)*size {
+			copy(all.Data[index*size:], e.Data[:size])
+		}
+		return nil
+	}
+}
+
+func (p *patcher) moves(name string) func(*export.Decoder, int) error {
+	entries := p.open(name)
+	return func(d *export.Decoder, index int) error {
+		var moves []stats.MoveStats
+		var e *garc.Entry
+		var err error
+		if len(entries) == 1 {
+			// All the moves are packed into one file.
+			e = &entries[0]
+			moves, err = stats.ReadMoves(bytes.NewReader(e.Data))
+			if err == nil && index >= len(moves) {
+				err = fmt.Errorf("no move %d", index)
+			}
+		} else {
+			e, err = entry(entries, index)
+			if err == nil {
+				moves = make([]stats.MoveStats, index+1)
+				err = binary.Read(bytes.NewReader(e.Data), binary.LittleEndian, &moves[index])
+			}
+		}
+		if err != nil {
+			return err
+		}
+		m := &moves[index]
+		m.Decode(d, p.cat)
+		if len(entries) == 1 {
+			return stats.PutMove(e.Data, index, m)
+		}
+		e.Data = stats.Encode(e.Data, m)
+		return nil
+	}
+}
+
+func (p *patcher) items(name string) func(*export.Decoder, int) error {
+	entries := p.open(name)
+	return func(d *export.Decoder, index int) error {
+		e, err := entry(entries, index)
+		if err != nil {
+			return err
+		}
+		var s stats.ItemStats
+		if err := binary.Read(bytes.NewReader(e.Data), binary.LittleEndian, &s); err != nil {
+			return err
+		}
+		s.Decode(d, p.cat)
+		e.Data = stats.Encode(e.Data, &s)
+		return nil
+	}
+}
+
+func (p *patcher) learnsets(name string) func(*export.Decoder, int) error {
+	entries := p.open(name)
+	return func(d *export.Decoder, index int) error {
+		e, err := entry(entries, index)
+		if err != nil {
+			return err
+		}
+		l, err := stats.ReadLearnset(bytes.NewReader(e.Data))
+		if err != nil {
+			return err
+		}
+		l.Decode(d, p.cat)
+		e.Data = l.Bytes()
+		return nil
+	}
+}
+
+func (p *patcher) trainers(trdata, trpoke string) func(*export.Decoder, int) error {
+	trainers, teams := p.open(trdata), p.open(trpoke)
+	return func(d *export.Decoder, index int) error {
+		te, err := entry(trainers, index)
+		if err != nil {
+			return err
+		}
+		pe, err := entry(teams, index)
+		if err != nil {
+			return err
+		}
+		// The first trainer is empty.
+		var t stats.Team
+		if len(te.Data) > 0 {
+			t.Trainer, err = stats.ReadTrainer(bytes.NewReader(te.Data))
+			if err != nil {
+				return err
+			}
+		}
+		t.Pokemon, err = stats.ReadTeam(bytes.NewReader(pe.Data), &t.Trainer)
+		if err != nil {
+			return err
+		}
+		t.Decode(d, p.cat)
+		if len(te.Data) == 0 && len(t.Pokemon) == 0 {
+			return nil
+		}
+		te.Data = stats.Encode(te.Data, &t.Trainer)
+		pe.Data = stats.TeamBytes(&t.Trainer, t.Pokemon)
+		return nil
+	}
+}
+
+func (p *patcher) encounters(name string) func(*export.Decoder, int) error {
+	entries := p.open(name)
+	return func(d *export.Decoder, index int) error {
+		// The last file is the zone table.
+		if index >= len(entries)-1 {
+			return fmt.Errorf("no zone %d", index)
+		}
+		e := &entries[index]
+		b, err := lz.Decode(bytes.NewReader(e.Data))
+		if err != nil {
+			return err
+		}
+		enc, err := zone.ReadEncounter(b)
+		if err != nil {
+			return err
+		}
+		if enc == nil {
+			return fmt.Errorf("zone %d has no encounters", index)
+		}
+		enc.Decode(d, p.cat)
+		if err := zone.WriteEncounter(b, enc); err != nil {
+			return err
+		}
+		e.Data, err = lz.Compress11(b)
+		return err
+	}
+}
+
+func writeGARC(name string, entries []garc.Entry) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		return err
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := garc.Write(f, entries); err != nil {
+		f.Close()
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "wrote", path.Clean(filepath.ToSlash(name)))
+	return f.Close()
+}
@@ -0,0 +1,374 @@
+// Usage: randomize [options] [-seed n] [-o outdir] romfs
+// Randomize the game's wild encounters, trainers, and Pokémon
+// and write the changed GARCs under outdir at their romfs paths,
+// along with a spoiler log listing every change.
+//
+// The same seed and options always give the same game.
+// If no seed is given one is chosen, and it is printed
+// and written at the top of the log.
+// Wild encounters can only be randomized in X and Y.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"xy/garc"
+	"xy/lz"
+	"xy/names"
+	"xy/randomizer"
+	"xy/stats"
+	"xy/util"
+	"xy/zone"
+)
+
+var (
+	oras    = flag.Bool("oras", false, "the romfs is from Omega Ruby or Alpha Sapphire")
+	lang    = flag.String("lang", "en", "write the log with names from the game text in `language`")
+	outdir  = flag.String("o", ".", "write the GARCs under `dir`")
+	logname = flag.String("log", "", "write the spoiler log to `file` (default outdir/spoiler.txt)")
+	seed    = flag.Int64("seed", 0, "random `seed` (default chosen at random)")
+
+	encounters = flag.String("encounters", "", "randomize wild Pokémon by `area` or global")
+	similar    = flag.Bool("similar", false, "replace wild and trainer Pokémon with ones of similar base stat total")
+	trainers   = flag.Bool("trainers", false, "randomize trainers' Pokémon")
+	types      = flag.Bool("types", false, "randomize Pokémon types")
+	abilities  = flag.Bool("abilities", false, "randomize Pokémon abilities")
+	learnsets  = flag.Bool("learnsets", false, "randomize level-up moves")
+	machines   = flag.Bool("machines", false, "randomize TM compatibility")
+)
+
+// Number of species and abilities in each game.
+var limits = map[names.Version]struct{ species, abilities int }{
+	names.XY:   {721, 189},
+	names.ORAS: {721, 192},
+}
+
+func die(v ...interface{}) {
+	fmt.Fprintln(os.Stderr, v...)
+	os.Exit(1)
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() < 1 {
+		die("usage: randomize [-oras] [-seed n] [-encounters area|global] [-similar] [-trainers] [-types] [-abilities] [-learnsets] [-machines] [-o outdir] [-log file] romfs")
+	}
+	r := &randomizer.Randomizer{Seed: *seed}
+	switch *encounters {
+	case "":
+	case "area":
+		r.Encounters = randomizer.AreaEncounters
+	case "global":
+		r.Encounters = randomizer.GlobalEncounters
+	default:
+		die("-encounters: want area or global")
+	}
+	v := names.XY
+	if *oras {
+		v = names.ORAS
+	}
+	if r.Encounters != randomizer.KeepEncounters {
+		if err := zone.CheckEncounters(v); err != nil {
+			die(err)
+		}
+	}
+	r.SimilarStats = *similar
+	r.Trainers = *trainers
+	r.Types = *types
+	r.Abilities = *abilities
+	r.Learnsets = *learnsets
+	r.Machines = *machines
+	seeded := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			seeded = true
+		}
+	})
+	if !seeded {
+		r.Seed = time.Now().UnixNano()
+	}
+	fmt.Fprintln(os.Stderr, "seed", r.Seed)
+
+	romfs, err := util.OpenFS(flag.Arg(0))
+	if err != nil {
+		die(err)
+	}
+	r.Names, err = names.Load(romfs, v, *lang)
+	if err != nil {
+		log.Println(err)
+	}
+
+	g := &game{files: stats.GameFiles[v], garcs: make(map[string][]garc.Entry)}
+	d := &randomizer.Data{
+		Species:   limits[v].species,
+		Abilities: limits[v].abilities,
+	}
+	for _, fn := range []func(*randomizer.Data, fs.FS) error{g.readPersonal, g.readLearnsets, g.readMoves} {
+		if err := fn(d, romfs); err != nil {
+			die(err)
+		}
+	}
+	if r.Trainers {
+		if err := g.readTrainers(d, romfs); err != nil {
+			die(err)
+		}
+	}
+	if r.Encounters != randomizer.KeepEncounters {
+		if err := g.readEncounters(d, romfs); err != nil {
+			die(err)
+		}
+	}
+
+	name := *logname
+	if name == "" {
+		name = filepath.Join(*outdir, "spoiler.txt")
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		die(err)
+	}
+	lf, err := os.Create(name)
+	if err != nil {
+		die(err)
+	}
+	r.Log = lf
+	if err := r.Randomize(d); err != nil {
+		die(err)
+	}
+	if err := lf.Close(); err != nil {
+		die(err)
+	}
+
+	if r.Types || r.Abilities || r.Machines {
+		g.writePersonal(d)
+	}
+	if r.Learnsets {
+		g.writeLearnsets(d)
+	}
+	if r.Trainers {
+		g.writeTrainers(d)
+	}
+	if r.Encounters != randomizer.KeepEncounters {
+		if err := g.writeEncounters(d); err != nil {
+			die(err)
+		}
+	}
+	for _, name := range g.changed {
+		if err := writeGARC(filepath.Join(*outdir, filepath.FromSlash(name)), g.garcs[name]); err != nil {
+			die(err)
+		}
+	}
+}
+
+// game holds the contents of the GARCs being randomized.
+type game struct {
+	files   stats.Files
+	garcs   map[string][]garc.Entry
+	changed []string // the GARCs to write
+	zones   [][]byte // decompressed zone files
+}
+
+// open returns the files of a GARC.
+func (g *game) open(romfs fs.FS, name string) ([]garc.Entry, error) {
+	if entries, ok := g.garcs[name]; ok {
+		return entries, nil
+	}
+	f, err := util.OpenGARCFS(romfs, name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries, err := garc.Entries(f.Files)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	g.garcs[name] = entries
+	return entries, nil
+}
+
+func (g *game) change(name string) {
+	for _, n := range g.changed {
+		if n == name {
+			return
+		}
+	}
+	g.changed = append(g.changed, name)
+}
+
+func (g *game) readPersonal(d *randomizer.Data, romfs fs.FS) error {
+	entries, err := g.open(romfs, g.files.Personal)
+	if err != nil {
+		return err
+	}
+	// The last file is every Pokémon's stats in one.
+	if len(entries) == 0 {
+		return fmt.Errorf("%s: empty", g.files.Personal)
+	}
+	d.Pokemon = make([]stats.PokemonStats, len(entries)-1)
+	for i := range d.Pokemon {
+		err := binary.Read(bytes.NewReader(entries[i].Data), binary.LittleEndian, &d.Pokemon[i])
+		if err != nil {
+			return fmt.Errorf("%s: file %d: %v", g.files.Personal, i, err)
+		}
+	}
+	return nil
+}
+
+func (g *game) writePersonal(d *randomizer.Data) {
+	entries := g.garcs[g.files.Personal]
+	var all []byte
+	for i := range d.Pokemon {
+		e := &entries[i]
+		e.Data = stats.Encode(e.Data, &d.Pokemon[i])
+		all = append(all, e.Data[:binary.Size(&d.Pokemon[i])]...)
+	}
+	last := &entries[len(entries)-1]
+	last.Data = stats.Encode(last.Data, all)
+	g.change(g.files.Personal)
+}
+
+func (g *game) readLearnsets(d *randomizer.Data, romfs fs.FS) error {
+	entries, err := g.open(romfs, g.files.Learnsets)
+	if err != nil {
+		return err
+	}
+	d.Learnsets = make([]stats.Learnset, len(entries))
+	for i, e := range entries {
+		d.Learnsets[i], err = stats.ReadLearnset(bytes.NewReader(e.Data))
+		if err != nil {
+			return fmt.Errorf("%s: file %d: %v", g.files.Learnsets, i, err)
+		}
+	}
+	return nil
+}
+
+func (g *game) writeLearnsets(d *randomizer.Data) {
+	entries := g.garcs[g.files.Learnsets]
+	for i, l := range d.Learnsets {
+		if len(l) > 0 {
+			entries[i].Data = l.Bytes()
+		}
+	}
+	g.change(g.files.Learnsets)
+}
+
+func (g *game) readMoves(d *randomizer.Data, romfs fs.FS) error {
+	f, err := util.OpenGARCFS(romfs, g.files.Moves)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	d.Moves, err = stats.ReadMoveFiles(f.Files)
+	if err != nil {
+		return fmt.Errorf("%s: %v", g.files.Moves, err)
+	}
+	return nil
+}
+
+func (g *game) readTrainers(d *randomizer.Data, romfs fs.FS) error {
+	trdata, err := g.open(romfs, g.files.Trainers)
+	if err != nil {
+		return err
+	}
+	trpoke, err := g.open(romfs, g.files.TrainerPokemon)
+	if err != nil {
+		return err
+	}
+	d.Teams = make([]stats.Team, len(trdata))
+	for i, e := range trdata {
+		// The first trainer is empty.
+		if len(e.Data) == 0 || i >= len(trpoke) {
+			continue
+		}
+		t := &d.Teams[i]
+		t.Trainer, err = stats.ReadTrainer(bytes.NewReader(e.Data))
+		if err != nil {
+			return fmt.Errorf("trainer %d: %v", i, err)
+		}
+		t.Pokemon, err = stats.ReadTeam(bytes.NewReader(trpoke[i].Data), &t.Trainer)
+		if err != nil {
+			return fmt.Errorf("trainer %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// writeTrainers writes the teams.
+// The trainers themselves are unchanged.
+func (g *game) writeTrainers(d *randomizer.Data) {
+	trpoke := g.garcs[g.files.TrainerPokemon]
+	for i := range d.Teams {
+		t := &d.Teams[i]
+		if len(t.Pokemon) > 0 {
+			trpoke[i].Data = stats.TeamBytes(&t.Trainer, t.Pokemon)
+		}
+	}
+	g.change(g.files.TrainerPokemon)
+}
+
+func (g *game) readEncounters(d *randomizer.Data, romfs fs.FS) error {
+	entries, err := g.open(romfs, g.files.Zones)
+	if err != nil {
+		return err
+	}
+	// The last file is the zone table.
+	if len(entries) == 0 {
+		return fmt.Errorf("%s: empty", g.files.Zones)
+	}
+	g.zones = make([][]byte, len(entries)-1)
+	d.Encounters = make([]*zone.Encounter, len(g.zones))
+	for i := range g.zones {
+		b, err := lz.Decode(bytes.NewReader(entries[i].Data))
+		if err != nil {
+			return fmt.Errorf("zone %d: %v", i, err)
+		}
+		g.zones[i] = b
+		d.Encounters[i], err = zone.ReadEncounter(b)
+		if err != nil {
+			return fmt.Errorf("zone %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func (g *game) writeEncounters(d *randomizer.Data) error {
+	entries := g.garcs[g.files.Zones]
+	for i, enc := range d.Encounters {
+		if enc == nil {
+			continue
+		}
+		if err := zone.WriteEncounter(g.zones[i], enc); err != nil {
+			return fmt.Errorf("zone %d: %v", i, err)
+		}
+		b, err := lz.Compress11(g.zones[i])
+		if err != nil {
+			return fmt.Errorf("zone %d: %v", i, err)
+		}
+		entries[i].Data = b
+	}
+	g.change(g.files.Zones)
+	return nil
+}
+
+func writeGARC(name string, entries []garc.Entry) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		return err
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := garc.Write(f, entries); err != nil {
+		f.Close()
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "wrote", filepath.ToSlash(name))
+	return f.Close()
+}
@@ -0,0 +1,143 @@
+package randomizer
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"xy/stats"
+	"xy/types"
+)
+
+const (
+	wonderGuard = 25  // not given out, since most Pokémon would be unbeatable
+	struggle    = 165 // not learnable
+)
+
+func (r *Randomizer) types(d *Data, rng *rand.Rand) error {
+	r.section("types")
+	for i := 1; i < len(d.Pokemon); i++ {
+		p := &d.Pokemon[i]
+		old := r.typeName(p)
+		// Single-typed Pokémon stay single-typed.
+		dual := p.Type[0] != p.Type[1]
+		p.Type[0] = uint8(rng.Intn(types.N))
+		p.Type[1] = p.Type[0]
+		if dual {
+			p.Type[1] = uint8((int(p.Type[0]) + 1 + rng.Intn(types.N-1)) % types.N)
+		}
+		fmt.Fprintf(r.Log, "%d %s: %s -> %s\n", i, r.species(d, i), old, r.typeName(p))
+	}
+	return nil
+}
+
+func (r *Randomizer) typeName(p *stats.PokemonStats) string {
+	if p.Type[0] == p.Type[1] {
+		return r.Names.Type(int(p.Type[0]))
+	}
+	return r.Names.Type(int(p.Type[0])) + "/" + r.Names.Type(int(p.Type[1]))
+}
+
+func (r *Randomizer) abilities(d *Data, rng *rand.Rand) error {
+	if d.Abilities <= 2 {
+		return fmt.Errorf("randomizer: %d abilities", d.Abilities)
+	}
+	r.section("abilities")
+	ability := func() uint8 {
+		for {
+			if n := 1 + rng.Intn(d.Abilities-1); n != wonderGuard {
+				return uint8(n)
+			}
+		}
+	}
+	for i := 1; i < len(d.Pokemon); i++ {
+		p := &d.Pokemon[i]
+		old := r.abilityNames(p)
+		// A Pokémon with one ability has it in both of the first two slots.
+		single := p.Ability[0] == p.Ability[1]
+		p.Ability[0] = ability()
+		p.Ability[1] = p.Ability[0]
+		for !single && p.Ability[1] == p.Ability[0] {
+			p.Ability[1] = ability()
+		}
+		p.Ability[2] = ability()
+		fmt.Fprintf(r.Log, "%d %s: %s -> %s\n", i, r.species(d, i), old, r.abilityNames(p))
+	}
+	return nil
+}
+
+func (r *Randomizer) abilityNames(p *stats.PokemonStats) string {
+	var list []string
+	for _, a := range p.Ability {
+		list = append(list, r.Names.Ability(int(a)))
+	}
+	return strings.Join(list, "/")
+}
+
+// learnsets replaces every level-up move, keeping the levels.
+// The first move is one that does damage, so that
+// every Pokémon can attack from the start.
+func (r *Randomizer) learnsets(d *Data, rng *rand.Rand) error {
+	if len(d.Moves) <= struggle {
+		return fmt.Errorf("randomizer: %d moves", len(d.Moves))
+	}
+	r.section("learnsets")
+	var damaging []int
+	for i := 1; i < len(d.Moves); i++ {
+		if d.Moves[i].Power > 0 && i != struggle {
+			damaging = append(damaging, i)
+		}
+	}
+	for i := 1; i < len(d.Learnsets) && i < len(d.Pokemon); i++ {
+		l := d.Learnsets[i]
+		if len(l) == 0 {
+			continue
+		}
+		var list []string
+		for j := range l {
+			for {
+				var n int
+				if j == 0 && len(damaging) > 0 {
+					n = damaging[rng.Intn(len(damaging))]
+				} else {
+					n = 1 + rng.Intn(len(d.Moves)-1)
+				}
+				if n != struggle && !learns(l[:j], n) {
+					l[j].Move = uint16(n)
+					break
+				}
+			}
+			list = append(list, fmt.Sprintf("%d %s", l[j].Level, r.Names.Move(int(l[j].Move))))
+		}
+		fmt.Fprintf(r.Log, "%d %s: %s\n", i, r.species(d, i), strings.Join(list, ", "))
+	}
+	return nil
+}
+
+func learns(l stats.Learnset, move int) bool {
+	for _, m := range l {
+		if int(m.Move) == move {
+			return true
+		}
+	}
+	return false
+}
+
+// machines gives each Pokémon each TM with even odds.
+func (r *Randomizer) machines(d *Data, rng *rand.Rand) error {
+	const tms = 100
+	r.section("machines")
+	for i := 1; i < len(d.Pokemon); i++ {
+		p := &d.Pokemon[i]
+		for bit := 0; bit < tms; bit++ {
+			mask := uint8(1) << uint(bit%8)
+			if rng.Intn(2) == 0 {
+				p.TM[bit/8] &^= mask
+			} else {
+				p.TM[bit/8] |= mask
+			}
+		}
+		fmt.Fprintf(r.Log, "%d %s: %s\n", i, r.species(d, i), strings.Join(p.Machines(), " "))
+	}
+	return nil
+}
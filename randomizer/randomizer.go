@@ -0,0 +1,283 @@
+// Package randomizer shuffles the game data of Pokémon X and Y
+// and Omega Ruby and Alpha Sapphire: wild encounters, trainer teams,
+// and the Pokémon's types, abilities, learnsets, and TM compatibility.
+//
+// Randomizing is deterministic. The same seed and options
+// applied to the same data always give the same result,
+// and each kind of change draws from its own source,
+// so turning one option on or off doesn't change the others.
+package randomizer
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+
+	"xy/names"
+	"xy/stats"
+	"xy/zone"
+)
+
+// EncounterMode says how wild Pokémon are replaced.
+type EncounterMode int
+
+const (
+	KeepEncounters   EncounterMode = iota
+	AreaEncounters                 // a species is replaced by the same one throughout a zone
+	GlobalEncounters               // a species is replaced by the same one everywhere
+)
+
+// Options says what to randomize.
+type Options struct {
+	Encounters EncounterMode
+	Trainers   bool
+
+	// SimilarStats picks replacements for wild and trainer Pokémon
+	// whose base stat total is close to the original's.
+	SimilarStats bool
+
+	Types     bool
+	Abilities bool
+	Learnsets bool
+	Machines  bool // TM compatibility; HMs are left alone
+}
+
+// Data is the game data to randomize, which is changed in place.
+type Data struct {
+	Pokemon    []stats.PokemonStats // base stats, indexed as in the personal GARC
+	Learnsets  []stats.Learnset     // indexed like Pokemon
+	Moves      []stats.MoveStats
+	Teams      []stats.Team      // trainer teams, indexed by trainer
+	Encounters []*zone.Encounter // indexed by zone; nil if the zone has none
+
+	Species   int // number of species, not counting alternate forms
+	Abilities int // number of abilities, counting the 0th
+}
+
+// A Randomizer randomizes game data.
+type Randomizer struct {
+	Options
+	Seed int64
+
+	Names *names.Catalog // names used in the log
+	Log   io.Writer      // the spoiler log, listing every change; may be nil
+}
+
+// Each step has its own source of random numbers, seeded from Seed.
+const (
+	stepTypes = iota + 1
+	stepAbilities
+	stepLearnsets
+	stepMachines
+	stepEncounters
+	stepTrainers
+)
+
+// Randomize randomizes d according to the options.
+// Trainers' moves are chosen from their Pokémon's learnsets,
+// so they are randomized last.
+func (r *Randomizer) Randomize(d *Data) error {
+	if d.Species <= 0 || d.Species >= len(d.Pokemon) {
+		return fmt.Errorf("randomizer: %d species but base stats for %d", d.Species, len(d.Pokemon))
+	}
+	if r.Log == nil {
+		r.Log = ioutil.Discard
+	}
+	fmt.Fprintf(r.Log, "seed %d\n", r.Seed)
+	for _, step := range []struct {
+		on bool
+		n  int
+		fn func(*Data, *rand.Rand) error
+	}{
+		{r.Types, stepTypes, r.types},
+		{r.Abilities, stepAbilities, r.abilities},
+		{r.Learnsets, stepLearnsets, r.learnsets},
+		{r.Machines, stepMachines, r.machines},
+		{r.Encounters != KeepEncounters, stepEncounters, r.encounters},
+		{r.Trainers, stepTrainers, r.trainers},
+	} {
+		if !step.on {
+			continue
+		}
+		rng := rand.New(rand.NewSource(r.Seed + int64(step.n)))
+		if err := step.fn(d, rng); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Randomizer) section(name string) {
+	fmt.Fprintf(r.Log, "\n== %s ==\n", name)
+}
+
+// species returns the name of the Pokémon at index i of d.Pokemon.
+func (r *Randomizer) species(d *Data, i int) string {
+	if i <= d.Species {
+		return r.Names.Species(i)
+	}
+	return fmt.Sprintf("form %d", i)
+}
+
+func baseStatTotal(p *stats.PokemonStats) int {
+	total := 0
+	for _, s := range p.Stat {
+		total += int(s)
+	}
+	return total
+}
+
+// replacement picks a species to replace the original.
+// With SimilarStats, the replacement's base stat total is within 10%
+// of the original's, widening the range if there are too few to choose from.
+func (r *Randomizer) replacement(d *Data, rng *rand.Rand, orig int) int {
+	if !r.SimilarStats || orig <= 0 || orig > d.Species {
+		return 1 + rng.Intn(d.Species)
+	}
+	const enough = 5
+	bst := baseStatTotal(&d.Pokemon[orig])
+	var list []int
+	for pct := 10; len(list) < enough && pct <= 100; pct += 10 {
+		list = list[:0]
+		for i := 1; i <= d.Species; i++ {
+			diff := baseStatTotal(&d.Pokemon[i]) - bst
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff*100 <= bst*pct {
+				list = append(list, i)
+			}
+		}
+	}
+	if len(list) == 0 {
+		return 1 + rng.Intn(d.Species)
+	}
+	return list[rng.Intn(len(list))]
+}
+
+func (r *Randomizer) encounters(d *Data, rng *rand.Rand) error {
+	r.section("encounters")
+	global := make(map[int]int)
+	if r.Encounters == GlobalEncounters {
+		for i := 1; i <= d.Species; i++ {
+			global[i] = r.replacement(d, rng, i)
+		}
+	}
+	for z, enc := range d.Encounters {
+		if enc == nil {
+			continue
+		}
+		replace := global
+		var order []int
+		if r.Encounters == AreaEncounters {
+			replace = make(map[int]int)
+		}
+		for i := range zone.EncounterMethods {
+			m := &zone.EncounterMethods[i]
+			if !enc.Has(m) {
+				continue
+			}
+			slots := m.Slots(enc)
+			for j := range slots {
+				s := &slots[j]
+				orig := s.Species()
+				if orig == 0 {
+					continue
+				}
+				n, ok := replace[orig]
+				if !ok {
+					n = r.replacement(d, rng, orig)
+					replace[orig] = n
+				}
+				if !contains(order, orig) {
+					order = append(order, orig)
+				}
+				s.Pokemon = uint16(n)
+			}
+		}
+		if len(order) == 0 {
+			continue
+		}
+		var changes []string
+		for _, orig := range order {
+			changes = append(changes, fmt.Sprintf("%s -> %s", r.Names.Species(orig), r.Names.Species(replace[orig])))
+		}
+		fmt.Fprintf(r.Log, "zone %d: %s\n", z, strings.Join(changes, ", "))
+	}
+	return nil
+}
+
+func contains(list []int, n int) bool {
+	for _, m := range list {
+		if m == n {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Randomizer) trainers(d *Data, rng *rand.Rand) error {
+	r.section("trainers")
+	for i := range d.Teams {
+		t := &d.Teams[i]
+		if len(t.Pokemon) == 0 {
+			continue
+		}
+		var team []string
+		for j := range t.Pokemon {
+			p := &t.Pokemon[j]
+			p.Pokemon = uint16(r.replacement(d, rng, int(p.Pokemon)))
+			p.Form = 0
+			if t.Trainer.Format&stats.TrainerMoves != 0 {
+				p.Moves = levelMoves(d, int(p.Pokemon), int(p.Level))
+			}
+			team = append(team, r.teamMember(p))
+		}
+		fmt.Fprintf(r.Log, "%d %s: %s\n", i, r.Names.TrainerName(i), strings.Join(team, ", "))
+	}
+	return nil
+}
+
+func (r *Randomizer) teamMember(p *stats.TrainerPokemon) string {
+	s := fmt.Sprintf("L%d %s", p.Level, r.Names.Species(int(p.Pokemon)))
+	if len(p.Moves) > 0 {
+		var moves []string
+		for _, m := range p.Moves {
+			if m != 0 {
+				moves = append(moves, r.Names.Move(int(m)))
+			}
+		}
+		s += " (" + strings.Join(moves, "/") + ")"
+	}
+	return s
+}
+
+// levelMoves returns the last four moves the Pokémon learns
+// by its level, as a wild Pokémon would know.
+// The list is padded with zeros.
+func levelMoves(d *Data, species, level int) []uint16 {
+	moves := make([]uint16, 0, 4)
+	if species < len(d.Learnsets) {
+		for _, m := range d.Learnsets[species] {
+			if int(m.Level) > level {
+				break
+			}
+			for k, n := range moves {
+				if n == m.Move {
+					moves = append(moves[:k], moves[k+1:]...)
+					break
+				}
+			}
+			if len(moves) == 4 {
+				moves = append(moves[:0], moves[1:]...)
+			}
+			moves = append(moves, m.Move)
+		}
+	}
+	for len(moves) < 4 {
+		moves = append(moves, 0)
+	}
+	return moves
+}
@@ -0,0 +1,70 @@
+// Usage: romfs [-level3] -o out.bin romfs.bin [overlay]
+// Usage: romfs -layered outdir romfs.bin overlay
+// Build a RomFS image from an original image
+// with the files in the overlay directory added or replaced.
+// With -layered, write only the changed files to outdir/romfs
+// for use with LayeredFS.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"xy/romfs"
+)
+
+var (
+	outname = flag.String("o", "", "write the new image to `file`")
+	layered = flag.String("layered", "", "write the changed files to `dir`/romfs")
+	level3  = flag.Bool("level3", false, "write only level 3, without the IVFC hash tree")
+)
+
+func main() {
+	flag.Parse()
+	if flag.NArg() < 1 || (*outname == "") == (*layered == "") || (*layered != "" && flag.NArg() < 2) {
+		fmt.Fprintln(os.Stderr, "usage: romfs [-level3] -o out.bin romfs.bin [overlay]")
+		fmt.Fprintln(os.Stderr, "       romfs -layered outdir romfs.bin overlay")
+		os.Exit(1)
+	}
+	if err := main1(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func main1() error {
+	orig, err := romfs.Open(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer orig.Close()
+	var overlay fs.FS
+	if flag.NArg() > 1 {
+		overlay = os.DirFS(flag.Arg(1))
+	}
+
+	if *layered != "" {
+		written, err := romfs.WriteLayered(*layered, orig, overlay)
+		for _, name := range written {
+			fmt.Println(name)
+		}
+		return err
+	}
+
+	f, err := os.Create(*outname)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	err = romfs.Build(w, orig, overlay, *level3)
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
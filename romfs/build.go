@@ -0,0 +1,657 @@
+package romfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A node is a directory or file in the image being built.
+type node struct {
+	name   []uint16
+	dir    bool
+	parent *node
+	dirs   []*node
+	files  []*node
+
+	// Original metadata, for entries read from an image.
+	orig     bool
+	metaOff  uint32
+	dataOff  uint64
+	origSize int64
+
+	// Contents of a file.
+	size int64
+	open func() (io.ReadCloser, error)
+
+	// Layout of the new image.
+	newMeta uint32
+	newData uint64
+}
+
+// tree reads the directory tree of fsys, keeping the image order.
+func (fsys *FS) tree() (*node, error) {
+	root := &node{dir: true, orig: true}
+	err := fsys.readTree(root, 0, 0)
+	return root, err
+}
+
+func (fsys *FS) readTree(n *node, off uint32, depth int) error {
+	if depth > 64 {
+		return ErrCorrupt
+	}
+	d, _, err := fsys.dir(off)
+	if err != nil {
+		return err
+	}
+	for c, i := d.Child, 0; c != empty; i++ {
+		if i > len(fsys.dirMeta)/dirMetaSize {
+			return ErrCorrupt
+		}
+		cd, name, err := fsys.dir(c)
+		if err != nil {
+			return err
+		}
+		child := &node{name: name, dir: true, parent: n, orig: true, metaOff: c}
+		n.dirs = append(n.dirs, child)
+		if err := fsys.readTree(child, c, depth+1); err != nil {
+			return err
+		}
+		c = cd.Sibling
+	}
+	for c, i := d.File, 0; c != empty; i++ {
+		if i > len(fsys.fileMeta)/fileMetaSize {
+			return ErrCorrupt
+		}
+		f, name, err := fsys.file(c)
+		if err != nil {
+			return err
+		}
+		sr := io.NewSectionReader(fsys.r, fsys.base+int64(fsys.Header.FileDataOffset)+int64(f.Offset), int64(f.Size))
+		n.files = append(n.files, &node{
+			name:     name,
+			parent:   n,
+			orig:     true,
+			metaOff:  c,
+			dataOff:  f.Offset,
+			origSize: int64(f.Size),
+			size:     int64(f.Size),
+			open: func() (io.ReadCloser, error) {
+				return io.NopCloser(io.NewSectionReader(sr, 0, sr.Size())), nil
+			},
+		})
+		c = f.Sibling
+	}
+	return nil
+}
+
+func (n *node) child(name []uint16, dir bool) *node {
+	list := n.files
+	if dir {
+		list = n.dirs
+	}
+	for _, c := range list {
+		if equal(c.name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// overlay replaces or adds the files in overlay.
+// It reports whether any directories or files were added.
+func overlay(root *node, overlay fs.FS) (added bool, err error) {
+	err = fs.WalkDir(overlay, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == "." {
+			return err
+		}
+		parent := root
+		if dir := path.Dir(p); dir != "." {
+			for _, elem := range strings.Split(dir, "/") {
+				parent = parent.child(encodeName(elem), true)
+			}
+		}
+		name := encodeName(path.Base(p))
+		if d.IsDir() {
+			if parent.child(name, true) == nil {
+				parent.dirs = append(parent.dirs, &node{name: name, dir: true, parent: parent})
+				added = true
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		f := parent.child(name, false)
+		if f == nil {
+			f = &node{name: name, parent: parent}
+			parent.files = append(parent.files, f)
+			added = true
+		}
+		f.size = info.Size()
+		f.open = func() (io.ReadCloser, error) { return overlay.Open(p) }
+		return nil
+	})
+	return added, err
+}
+
+// walk calls fn for each directory and file under n, depth first.
+func (n *node) walk(fn func(*node)) {
+	fn(n)
+	for _, d := range n.dirs {
+		d.walk(fn)
+	}
+	for _, f := range n.files {
+		fn(f)
+	}
+}
+
+// hashTableSize returns the number of buckets used
+// for a hash table of n entries.
+func hashTableSize(n int) int {
+	if n < 3 {
+		return 3
+	}
+	if n < 19 {
+		return n | 1
+	}
+	for {
+		ok := true
+		for _, p := range []int{2, 3, 5, 7, 11, 13, 17} {
+			if n%p == 0 {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return n
+		}
+		n++
+	}
+}
+
+func metaSize(fixed int, name []uint16) uint32 {
+	return uint32(fixed + (len(name)*2+3)&^3)
+}
+
+// builder holds the level 3 metadata of an image being built.
+type builder struct {
+	header   Header
+	meta     []byte // header, hash tables, and metadata, padded
+	files    []*node
+	dataSize uint64
+}
+
+const dataAlign = 0x10
+
+// layout arranges the tree into level 3.
+// If base is not nil and the tree has the same entries,
+// its metadata and hash tables are reused
+// so that an unmodified tree produces an identical image.
+func layout(root *node, base *FS) (*builder, error) {
+	var dirs, files []*node
+	root.walk(func(n *node) {
+		if n.dir {
+			dirs = append(dirs, n)
+		} else {
+			files = append(files, n)
+		}
+	})
+
+	// Files keep the order of their original data,
+	// followed by new files.
+	b := &builder{files: append([]*node(nil), files...)}
+	sort.SliceStable(b.files, func(i, j int) bool {
+		fi, fj := b.files[i], b.files[j]
+		if fi.orig != fj.orig {
+			return fi.orig
+		}
+		return fi.orig && fi.dataOff < fj.dataOff
+	})
+	// Original files stay where they were
+	// until a file grows into the next one,
+	// after which the rest are moved along.
+	var pos, shift uint64
+	for _, f := range b.files {
+		f.newData = align64(pos, dataAlign)
+		if f.orig && f.dataOff+shift >= pos {
+			f.newData = f.dataOff + shift
+		} else if f.orig {
+			shift = f.newData - f.dataOff
+		}
+		pos = f.newData + uint64(f.size)
+	}
+	b.dataSize = pos
+	if base != nil && base.ivfc != nil {
+		// Keep any padding at the end of the original.
+		if end := base.ivfc.Levels[2].Size - uint64(base.Header.FileDataOffset); end > pos {
+			b.dataSize = end
+		}
+	}
+
+	var dirHash, fileHash []uint32
+	var dirMeta, fileMeta []byte
+	if base != nil {
+		dirHash, fileHash = base.dirHash, base.fileHash
+		dirMeta = append([]byte(nil), base.dirMeta...)
+		fileMeta = append([]byte(nil), base.fileMeta...)
+		for _, f := range files {
+			f.newMeta = f.metaOff
+			p := fileMeta[f.metaOff:]
+			le.PutUint64(p[8:], f.newData)
+			le.PutUint64(p[16:], uint64(f.size))
+		}
+	} else {
+		dirSize := assign(dirs, dirMetaSize)
+		fileSize := assign(files, fileMetaSize)
+		dirHash, dirMeta = buildDirMeta(dirs, dirSize)
+		fileHash, fileMeta = buildFileMeta(files, fileSize)
+	}
+
+	h := &b.header
+	h.HeaderSize = headerSize
+	off := uint32(headerSize)
+	h.DirHashOffset, h.DirHashSize = off, uint32(len(dirHash)*4)
+	off += h.DirHashSize
+	h.DirMetaOffset, h.DirMetaSize = off, uint32(len(dirMeta))
+	off += h.DirMetaSize
+	h.FileHashOffset, h.FileHashSize = off, uint32(len(fileHash)*4)
+	off += h.FileHashSize
+	h.FileMetaOffset, h.FileMetaSize = off, uint32(len(fileMeta))
+	off += h.FileMetaSize
+	h.FileDataOffset = uint32(align(int64(off), dataAlign))
+	if base != nil {
+		h.FileDataOffset = base.Header.FileDataOffset
+	}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, le, h)
+	binary.Write(&buf, le, dirHash)
+	buf.Write(dirMeta)
+	binary.Write(&buf, le, fileHash)
+	buf.Write(fileMeta)
+	for uint32(buf.Len()) < h.FileDataOffset {
+		buf.WriteByte(0)
+	}
+	b.meta = buf.Bytes()
+	return b, nil
+}
+
+// assign sorts the directory or file entries
+// and assigns their metadata offsets.
+// Original entries keep their original order, followed by new entries.
+// It returns the size of the metadata.
+func assign(list []*node, fixed int) uint32 {
+	sort.SliceStable(list, func(i, j int) bool {
+		ni, nj := list[i], list[j]
+		if ni.orig != nj.orig {
+			return ni.orig
+		}
+		return ni.orig && ni.metaOff < nj.metaOff
+	})
+	var off uint32
+	for _, n := range list {
+		n.newMeta = off
+		off += metaSize(fixed, n.name)
+	}
+	return off
+}
+
+func buildDirMeta(dirs []*node, off uint32) ([]uint32, []byte) {
+	table := make([]uint32, hashTableSize(len(dirs)))
+	for i := range table {
+		table[i] = empty
+	}
+	meta := make([]byte, off)
+	for _, d := range dirs {
+		p := meta[d.newMeta:]
+		parent := uint32(0)
+		if d.parent != nil {
+			parent = d.parent.newMeta
+		}
+		h := hashName(parent, d.name) % uint32(len(table))
+		le.PutUint32(p[0:], parent)
+		le.PutUint32(p[4:], sibling(d))
+		le.PutUint32(p[8:], first(d.dirs))
+		le.PutUint32(p[12:], first(d.files))
+		le.PutUint32(p[16:], table[h])
+		le.PutUint32(p[20:], uint32(len(d.name)*2))
+		putName(p[dirMetaSize:], d.name)
+		table[h] = d.newMeta
+	}
+	return table, meta
+}
+
+func buildFileMeta(files []*node, off uint32) ([]uint32, []byte) {
+	table := make([]uint32, hashTableSize(len(files)))
+	for i := range table {
+		table[i] = empty
+	}
+	meta := make([]byte, off)
+	for _, f := range files {
+		p := meta[f.newMeta:]
+		h := hashName(f.parent.newMeta, f.name) % uint32(len(table))
+		le.PutUint32(p[0:], f.parent.newMeta)
+		le.PutUint32(p[4:], sibling(f))
+		le.PutUint64(p[8:], f.newData)
+		le.PutUint64(p[16:], uint64(f.size))
+		le.PutUint32(p[24:], table[h])
+		le.PutUint32(p[28:], uint32(len(f.name)*2))
+		putName(p[fileMetaSize:], f.name)
+		table[h] = f.newMeta
+	}
+	return table, meta
+}
+
+func sibling(n *node) uint32 {
+	if n.parent == nil {
+		return empty
+	}
+	list := n.parent.files
+	if n.dir {
+		list = n.parent.dirs
+	}
+	for i, c := range list {
+		if c == n && i+1 < len(list) {
+			return list[i+1].newMeta
+		}
+	}
+	return empty
+}
+
+func first(list []*node) uint32 {
+	if len(list) == 0 {
+		return empty
+	}
+	return list[0].newMeta
+}
+
+func putName(p []byte, name []uint16) {
+	for i, c := range name {
+		le.PutUint16(p[i*2:], c)
+	}
+}
+
+// size returns the size of level 3.
+func (b *builder) size() int64 {
+	return int64(len(b.meta)) + int64(b.dataSize)
+}
+
+// writeTo writes level 3 to w.
+func (b *builder) writeTo(w io.Writer) error {
+	if _, err := w.Write(b.meta); err != nil {
+		return err
+	}
+	var pos uint64
+	for _, f := range b.files {
+		if err := pad(w, int64(f.newData-pos)); err != nil {
+			return err
+		}
+		r, err := f.open()
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(w, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		if n != f.size {
+			return fmt.Errorf("romfs: %s changed size while building", decodeName(f.name))
+		}
+		pos = f.newData + uint64(f.size)
+	}
+	return pad(w, int64(b.dataSize-pos))
+}
+
+var zeros [0x1000]byte
+
+func pad(w io.Writer, n int64) error {
+	for n > 0 {
+		m := n
+		if m > int64(len(zeros)) {
+			m = int64(len(zeros))
+		}
+		if _, err := w.Write(zeros[:m]); err != nil {
+			return err
+		}
+		n -= m
+	}
+	return nil
+}
+
+// Build writes a RomFS image of orig with the files in overlay
+// added or replaced, as a full IVFC image.
+// If level3 is true, only level 3 is written.
+//
+// When no files or directories are added,
+// the original metadata is kept,
+// so an empty overlay reproduces the original image.
+func Build(w io.Writer, orig *FS, over fs.FS, level3 bool) error {
+	root, err := orig.tree()
+	if err != nil {
+		return err
+	}
+	base := orig
+	if over != nil {
+		added, err := overlay(root, over)
+		if err != nil {
+			return err
+		}
+		if added {
+			base = nil
+		}
+	}
+	b, err := layout(root, base)
+	if err != nil {
+		return err
+	}
+	if level3 {
+		return b.writeTo(w)
+	}
+
+	// Hash level 3, then each level above it.
+	// Each level holds the hashes of the blocks of the level below.
+	blockLog := [3]uint32{12, 12, 12}
+	if orig.ivfc != nil {
+		for i, l := range orig.ivfc.Levels {
+			blockLog[i] = l.BlockSizeLog
+		}
+	}
+	var block [3]int64
+	for i, n := range blockLog {
+		block[i] = 1 << n
+	}
+	h := newBlockHasher(block[2])
+	if err := b.writeTo(h); err != nil {
+		return err
+	}
+	l2 := h.Sum()
+	l1 := hashBlocks(l2, block[1])
+	master := hashBlocks(l1, block[0])
+
+	hdr := IVFCHeader{
+		Magic:          IVFCMagic,
+		Version:        0x10000,
+		MasterHashSize: uint32(len(master)),
+		HeaderSize:     0x5C,
+	}
+	sizes := []int64{int64(len(l1)), int64(len(l2)), b.size()}
+	var logical int64
+	for i, size := range sizes {
+		logical = align(logical, block[i])
+		hdr.Levels[i] = LevelHeader{
+			Offset:       uint64(logical),
+			Size:         uint64(size),
+			BlockSizeLog: blockLog[i],
+		}
+		logical += size
+	}
+
+	cw := &countWriter{w: w}
+	if err := binary.Write(cw, le, &hdr); err != nil {
+		return err
+	}
+	if _, err := cw.Write(master); err != nil {
+		return err
+	}
+	if err := pad(cw, hdr.Level3Offset()-cw.n); err != nil {
+		return err
+	}
+	if err := b.writeTo(cw); err != nil {
+		return err
+	}
+	for i, level := range [][]byte{l1, l2} {
+		if err := pad(cw, align(cw.n, block[i])-cw.n); err != nil {
+			return err
+		}
+		if _, err := cw.Write(level); err != nil {
+			return err
+		}
+	}
+	return pad(cw, align(cw.n, block[1])-cw.n)
+}
+
+// A blockHasher computes the SHA-256 hash of each block written to it.
+// The last block is padded with zeros.
+type blockHasher struct {
+	block  []byte
+	n      int
+	hashes []byte
+}
+
+func newBlockHasher(size int64) *blockHasher {
+	return &blockHasher{block: make([]byte, size)}
+}
+
+func (h *blockHasher) Write(p []byte) (int, error) {
+	total := len(p)
+	for len(p) > 0 {
+		m := copy(h.block[h.n:], p)
+		h.n += m
+		p = p[m:]
+		if h.n == len(h.block) {
+			h.flush()
+		}
+	}
+	return total, nil
+}
+
+func (h *blockHasher) flush() {
+	for i := h.n; i < len(h.block); i++ {
+		h.block[i] = 0
+	}
+	sum := sha256.Sum256(h.block)
+	h.hashes = append(h.hashes, sum[:]...)
+	h.n = 0
+}
+
+// Sum returns the hashes of the blocks written so far.
+func (h *blockHasher) Sum() []byte {
+	if h.n > 0 {
+		h.flush()
+	}
+	return h.hashes
+}
+
+func align64(n, a uint64) uint64 {
+	return (n + a - 1) / a * a
+}
+
+func hashBlocks(b []byte, size int64) []byte {
+	h := newBlockHasher(size)
+	h.Write(b)
+	return h.Sum()
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// WriteLayered writes the files in overlay which differ from orig
+// to dir/romfs, for use as a LayeredFS mod.
+// It returns the paths of the files written.
+func WriteLayered(dir string, orig *FS, over fs.FS) ([]string, error) {
+	var written []string
+	err := fs.WalkDir(over, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		same, err := sameFile(orig, over, p)
+		if err != nil || same {
+			return err
+		}
+		b, err := fs.ReadFile(over, p)
+		if err != nil {
+			return err
+		}
+		out := filepath.Join(dir, "romfs", filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(out), 0777); err != nil {
+			return err
+		}
+		if err := os.WriteFile(out, b, 0666); err != nil {
+			return err
+		}
+		written = append(written, p)
+		return nil
+	})
+	return written, err
+}
+
+// sameFile reports whether the file named p is the same in both file systems.
+func sameFile(a *FS, b fs.FS, p string) (bool, error) {
+	fa, err := a.Open(p)
+	if err != nil {
+		return false, nil
+	}
+	defer fa.Close()
+	fb, err := b.Open(p)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+	ia, _ := fa.Stat()
+	ib, err := fb.Stat()
+	if err != nil {
+		return false, err
+	}
+	if ia.IsDir() || ia.Size() != ib.Size() {
+		return false, nil
+	}
+	var ba, bb [0x8000]byte
+	for {
+		na, erra := io.ReadFull(fa, ba[:])
+		nb, errb := io.ReadFull(fb, bb[:])
+		if !bytes.Equal(ba[:na], bb[:nb]) {
+			return false, nil
+		}
+		if erra != nil || errb != nil {
+			if (erra == io.EOF || erra == io.ErrUnexpectedEOF) && (errb == io.EOF || errb == io.ErrUnexpectedEOF) {
+				return true, nil
+			}
+			if errb != nil && errb != io.EOF && errb != io.ErrUnexpectedEOF {
+				return false, errb
+			}
+			return false, erra
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package romfs
+
+import (
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Open opens the named file or directory.
+// Files implement io.ReaderAt and io.Seeker,
+// so they can be passed directly to garc.Files.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	e, err := fsys.lookup(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if e.IsDir() {
+		return &dir{fsys: fsys, entry: e, next: empty}, nil
+	}
+	sr := io.NewSectionReader(fsys.r, fsys.base+int64(fsys.Header.FileDataOffset)+int64(e.offset), e.size)
+	return &File{entry: e, SectionReader: sr}, nil
+}
+
+// Stat returns information about the named file or directory.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	e, err := fsys.lookup(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return e, nil
+}
+
+// ReadDir reads the named directory
+// and returns its entries sorted by name.
+// Reading the directory itself with fs.ReadDirFile
+// returns its subdirectories followed by its files, in image order.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := f.(*dir)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	list, err := d.ReadDir(-1)
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list, err
+}
+
+func (fsys *FS) lookup(name string) (*entry, error) {
+	off := uint32(0)
+	if name == "." {
+		return fsys.dirEntry(off)
+	}
+	elems := strings.Split(name, "/")
+	for i, elem := range elems {
+		s := encodeName(elem)
+		if i == len(elems)-1 {
+			if f, ok := fsys.lookupFile(off, s); ok {
+				return fsys.fileEntry(f)
+			}
+		}
+		d, ok := fsys.lookupDir(off, s)
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		off = d
+	}
+	return fsys.dirEntry(off)
+}
+
+// An entry is a directory or file in the image.
+// It implements fs.FileInfo and fs.DirEntry.
+type entry struct {
+	name   string
+	dir    bool
+	off    uint32 // offset of the metadata
+	offset uint64 // offset of the data
+	size   int64
+	first  [2]uint32 // first subdirectory and file
+}
+
+func (fsys *FS) dirEntry(off uint32) (*entry, error) {
+	d, name, err := fsys.dir(off)
+	if err != nil {
+		return nil, err
+	}
+	return &entry{
+		name:  decodeName(name),
+		dir:   true,
+		off:   off,
+		first: [2]uint32{d.Child, d.File},
+	}, nil
+}
+
+func (fsys *FS) fileEntry(off uint32) (*entry, error) {
+	f, name, err := fsys.file(off)
+	if err != nil {
+		return nil, err
+	}
+	return &entry{
+		name:   decodeName(name),
+		off:    off,
+		offset: f.Offset,
+		size:   int64(f.Size),
+	}, nil
+}
+
+func (e *entry) Name() string {
+	if e.name == "" && e.dir {
+		return "."
+	}
+	return e.name
+}
+
+func (e *entry) Size() int64 { return e.size }
+
+func (e *entry) Mode() fs.FileMode {
+	if e.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (e *entry) Type() fs.FileMode          { return e.Mode().Type() }
+func (e *entry) ModTime() time.Time         { return time.Time{} }
+func (e *entry) IsDir() bool                { return e.dir }
+func (e *entry) Sys() interface{}           { return nil }
+func (e *entry) Info() (fs.FileInfo, error) { return e, nil }
+
+// A File is an open file in the image.
+type File struct {
+	entry *entry
+	*io.SectionReader
+}
+
+func (f *File) Stat() (fs.FileInfo, error) { return f.entry, nil }
+func (f *File) Close() error               { return nil }
+
+// Offset returns the offset of the file data in the image.
+func (f *File) Offset() int64 {
+	_, off, _ := f.SectionReader.Outer()
+	return off
+}
+
+// dir is an open directory.
+type dir struct {
+	fsys  *FS
+	entry *entry
+	files bool   // listing files rather than subdirectories
+	next  uint32 // next entry to list, or empty to start
+	done  bool
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) { return d.entry, nil }
+func (d *dir) Close() error               { return nil }
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.entry.name, Err: fs.ErrInvalid}
+}
+
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	var list []fs.DirEntry
+	if d.next == empty && !d.done {
+		d.next = d.entry.first[0]
+		if d.next == empty {
+			d.files = true
+			d.next = d.entry.first[1]
+		}
+		d.done = d.next == empty
+	}
+	for !d.done && (n <= 0 || len(list) < n) {
+		var e *entry
+		var sibling uint32
+		if d.files {
+			f, _, err := d.fsys.file(d.next)
+			if err != nil {
+				return list, err
+			}
+			sibling = f.Sibling
+			e, err = d.fsys.fileEntry(d.next)
+			if err != nil {
+				return list, err
+			}
+		} else {
+			dm, _, err := d.fsys.dir(d.next)
+			if err != nil {
+				return list, err
+			}
+			sibling = dm.Sibling
+			e, err = d.fsys.dirEntry(d.next)
+			if err != nil {
+				return list, err
+			}
+		}
+		list = append(list, e)
+		d.next = sibling
+		if d.next == empty && !d.files {
+			d.files = true
+			d.next = d.entry.first[1]
+		}
+		d.done = d.next == empty
+	}
+	if n > 0 && len(list) == 0 {
+		return nil, io.EOF
+	}
+	return list, nil
+}
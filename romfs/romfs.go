@@ -0,0 +1,328 @@
+// Package romfs reads decrypted RomFS images.
+//
+// A RomFS image is wrapped in an IVFC hash tree.
+// The file system itself is the third level of the tree,
+// which holds the directory and file metadata and the file data.
+// Directories and files are found by name through hash tables.
+package romfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf16"
+)
+
+var le = binary.LittleEndian
+
+var (
+	ErrHeader  = errors.New("romfs: invalid header")
+	ErrCorrupt = errors.New("romfs: corrupt metadata")
+)
+
+var IVFCMagic = [4]byte{'I', 'V', 'F', 'C'}
+
+// IVFCHeader is the header of a RomFS image.
+// The master hash follows the header at 0x60,
+// and level 3 starts at the next level 3 block boundary.
+type IVFCHeader struct {
+	Magic          [4]byte
+	Version        uint32 // always 0x10000
+	MasterHashSize uint32
+	Levels         [3]LevelHeader
+	HeaderSize     uint32 // always 0x5C
+	_              uint32
+}
+
+type LevelHeader struct {
+	Offset       uint64 // logical offset
+	Size         uint64
+	BlockSizeLog uint32
+	_            uint32
+}
+
+const ivfcHeaderSize = 0x60
+
+// Level3Offset returns the offset of level 3 in the image.
+func (h *IVFCHeader) Level3Offset() int64 {
+	block := int64(1) << h.Levels[2].BlockSizeLog
+	return align(ivfcHeaderSize+int64(h.MasterHashSize), block)
+}
+
+// Header is the header of level 3.
+// Offsets are relative to the start of level 3.
+type Header struct {
+	HeaderSize     uint32 // always 0x28
+	DirHashOffset  uint32
+	DirHashSize    uint32
+	DirMetaOffset  uint32
+	DirMetaSize    uint32
+	FileHashOffset uint32
+	FileHashSize   uint32
+	FileMetaOffset uint32
+	FileMetaSize   uint32
+	FileDataOffset uint32
+}
+
+const headerSize = 0x28
+
+// empty marks the end of a list in the metadata.
+const empty = 0xFFFFFFFF
+
+// dirMeta is a directory metadata entry.
+// The root directory is at offset 0.
+type dirMeta struct {
+	Parent  uint32
+	Sibling uint32
+	Child   uint32 // first subdirectory
+	File    uint32 // first file
+	Next    uint32 // next entry in the hash bucket
+	NameLen uint32
+}
+
+const dirMetaSize = 0x18
+
+// fileMeta is a file metadata entry.
+type fileMeta struct {
+	Parent  uint32
+	Sibling uint32
+	Offset  uint64 // relative to the file data
+	Size    uint64
+	Next    uint32 // next entry in the hash bucket
+	NameLen uint32
+}
+
+const fileMetaSize = 0x20
+
+// hashName is the hash function used for the hash tables.
+func hashName(parent uint32, name []uint16) uint32 {
+	h := parent ^ 123456789
+	for _, c := range name {
+		h = (h>>5 | h<<27) ^ uint32(c)
+	}
+	return h
+}
+
+// An FS is a RomFS image.
+// It implements fs.FS.
+type FS struct {
+	Header Header
+
+	ivfc     *IVFCHeader // nil for a bare level 3 image
+	r        io.ReaderAt
+	c        io.Closer
+	base     int64 // offset of level 3
+	dirHash  []uint32
+	fileHash []uint32
+	dirMeta  []byte
+	fileMeta []byte
+}
+
+// Open opens the named RomFS image.
+func Open(name string) (*FS, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fsys, err := Read(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	fsys.c = f
+	return fsys, nil
+}
+
+// Close closes the image, if it was opened by Open.
+func (fsys *FS) Close() error {
+	if fsys.c == nil {
+		return nil
+	}
+	return fsys.c.Close()
+}
+
+// Read reads the metadata of a RomFS image.
+// The image can be either a full IVFC image, such as romfs.bin,
+// or just level 3.
+// File data is read from r as needed.
+func Read(r io.ReaderAt) (*FS, error) {
+	var magic [4]byte
+	if _, err := r.ReadAt(magic[:], 0); err != nil {
+		return nil, ErrHeader
+	}
+	var ivfc *IVFCHeader
+	var base int64
+	if magic == IVFCMagic {
+		ivfc = new(IVFCHeader)
+		err := binary.Read(io.NewSectionReader(r, 0, ivfcHeaderSize), le, ivfc)
+		if err != nil {
+			return nil, ErrHeader
+		}
+		for _, l := range ivfc.Levels {
+			if l.BlockSizeLog >= 32 {
+				return nil, ErrHeader
+			}
+		}
+		base = ivfc.Level3Offset()
+	}
+	return readLevel3(r, ivfc, base)
+}
+
+func readLevel3(r io.ReaderAt, ivfc *IVFCHeader, base int64) (*FS, error) {
+	fsys := &FS{r: r, ivfc: ivfc, base: base}
+	err := binary.Read(io.NewSectionReader(r, base, headerSize), le, &fsys.Header)
+	if err != nil {
+		return nil, ErrHeader
+	}
+	h := &fsys.Header
+	if h.HeaderSize != headerSize {
+		return nil, ErrHeader
+	}
+	if fsys.dirHash, err = fsys.readHash(h.DirHashOffset, h.DirHashSize); err != nil {
+		return nil, err
+	}
+	if fsys.fileHash, err = fsys.readHash(h.FileHashOffset, h.FileHashSize); err != nil {
+		return nil, err
+	}
+	if fsys.dirMeta, err = fsys.read(h.DirMetaOffset, h.DirMetaSize); err != nil {
+		return nil, err
+	}
+	if fsys.fileMeta, err = fsys.read(h.FileMetaOffset, h.FileMetaSize); err != nil {
+		return nil, err
+	}
+	if len(fsys.dirHash) == 0 || len(fsys.dirMeta) < dirMetaSize {
+		return nil, ErrCorrupt
+	}
+	return fsys, nil
+}
+
+func (fsys *FS) read(off, size uint32) ([]byte, error) {
+	b := make([]byte, size)
+	_, err := fsys.r.ReadAt(b, fsys.base+int64(off))
+	if err != nil {
+		return nil, fmt.Errorf("romfs: reading metadata: %v", err)
+	}
+	return b, nil
+}
+
+func (fsys *FS) readHash(off, size uint32) ([]uint32, error) {
+	b, err := fsys.read(off, size)
+	if err != nil {
+		return nil, err
+	}
+	t := make([]uint32, len(b)/4)
+	for i := range t {
+		t[i] = le.Uint32(b[i*4:])
+	}
+	return t, nil
+}
+
+// dir returns the directory entry at off.
+func (fsys *FS) dir(off uint32) (dirMeta, []uint16, error) {
+	var d dirMeta
+	if int64(off)+dirMetaSize > int64(len(fsys.dirMeta)) {
+		return d, nil, ErrCorrupt
+	}
+	b := fsys.dirMeta[off:]
+	d = dirMeta{
+		Parent:  le.Uint32(b[0:]),
+		Sibling: le.Uint32(b[4:]),
+		Child:   le.Uint32(b[8:]),
+		File:    le.Uint32(b[12:]),
+		Next:    le.Uint32(b[16:]),
+		NameLen: le.Uint32(b[20:]),
+	}
+	name, err := utf16Name(b[dirMetaSize:], d.NameLen)
+	return d, name, err
+}
+
+// file returns the file entry at off.
+func (fsys *FS) file(off uint32) (fileMeta, []uint16, error) {
+	var f fileMeta
+	if int64(off)+fileMetaSize > int64(len(fsys.fileMeta)) {
+		return f, nil, ErrCorrupt
+	}
+	b := fsys.fileMeta[off:]
+	f = fileMeta{
+		Parent:  le.Uint32(b[0:]),
+		Sibling: le.Uint32(b[4:]),
+		Offset:  le.Uint64(b[8:]),
+		Size:    le.Uint64(b[16:]),
+		Next:    le.Uint32(b[24:]),
+		NameLen: le.Uint32(b[28:]),
+	}
+	name, err := utf16Name(b[fileMetaSize:], f.NameLen)
+	return f, name, err
+}
+
+func utf16Name(b []byte, n uint32) ([]uint16, error) {
+	if int64(n) > int64(len(b)) || n%2 != 0 {
+		return nil, ErrCorrupt
+	}
+	name := make([]uint16, n/2)
+	for i := range name {
+		name[i] = le.Uint16(b[i*2:])
+	}
+	return name, nil
+}
+
+func equal(a, b []uint16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupDir finds the subdirectory of parent with the given name.
+func (fsys *FS) lookupDir(parent uint32, name []uint16) (uint32, bool) {
+	off := fsys.dirHash[hashName(parent, name)%uint32(len(fsys.dirHash))]
+	for n := 0; off != empty && n < len(fsys.dirMeta)/dirMetaSize; n++ {
+		d, dname, err := fsys.dir(off)
+		if err != nil {
+			return 0, false
+		}
+		if d.Parent == parent && equal(dname, name) {
+			return off, true
+		}
+		off = d.Next
+	}
+	return 0, false
+}
+
+// lookupFile finds the file in parent with the given name.
+func (fsys *FS) lookupFile(parent uint32, name []uint16) (uint32, bool) {
+	if len(fsys.fileHash) == 0 {
+		return 0, false
+	}
+	off := fsys.fileHash[hashName(parent, name)%uint32(len(fsys.fileHash))]
+	for n := 0; off != empty && n < len(fsys.fileMeta)/fileMetaSize; n++ {
+		f, fname, err := fsys.file(off)
+		if err != nil {
+			return 0, false
+		}
+		if f.Parent == parent && equal(fname, name) {
+			return off, true
+		}
+		off = f.Next
+	}
+	return 0, false
+}
+
+func encodeName(s string) []uint16 {
+	return utf16.Encode([]rune(s))
+}
+
+func decodeName(name []uint16) string {
+	return string(utf16.Decode(name))
+}
+
+func align(n, a int64) int64 {
+	return (n + a - 1) / a * a
+}
@@ -0,0 +1,548 @@
+// Usage: serve [-oras] [-addr :8080] [-iconmap code.bin:offset -itemicons path] romfs
+// Serve the game data over HTTP.
+// Everything is read from the romfs as it is requested,
+// so edits to an extracted romfs show up on the next reload.
+//
+// Pages:
+//
+//	/pokemon-stats           base stats, as written by pokemon-stats
+//	/move-stats              moves, as written by move-stats
+//	/item-stats              items, as written by item-stats
+//
+// Data, as JSON unless ?format=csv or ?format=yaml is given:
+//
+//	/pokemon, /pokemon/{n}   base stats of all Pokémon or one, by index or name
+//	/moves, /moves/{n}       moves
+//	/items, /items/{n}       items
+//	/text/{lang}/{n}         lines of a text file; ?story=1 for the story text
+//
+// Files:
+//
+//	/garc/{path}/{n}         file n of the GARC at path; ?decompress=1 to LZ-decode it
+//	/icons/{path}/{n}.png    file n of the GARC at path, decoded as an icon
+//	/items/{n}.png           item icon n, from the -itemicons GARC
+//
+// Item icons need the table mapping items to icons,
+// which is in code.bin at a version-dependent offset.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"xy/export"
+	"xy/garc"
+	"xy/image/ctr"
+	"xy/lz"
+	"xy/names"
+	"xy/pages"
+	"xy/stats"
+	"xy/text"
+	"xy/util"
+)
+
+var (
+	oras      = flag.Bool("oras", false, "the romfs is from Omega Ruby or Alpha Sapphire")
+	addr      = flag.String("addr", "localhost:8080", "listen on `address`")
+	iconmap   = flag.String("iconmap", "", "read the item icon table from `file:offset`")
+	itemicons = flag.String("itemicons", "", "romfs `path` of the item icon GARC")
+)
+
+func die(v ...interface{}) {
+	fmt.Fprintln(os.Stderr, v...)
+	os.Exit(1)
+}
+
+// A server serves one romfs.
+type server struct {
+	romfs fs.FS
+	v     names.Version
+	files stats.Files
+}
+
+// An httpError is an error with an HTTP status.
+type httpError struct {
+	code int
+	msg  string
+}
+
+func (e *httpError) Error() string { return e.msg }
+
+func notFound(format string, v ...interface{}) error {
+	return &httpError{http.StatusNotFound, fmt.Sprintf(format, v...)}
+}
+
+func badRequest(format string, v ...interface{}) error {
+	return &httpError{http.StatusBadRequest, fmt.Sprintf(format, v...)}
+}
+
+// handler adapts a function returning an error to an http.Handler.
+type handler func(w http.ResponseWriter, r *http.Request) error
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	err := h(w, r)
+	if err == nil {
+		return
+	}
+	code := http.StatusInternalServerError
+	var he *httpError
+	if errors.As(err, &he) {
+		code = he.code
+	} else if errors.Is(err, fs.ErrNotExist) {
+		code = http.StatusNotFound
+	}
+	if code == http.StatusInternalServerError {
+		log.Printf("%s: %v", r.URL.Path, err)
+	}
+	http.Error(w, err.Error(), code)
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() < 1 {
+		die("usage: serve [-oras] [-addr :8080] [-iconmap code.bin:offset -itemicons path] romfs")
+	}
+	romfs, err := util.OpenFS(flag.Arg(0))
+	if err != nil {
+		die(err)
+	}
+	s := &server{romfs: romfs, v: names.XY}
+	if *oras {
+		s.v = names.ORAS
+	}
+	s.files = stats.GameFiles[s.v]
+
+	http.Handle("/", handler(s.index))
+	http.Handle("/pokemon-stats", handler(s.pokemonPage))
+	http.Handle("/move-stats", handler(s.movePage))
+	http.Handle("/item-stats", handler(s.itemPage))
+	http.Handle("/pokemon", handler(s.pokemon))
+	http.Handle("/pokemon/", handler(s.pokemon))
+	http.Handle("/moves", handler(s.moves))
+	http.Handle("/moves/", handler(s.moves))
+	http.Handle("/items", handler(s.items))
+	http.Handle("/items/", handler(s.items))
+	http.Handle("/text/", handler(s.text))
+	http.Handle("/garc/", handler(s.garc))
+	http.Handle("/icons/", handler(s.icon))
+
+	log.Printf("serving %s on http://%s/", flag.Arg(0), *addr)
+	die(http.ListenAndServe(*addr, nil))
+}
+
+const indexText = `<!DOCTYPE html>
+<meta charset="utf-8">
+<title>romfs</title>
+<ul>
+<li><a href="pokemon-stats">Pokémon</a>
+<li><a href="move-stats">Moves</a>
+<li><a href="item-stats">Items</a>
+</ul>
+`
+
+func (s *server) index(w http.ResponseWriter, r *http.Request) error {
+	if r.URL.Path != "/" {
+		return notFound("not found")
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err := io.WriteString(w, indexText)
+	return err
+}
+
+// open returns the files of the GARC at the romfs path name.
+// The caller must close it.
+func (s *server) open(name string) (*util.GARC, error) {
+	g, err := util.OpenGARCFS(s.romfs, name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return g, err
+}
+
+// splitIndex splits "path/n" into path and n.
+func splitIndex(p string) (string, int, error) {
+	i := strings.LastIndex(p, "/")
+	if i < 0 {
+		return "", 0, notFound("no file number in %q", p)
+	}
+	n, err := strconv.Atoi(p[i+1:])
+	if err != nil || n < 0 {
+		return "", 0, notFound("bad file number %q", p[i+1:])
+	}
+	return p[:i], n, nil
+}
+
+// file returns file n of the GARC.
+func file(g *util.GARC, name string, n int) (*garc.File, error) {
+	if n >= len(g.Files) {
+		return nil, notFound("%s has %d files", name, len(g.Files))
+	}
+	return g.Files[n], nil
+}
+
+func (s *server) readPokemon() ([]pages.Pokemon, error) {
+	g, err := s.open(s.files.Personal)
+	if err != nil {
+		return nil, err
+	}
+	defer g.Close()
+	return pages.ReadPokemon(g.Files)
+}
+
+func (s *server) readMoves() ([]pages.Move, error) {
+	g, err := s.open(s.files.Moves)
+	if err != nil {
+		return nil, err
+	}
+	defer g.Close()
+	return pages.ReadMoves(g.Files)
+}
+
+func (s *server) readItems() ([]pages.Item, error) {
+	g, err := s.open(s.files.Items)
+	if err != nil {
+		return nil, err
+	}
+	defer g.Close()
+	var m []uint32
+	if *iconmap != "" {
+		m, err = readiconmap(*iconmap, len(g.Files))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pages.ReadItems(g.Files, m)
+}
+
+// writePage writes the page to a buffer first,
+// so that an error can still be reported.
+func writePage(w http.ResponseWriter, write func(io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func (s *server) pokemonPage(w http.ResponseWriter, r *http.Request) error {
+	pokemon, err := s.readPokemon()
+	if err != nil {
+		return err
+	}
+	return writePage(w, func(w io.Writer) error { return pages.WritePokemon(w, pokemon) })
+}
+
+func (s *server) movePage(w http.ResponseWriter, r *http.Request) error {
+	moves, err := s.readMoves()
+	if err != nil {
+		return err
+	}
+	return writePage(w, func(w io.Writer) error { return pages.WriteMoves(w, moves) })
+}
+
+func (s *server) itemPage(w http.ResponseWriter, r *http.Request) error {
+	items, err := s.readItems()
+	if err != nil {
+		return err
+	}
+	return writePage(w, func(w io.Writer) error { return pages.WriteItems(w, items) })
+}
+
+// writeTable writes the table in the format asked for, JSON by default.
+// If one is true, the table has one record, which is written by itself.
+func writeTable(w http.ResponseWriter, r *http.Request, table []export.Record, one bool) error {
+	format := r.FormValue("format")
+	if format == "" {
+		format = "json"
+	}
+	if !export.IsFormat(format) {
+		return badRequest("unknown format %q", format)
+	}
+	var buf bytes.Buffer
+	var err error
+	if one && format == "json" {
+		err = writeJSON(&buf, table[0])
+	} else {
+		err = export.Write(&buf, format, table)
+	}
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// writeJSON writes a single record.
+func writeJSON(w io.Writer, rec export.Record) error {
+	b, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// selectRecord returns the records for a request for prefix or prefix/key.
+// The key is an index or a name, which lookup converts to an index.
+func selectRecord(r *http.Request, prefix string, table []export.Record, lookup export.Lookup) ([]export.Record, bool, error) {
+	key := strings.TrimPrefix(r.URL.Path, prefix)
+	key = strings.TrimPrefix(key, "/")
+	if key == "" {
+		return table, false, nil
+	}
+	n, err := strconv.Atoi(key)
+	if err != nil {
+		var ok bool
+		n, ok = lookup(key)
+		if !ok {
+			return nil, false, notFound("no %s named %q", strings.TrimPrefix(prefix, "/"), key)
+		}
+	}
+	if n < 0 || n >= len(table) {
+		return nil, false, notFound("%s %d out of range", strings.TrimPrefix(prefix, "/"), n)
+	}
+	return table[n : n+1], true, nil
+}
+
+func (s *server) pokemon(w http.ResponseWriter, r *http.Request) error {
+	pokemon, err := s.readPokemon()
+	if err != nil {
+		return err
+	}
+	table := make([]export.Record, len(pokemon))
+	for i := range pokemon {
+		table[i] = pokemon[i].Record()
+	}
+	table, one, err := selectRecord(r, "/pokemon", table, names.SpeciesID)
+	if err != nil {
+		return err
+	}
+	return writeTable(w, r, table, one)
+}
+
+func (s *server) moves(w http.ResponseWriter, r *http.Request) error {
+	moves, err := s.readMoves()
+	if err != nil {
+		return err
+	}
+	table := make([]export.Record, len(moves))
+	for i := range moves {
+		table[i] = moves[i].Record()
+	}
+	table, one, err := selectRecord(r, "/moves", table, names.MoveID)
+	if err != nil {
+		return err
+	}
+	return writeTable(w, r, table, one)
+}
+
+func (s *server) items(w http.ResponseWriter, r *http.Request) error {
+	if strings.HasSuffix(r.URL.Path, ".png") {
+		return s.itemIcon(w, r)
+	}
+	items, err := s.readItems()
+	if err != nil {
+		return err
+	}
+	table := make([]export.Record, len(items))
+	for i := range items {
+		table[i] = items[i].Record()
+	}
+	table, one, err := selectRecord(r, "/items", table, names.ItemID)
+	if err != nil {
+		return err
+	}
+	return writeTable(w, r, table, one)
+}
+
+// text serves /text/{lang}/{n} as a JSON list of lines.
+func (s *server) text(w http.ResponseWriter, r *http.Request) error {
+	lang, n, err := splitIndex(strings.TrimPrefix(r.URL.Path, "/text/"))
+	if err != nil {
+		return err
+	}
+	common, story, err := names.TextArchives(s.v, lang)
+	if err != nil {
+		return notFound("%v", err)
+	}
+	name := common
+	if r.FormValue("story") != "" {
+		name = story
+	}
+	g, err := s.open(name)
+	if err != nil {
+		return err
+	}
+	defer g.Close()
+	f, err := file(g, name, n)
+	if err != nil {
+		return err
+	}
+	lines, err := text.Read(f)
+	if err != nil {
+		return fmt.Errorf("%s: file %d: %v", name, n, err)
+	}
+	if lines == nil {
+		lines = []string{}
+	}
+	b, err := json.MarshalIndent(lines, "", "  ")
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// garc serves /garc/{path}/{n}.
+func (s *server) garc(w http.ResponseWriter, r *http.Request) error {
+	name, n, err := splitIndex(strings.TrimPrefix(r.URL.Path, "/garc/"))
+	if err != nil {
+		return err
+	}
+	g, err := s.open(name)
+	if err != nil {
+		return err
+	}
+	defer g.Close()
+	f, err := file(g, name, n)
+	if err != nil {
+		return err
+	}
+	var data []byte
+	if r.FormValue("decompress") != "" {
+		data, err = lz.Decode(f)
+		if err != nil {
+			return badRequest("%s: file %d: %v", name, n, err)
+		}
+	} else {
+		data = make([]byte, f.Size())
+		if _, err := f.ReadAt(data, 0); err != nil {
+			return err
+		}
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	_, err = w.Write(data)
+	return err
+}
+
+// icon serves /icons/{path}/{n}.png.
+func (s *server) icon(w http.ResponseWriter, r *http.Request) error {
+	p := strings.TrimPrefix(r.URL.Path, "/icons/")
+	if !strings.HasSuffix(p, ".png") {
+		return notFound("not found")
+	}
+	name, n, err := splitIndex(strings.TrimSuffix(p, ".png"))
+	if err != nil {
+		return err
+	}
+	return s.writeIcon(w, name, n)
+}
+
+// itemIcon serves /items/{n}.png, as linked from the items page.
+func (s *server) itemIcon(w http.ResponseWriter, r *http.Request) error {
+	if *itemicons == "" {
+		return notFound("no item icons; use -itemicons")
+	}
+	_, n, err := splitIndex(strings.TrimSuffix(r.URL.Path, ".png"))
+	if err != nil {
+		return err
+	}
+	return s.writeIcon(w, *itemicons, n)
+}
+
+func (s *server) writeIcon(w http.ResponseWriter, name string, n int) error {
+	g, err := s.open(name)
+	if err != nil {
+		return err
+	}
+	defer g.Close()
+	f, err := file(g, name, n)
+	if err != nil {
+		return err
+	}
+	m, err := decodeIcon(f)
+	if err != nil {
+		return badRequest("%s: file %d: %v", name, n, err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, m); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "image/png")
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+func decodeIcon(f *garc.File) (image.Image, error) {
+	z, err := lz.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	// Most icons are paletted, but some are stored
+	// in one of the ordinary texture formats, such as ETC1.
+	foot, err := ctr.ReadFooter(z)
+	if err != nil {
+		return nil, err
+	}
+	w, h := int(foot.Imag.Width), int(foot.Imag.Height)
+	if len(z)-foot.Size() == ctr.DataSize(w, h, foot.Imag.Format) {
+		return ctr.Decode(z)
+	}
+	return ctr.DecodePaletted(z)
+}
+
+func readiconmap(filename string, n int) ([]uint32, error) {
+	filename, offstr := partition(filename, ":")
+
+	off, err := strconv.ParseInt(offstr, 0, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(off, 0); err != nil {
+		return nil, err
+	}
+
+	m := make([]uint32, n)
+	err = binary.Read(f, binary.LittleEndian, m)
+	return m, err
+}
+
+func partition(s string, sep string) (front, back string) {
+	i := strings.Index(s, sep)
+	if i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
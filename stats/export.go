@@ -0,0 +1,447 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"xy/export"
+	"xy/names"
+)
+
+// Names of enumerated values in exported records.
+var (
+	// StatNames are the stats in the order of PokemonStats.Stat.
+	StatNames = []string{"hp", "attack", "defense", "speed", "special_attack", "special_defense"}
+
+	GrowthRates = []string{"medium-fast", "erratic", "fluctuating", "medium-slow", "fast", "slow"}
+
+	Colors = []string{"red", "blue", "yellow", "green", "black", "brown", "purple", "gray", "white", "pink"}
+
+	EggGroups = []string{
+		"",
+		"monster",
+		"water1",
+		"bug",
+		"flying",
+		"ground",
+		"fairy",
+		"plant",
+		"humanshape",
+		"water3",
+		"mineral",
+		"indeterminate",
+		"water2",
+		"ditto",
+		"dragon",
+		"no-eggs",
+	}
+
+	// DamageClasses are the values of MoveStats.DamageClassCode.
+	DamageClasses = []string{"status", "physical", "special"}
+
+	// StatusNames are the values of MoveStats.StatusCode.
+	StatusNames = []string{"", "paralyze", "sleep", "freeze", "burn", "poison", "confuse", "infatuate", "trap"}
+
+	// MoveStatNames are the values of MoveStats.StatType.
+	MoveStatNames = []string{"", "attack", "defense", "special-attack", "special-defense", "speed", "accuracy", "evasion", "all"}
+)
+
+// Enum returns the name of v in list, or v as a number.
+// The name of 0 may be empty.
+func Enum(list []string, v int) string {
+	if 0 <= v && v < len(list) && (list[v] != "" || v == 0) {
+		return list[v]
+	}
+	return fmt.Sprint(v)
+}
+
+// EnumLookup returns a Lookup for the names in list.
+func EnumLookup(list []string) export.Lookup {
+	return func(name string) (int, bool) {
+		for i, s := range list {
+			if s != "" && s == name {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+}
+
+// nameOr returns s, or n as a number if s is empty,
+// so that unnamed values read back unchanged.
+func nameOr(s string, n int) string {
+	if s == "" && n != 0 {
+		return fmt.Sprint(n)
+	}
+	return s
+}
+
+// Encode returns the binary encoding of v written over a copy of orig,
+// keeping any bytes of orig past the end of v.
+func Encode(orig []byte, v interface{}) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, v)
+	if len(orig) < b.Len() {
+		return b.Bytes()
+	}
+	out := append([]byte(nil), orig...)
+	copy(out, b.Bytes())
+	return out
+}
+
+// Record returns the base stats for export.
+func (p *PokemonStats) Record(cat *names.Catalog) export.Record {
+	var r, st, ev export.Record
+	effort := p.Effort()
+	for i, name := range StatNames {
+		st.Add(name, p.Stat[i])
+		ev.Add(name, effort[i])
+	}
+	r.Add("stats", st)
+	r.Add("effort", ev)
+	r.Add("types", []string{cat.Type(int(p.Type[0])), cat.Type(int(p.Type[1]))})
+	r.Add("catch_rate", p.CatchRate)
+	r.Add("exp_stage", p.ExpStage)
+	var items, abilities []string
+	for _, n := range p.Item {
+		items = append(items, nameOr(cat.Item(int(n)), int(n)))
+	}
+	for _, n := range p.Ability {
+		abilities = append(abilities, nameOr(cat.Ability(int(n)), int(n)))
+	}
+	r.Add("items", items)
+	r.Add("female_rate", p.FemaleRate)
+	r.Add("hatch", p.Hatch)
+	r.Add("friendship", p.Friendship)
+	r.Add("growth_rate", Enum(GrowthRates, int(p.GrowthRate)))
+	r.Add("egg_groups", []string{Enum(EggGroups, int(p.EggGroup[0])), Enum(EggGroups, int(p.EggGroup[1]))})
+	r.Add("abilities", abilities)
+	r.Add("form", p.FormStats)
+	r.Add("form_name_index", p.FormTotal)
+	r.Add("form_count", p.FormCount)
+	r.Add("color", Enum(Colors, int(p.Color)))
+	r.Add("exp", p.Exp)
+	r.Add("height", p.Height)
+	r.Add("weight", p.Weight)
+	r.Add("machines", p.Machines())
+	r.Add("tutor", p.Tutor0)
+	r.Add("extra", p.Extra)
+	return r
+}
+
+// Machines returns the names of the TMs and HMs the Pokémon can learn,
+// e.g. "TM01" or "HM03".
+func (p *PokemonStats) Machines() []string {
+	machines := []string{}
+	for i := 0; i < len(p.TM)*8; i++ {
+		if p.TM[i/8]&(1<<uint(i%8)) == 0 {
+			continue
+		}
+		if i < 100 {
+			machines = append(machines, fmt.Sprintf("TM%02d", i+1))
+		} else {
+			machines = append(machines, fmt.Sprintf("HM%02d", i-99))
+		}
+	}
+	return machines
+}
+
+// machineBit returns the bit of PokemonStats.TM for a machine name.
+func machineBit(name string) (int, bool) {
+	name = strings.ToUpper(name)
+	if len(name) < 3 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(name[2:])
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	switch {
+	case name[:2] == "TM" && n <= 100:
+		return n - 1, true
+	case name[:2] == "HM" && n <= 28:
+		return n + 99, true
+	}
+	return 0, false
+}
+
+// Decode sets the base stats from the fields of an exported record.
+func (p *PokemonStats) Decode(d *export.Decoder, cat *names.Catalog) {
+	st, ev := d.Record("stats"), d.Record("effort")
+	var effort [6]uint8
+	for i, e := range p.Effort() {
+		effort[i] = uint8(e)
+	}
+	for i, name := range StatNames {
+		st.Int(name, &p.Stat[i])
+		ev.Int(name, &effort[i])
+		if effort[i] > 3 {
+			ev.Fail(name, fmt.Errorf("%d out of range", effort[i]))
+		}
+	}
+	p.RawEffort &^= 0xFFF
+	for i, e := range effort {
+		p.RawEffort |= uint16(e&3) << uint(2*i)
+	}
+	d.IDs("types", &p.Type, cat.TypeID, cat.TypeCount())
+	d.Int("catch_rate", &p.CatchRate)
+	d.Int("exp_stage", &p.ExpStage)
+	d.IDs("items", &p.Item, cat.ItemID, cat.ItemCount())
+	d.Int("female_rate", &p.FemaleRate)
+	d.Int("hatch", &p.Hatch)
+	d.Int("friendship", &p.Friendship)
+	d.Name("growth_rate", &p.GrowthRate, EnumLookup(GrowthRates))
+	d.Names("egg_groups", &p.EggGroup, EnumLookup(EggGroups))
+	d.IDs("abilities", &p.Ability, cat.AbilityID, cat.AbilityCount())
+	d.Int("form", &p.FormStats)
+	d.Int("form_name_index", &p.FormTotal)
+	d.Int("form_count", &p.FormCount)
+	d.Name("color", &p.Color, EnumLookup(Colors))
+	d.Int("exp", &p.Exp)
+	d.Int("height", &p.Height)
+	d.Int("weight", &p.Weight)
+	if d.Has("machines") {
+		var machines []string
+		d.Strings("machines", &machines)
+		p.TM = [16]uint8{}
+		for _, m := range machines {
+			bit, ok := machineBit(m)
+			if !ok {
+				d.Fail("machines", fmt.Errorf("unknown machine %q", m))
+				break
+			}
+			p.TM[bit/8] |= 1 << uint(bit%8)
+		}
+	}
+	d.Int("tutor", &p.Tutor0)
+	d.Ints("extra", &p.Extra)
+}
+
+// Record returns the move stats for export.
+func (m *MoveStats) Record(cat *names.Catalog) export.Record {
+	var r export.Record
+	r.Add("type", cat.Type(int(m.Type)))
+	r.Add("category", m.Category)
+	r.Add("damage_class", Enum(DamageClasses, int(m.DamageClassCode)))
+	r.Add("power", m.Power)
+	r.Add("accuracy", m.Accuracy)
+	r.Add("pp", m.PP)
+	r.Add("priority", m.Priority)
+	if m.MultiHit == 0 {
+		r.Add("hits", nil)
+	} else {
+		r.Add("hits", []int{int(m.MultiHit & 0xf), int(m.MultiHit >> 4)})
+	}
+	r.Add("status", Enum(StatusNames, int(m.StatusCode)))
+	r.Add("status_chance", m.StatusChance)
+	r.Add("effect_length", m.EffectLength)
+	r.Add("turns", []uint8{m.EffectMinTurns, m.EffectMaxTurns})
+	r.Add("crit", m.Crit)
+	r.Add("flinch", m.Flinch)
+	r.Add("effect", m.Effect)
+	r.Add("recoil", m.Recoil)
+	r.Add("heal", m.Heal)
+	r.Add("target", m.Target)
+	changes := []export.Record{}
+	for i, stat := range m.StatType {
+		if stat == 0 {
+			continue
+		}
+		var c export.Record
+		c.Add("stat", Enum(MoveStatNames, int(stat)))
+		c.Add("stages", m.StatStage[i])
+		c.Add("chance", m.StatChance[i])
+		changes = append(changes, c)
+	}
+	r.Add("stat_changes", changes)
+	r.Add("flags", m.FlagNames())
+	return r
+}
+
+// FlagNames returns the names of the move's flags.
+// Unnamed flags are given by their bit number.
+func (m *MoveStats) FlagNames() []string {
+	flags := []string{}
+	for i := uint(0); i < 32; i++ {
+		if m.Flags&(1<<i) == 0 {
+			continue
+		}
+		if int(i) < len(MoveFlagNames) && MoveFlagNames[i] != "" {
+			flags = append(flags, MoveFlagNames[i])
+		} else {
+			flags = append(flags, fmt.Sprint(i))
+		}
+	}
+	return flags
+}
+
+// Decode sets the move stats from the fields of an exported record.
+func (m *MoveStats) Decode(d *export.Decoder, cat *names.Catalog) {
+	d.ID("type", &m.Type, cat.TypeID, cat.TypeCount())
+	d.Int("category", &m.Category)
+	d.Name("damage_class", &m.DamageClassCode, EnumLookup(DamageClasses))
+	d.Int("power", &m.Power)
+	d.Int("accuracy", &m.Accuracy)
+	d.Int("pp", &m.PP)
+	d.Int("priority", &m.Priority)
+	if d.Has("hits") {
+		var hits []uint8
+		d.Ints("hits", &hits)
+		switch {
+		case len(hits) == 0:
+			m.MultiHit = 0
+		case len(hits) == 2 && hits[0] <= 15 && hits[1] <= 15:
+			m.MultiHit = hits[0] | hits[1]<<4
+		default:
+			d.Fail("hits", fmt.Errorf("want null or [min, max] up to 15"))
+		}
+	}
+	d.Name("status", &m.StatusCode, EnumLookup(StatusNames))
+	d.Int("status_chance", &m.StatusChance)
+	d.Int("effect_length", &m.EffectLength)
+	turns := [2]uint8{m.EffectMinTurns, m.EffectMaxTurns}
+	d.Ints("turns", &turns)
+	m.EffectMinTurns, m.EffectMaxTurns = turns[0], turns[1]
+	d.Int("crit", &m.Crit)
+	d.Int("flinch", &m.Flinch)
+	d.Int("effect", &m.Effect)
+	d.Int("recoil", &m.Recoil)
+	d.Int("heal", &m.Heal)
+	d.Int("target", &m.Target)
+	if changes := d.List("stat_changes"); changes != nil {
+		if len(changes) > len(m.StatType) {
+			d.Fail("stat_changes", fmt.Errorf("more than %d changes", len(m.StatType)))
+		}
+		m.StatType, m.StatStage, m.StatChance = [3]uint8{}, [3]int8{}, [3]uint8{}
+		for i, c := range changes {
+			if i >= len(m.StatType) {
+				break
+			}
+			c.Name("stat", &m.StatType[i], EnumLookup(MoveStatNames))
+			c.Int("stages", &m.StatStage[i])
+			c.Int("chance", &m.StatChance[i])
+		}
+	}
+	if d.Has("flags") {
+		var flags []string
+		d.Strings("flags", &flags)
+		m.Flags = 0
+		lookup := EnumLookup(MoveFlagNames)
+		for _, f := range flags {
+			bit, ok := lookup(f)
+			if !ok {
+				n, err := strconv.Atoi(f)
+				if err != nil || n < 0 || n >= 32 {
+					d.Fail("flags", fmt.Errorf("unknown flag %q", f))
+					break
+				}
+				bit = n
+			}
+			m.Flags |= 1 << uint(bit)
+		}
+	}
+}
+
+// NaturalGiftType returns the type of Natural Gift with the item.
+func (s *ItemStats) NaturalGiftType() int { return int(s.FlagsRaw & 31) }
+
+// Flags returns the item's flags, whose meanings are mostly unknown.
+func (s *ItemStats) Flags() uint16 { return s.FlagsRaw >> 5 }
+
+// Status returns the status conditions the item cures, as a bit set.
+func (s *ItemStats) Status() uint64 {
+	return uint64(s.Status1) | uint64(s.Status2)<<32 | uint64(s.Status3)<<48
+}
+
+// Record returns the item stats for export.
+func (s *ItemStats) Record(cat *names.Catalog) export.Record {
+	var r export.Record
+	r.Add("price", s.Price())
+	r.Add("effect", s.Effect)
+	r.Add("effect_arg", s.EffectArg)
+	r.Add("natural_gift_type", nameOr(cat.Type(s.NaturalGiftType()), s.NaturalGiftType()))
+	r.Add("natural_gift_power", s.NaturalGiftPower)
+	r.Add("natural_gift_effect", s.NaturalGiftEffect)
+	r.Add("fling_power", s.FlingPower)
+	r.Add("fling_effect", s.FlingEffect)
+	r.Add("flags", s.Flags())
+	r.Add("order", s.Order)
+	r.Add("status", s.Status())
+	r.Add("effort", s.Effort)
+	r.Add("hp", s.HP)
+	r.Add("pp", s.PP)
+	r.Add("friendship", s.Friendship)
+	return r
+}
+
+// Decode sets the item stats from the fields of an exported record.
+func (s *ItemStats) Decode(d *export.Decoder, cat *names.Catalog) {
+	price := s.Price()
+	d.Int("price", &price)
+	if price%10 != 0 || price < 0 || price/10 > 0xFFFF {
+		d.Fail("price", fmt.Errorf("%d is not a multiple of 10 up to 655350", price))
+	}
+	s.PriceRaw = uint16(price / 10)
+	d.Int("effect", &s.Effect)
+	d.Int("effect_arg", &s.EffectArg)
+	giftType, flags := uint8(s.NaturalGiftType()), s.Flags()
+	d.Name("natural_gift_type", &giftType, cat.TypeID)
+	d.Int("flags", &flags)
+	if giftType > 31 || flags > 0x7FF {
+		d.Fail("flags", fmt.Errorf("natural gift type or flags out of range"))
+	}
+	s.FlagsRaw = flags<<5 | uint16(giftType&31)
+	d.Int("natural_gift_power", &s.NaturalGiftPower)
+	d.Int("natural_gift_effect", &s.NaturalGiftEffect)
+	d.Int("fling_power", &s.FlingPower)
+	d.Int("fling_effect", &s.FlingEffect)
+	d.Int("order", &s.Order)
+	status := s.Status()
+	d.Int("status", &status)
+	if status >= 1<<56 {
+		d.Fail("status", fmt.Errorf("%#x out of range", status))
+	}
+	s.Status1, s.Status2, s.Status3 = uint32(status), uint16(status>>32), uint8(status>>48)
+	d.Ints("effort", &s.Effort)
+	d.Int("hp", &s.HP)
+	d.Int("pp", &s.PP)
+	d.Ints("friendship", &s.Friendship)
+}
+
+// Record returns the learnset for export.
+func (l Learnset) Record(cat *names.Catalog) export.Record {
+	moves := []export.Record{}
+	for _, m := range l {
+		var r export.Record
+		r.Add("level", m.Level)
+		r.Add("move", nameOr(cat.Move(int(m.Move)), int(m.Move)))
+		moves = append(moves, r)
+	}
+	var r export.Record
+	r.Add("moves", moves)
+	return r
+}
+
+// Decode sets the learnset from the fields of an exported record.
+func (l *Learnset) Decode(d *export.Decoder, cat *names.Catalog) {
+	moves := d.List("moves")
+	if moves == nil {
+		return
+	}
+	*l = (*l)[:0]
+	for _, md := range moves {
+		var m LevelMove
+		md.Int("level", &m.Level)
+		md.ID("move", &m.Move, cat.MoveID, cat.MoveCount())
+		if m.Level > 100 {
+			md.Fail("level", fmt.Errorf("%d out of range", m.Level))
+		}
+		if m.Move == 0 {
+			md.Fail("move", fmt.Errorf("missing move"))
+		}
+		*l = append(*l, m)
+	}
+}
@@ -0,0 +1,39 @@
+package stats
+
+import "xy/names"
+
+// Files gives the location of the data tables in the romfs.
+type Files struct {
+	Personal       string // base stats, see PokemonStats
+	Moves          string // see MoveStats
+	Items          string // see ItemStats
+	Learnsets      string // level-up moves, see LevelMove
+	Trainers       string // see Trainer
+	TrainerPokemon string // see TrainerPokemon
+	Zones          string // zone data and wild encounters, see package zone
+	HMs            int    // number of HMs, see FindMachines
+}
+
+// GameFiles gives the Files of each game.
+var GameFiles = map[names.Version]Files{
+	names.XY: {
+		Personal:       "a/2/1/8",
+		Moves:          "a/2/1/2",
+		Items:          "a/2/2/0",
+		Learnsets:      "a/2/1/4",
+		Trainers:       "a/0/3/8",
+		TrainerPokemon: "a/0/4/0",
+		Zones:          "a/0/1/2",
+		HMs:            5,
+	},
+	names.ORAS: {
+		Personal:       "a/1/9/5",
+		Moves:          "a/1/8/9",
+		Items:          "a/1/9/7",
+		Learnsets:      "a/1/9/1",
+		Trainers:       "a/0/3/6",
+		TrainerPokemon: "a/0/3/8",
+		Zones:          "a/0/1/3",
+		HMs:            7,
+	},
+}
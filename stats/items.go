@@ -0,0 +1,43 @@
+package stats
+
+// ItemStats is the item stat structure found at
+// a/2/2/0 in Pokémon X and Y, and
+// a/1/9/7 in Pokémon Omega Ruby and Alpha Sapphire.
+type ItemStats struct {
+	PriceRaw          uint16
+	Effect            uint8
+	EffectArg         uint8
+	NaturalGiftEffect uint8
+	FlingEffect       uint8
+	FlingPower        uint8
+	NaturalGiftPower  uint8
+	FlagsRaw          uint16
+	Unknown0A         uint8
+	Unknown0B         uint8
+	Unknown0C         uint8
+	Unknown0D         uint8
+	Unknown0E         uint8
+	Order             uint8
+
+	Status1 uint32
+	Status2 uint16
+	Status3 uint8
+
+	Effort     [6]int8
+	HP         uint8
+	PP         uint8
+	Friendship [3]int8
+}
+
+// Bits of ItemStats.Flags whose meanings are known.
+const (
+	ItemMachineOrBerry = 1 << 3
+	ItemKey            = 1 << 4
+	ItemBall           = 1 << 6
+	ItemBattle         = 1 << 7
+	ItemRestoresHP     = 1 << 8 // or PP
+	ItemRestoresStatus = 1 << 9
+)
+
+// Price returns the price of the item in the shop.
+func (s *ItemStats) Price() int { return int(s.PriceRaw) * 10 }
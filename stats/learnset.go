@@ -0,0 +1,46 @@
+package stats
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// A LevelMove is a move learned by levelling up.
+type LevelMove struct {
+	Move  uint16
+	Level uint16
+}
+
+// A Learnset lists the moves a Pokémon learns by levelling up.
+type Learnset []LevelMove
+
+// ReadLearnset reads the level-up moves of a Pokémon from
+// a/2/1/4 in Pokémon X and Y, or
+// a/1/9/1 in Pokémon Omega Ruby and Alpha Sapphire.
+// The list is terminated by 0xFFFF.
+func ReadLearnset(r io.Reader) (Learnset, error) {
+	var list Learnset
+	for {
+		var m LevelMove
+		err := binary.Read(r, binary.LittleEndian, &m)
+		if err == io.EOF {
+			return list, nil
+		}
+		if err != nil {
+			return list, err
+		}
+		if m.Move == 0xFFFF {
+			return list, nil
+		}
+		list = append(list, m)
+	}
+}
+
+// Bytes returns the learnset in the format read by ReadLearnset.
+func (l Learnset) Bytes() []byte {
+	b := make([]byte, 0, 4*len(l)+4)
+	for _, m := range l {
+		b = append(b, byte(m.Move), byte(m.Move>>8), byte(m.Level), byte(m.Level>>8))
+	}
+	return append(b, 0xFF, 0xFF, 0xFF, 0xFF)
+}
@@ -0,0 +1,57 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var ErrMachines = errors.New("stats: TM list not found")
+
+// machineSignature is the start of the TM list in code.bin:
+// Hone Claws, Dragon Claw, Psyshock.
+var machineSignature = []byte{0xD4, 0x01, 0x51, 0x01, 0xD9, 0x01}
+
+// Machines is the list of moves taught by TMs and HMs.
+type Machines struct {
+	TM []uint16 // TM01 to TM100
+	HM []uint16
+}
+
+// FindMachines finds the TM list in the decompressed code.bin.
+// The list holds TM01 to TM92, then the HMs, then TM93 to TM100,
+// which were added after the HMs.
+// X and Y have 5 HMs, and Omega Ruby and Alpha Sapphire have 7.
+func FindMachines(code []byte, hms int) (*Machines, error) {
+	const tms = 100
+	const early = 92
+	i := bytes.Index(code, machineSignature)
+	if i < 0 || i+(tms+hms)*2 > len(code) {
+		return nil, ErrMachines
+	}
+	list := make([]uint16, tms+hms)
+	binary.Read(bytes.NewReader(code[i:]), binary.LittleEndian, list)
+	m := &Machines{}
+	m.TM = append(m.TM, list[:early]...)
+	m.HM = append(m.HM, list[early:early+hms]...)
+	m.TM = append(m.TM, list[early+hms:]...)
+	return m, nil
+}
+
+// MachineItem returns the item index of TM n (counting from 1),
+// or HM n if hm is set.
+// HM07, which only Omega Ruby and Alpha Sapphire have,
+// comes long after the other HMs.
+func MachineItem(n int, hm bool) int {
+	switch {
+	case hm && n == 7:
+		return 737
+	case hm:
+		return 420 + n - 1
+	case n <= 92:
+		return 328 + n - 1
+	case n <= 95:
+		return 618 + n - 93
+	}
+	return 690 + n - 96
+}
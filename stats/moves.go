@@ -1,5 +1,13 @@
 package stats
 
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"xy/garc"
+)
+
 // MoveStats is the move stat structure found at
 // a/2/1/2 in Pokémon X and Y, and
 // a/1/8/9 in Pokémon Omega Ruby and Alpha Sapphire.
@@ -30,3 +38,77 @@ type MoveStats struct {
 	Unknown1E  uint16
 	Flags      uint32
 }
+
+// MoveFlagNames are the names of the bits of MoveStats.Flags.
+var MoveFlagNames = []string{
+	0:  "contact",
+	1:  "charge",
+	2:  "recharge",
+	3:  "protect",
+	4:  "reflectable",
+	5:  "snatch",
+	6:  "mirror",
+	7:  "punch",
+	8:  "sound",
+	9:  "gravity",
+	10: "defrost",
+	11: "distance",
+	12: "heals",
+	13: "substitute",
+	14: "non-sky-battle",
+	15: "",
+}
+
+// ReadMoves reads the move stats of every move.
+// The move stats are packed into a single file with a header:
+// a magic number, the number of moves, and the offset of each move.
+func ReadMoves(r io.ReadSeeker) ([]MoveStats, error) {
+	var hdr [2]uint16
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return nil, err
+	}
+	offsets := make([]uint32, hdr[1])
+	if err := binary.Read(r, binary.LittleEndian, offsets); err != nil {
+		return nil, err
+	}
+	moves := make([]MoveStats, len(offsets))
+	for i, off := range offsets {
+		if _, err := r.Seek(int64(off), 0); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &moves[i]); err != nil {
+			return nil, err
+		}
+	}
+	return moves, nil
+}
+
+// ReadMoveFiles reads the move stats of every move from the files of the moves GARC.
+// X and Y have a file for each move, while Omega Ruby and Alpha Sapphire
+// pack them all into one file, as read by ReadMoves.
+func ReadMoveFiles(files []*garc.File) ([]MoveStats, error) {
+	if len(files) == 1 {
+		return ReadMoves(files[0])
+	}
+	moves := make([]MoveStats, len(files))
+	for i, f := range files {
+		if err := binary.Read(f, binary.LittleEndian, &moves[i]); err != nil {
+			return nil, fmt.Errorf("file %d: %v", i, err)
+		}
+	}
+	return moves, nil
+}
+
+// PutMove writes the stats of move i into b,
+// the packed file read by ReadMoves.
+func PutMove(b []byte, i int, m *MoveStats) error {
+	if len(b) < 4 || i < 0 || i >= int(binary.LittleEndian.Uint16(b[2:])) || 8+4*i > len(b) {
+		return fmt.Errorf("stats: no move %d", i)
+	}
+	off := int(binary.LittleEndian.Uint32(b[4+4*i:]))
+	if off < 0 || off+binary.Size(m) > len(b) {
+		return fmt.Errorf("stats: move %d out of bounds", i)
+	}
+	copy(b[off:], Encode(nil, m))
+	return nil
+}
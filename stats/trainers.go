@@ -0,0 +1,242 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"xy/export"
+	"xy/names"
+)
+
+// Trainer is the trainer data structure found at
+// a/0/3/8 in Pokémon X and Y, and
+// a/0/3/6 in Pokémon Omega Ruby and Alpha Sapphire.
+type Trainer struct {
+	Format       uint8 // TrainerMoves and TrainerItem
+	TrainerClass uint8
+	BattleType   uint8
+	NumPokemon   uint8
+	Items        [4]uint8
+	Unknown8     uint32
+	UnknownC     uint32
+}
+
+// Trainer formats, which say what the entries of a team hold.
+const (
+	TrainerMoves = 1 << 0
+	TrainerItem  = 1 << 1
+)
+
+// TrainerPokemon is a Pokémon in a trainer's team.
+// The teams are found at
+// a/0/4/0 in Pokémon X and Y, and
+// a/0/3/8 in Pokémon Omega Ruby and Alpha Sapphire.
+type TrainerPokemon struct {
+	Unknown uint16
+	Level   uint16
+	Pokemon uint16
+	Form    uint16
+	Item    uint16
+	Moves   []uint16
+}
+
+// trainerPokemon is the part of a team entry which is always present.
+// It is followed by the item and then four moves
+// if the trainer's format has them.
+type trainerPokemon struct {
+	Unknown uint16
+	Level   uint16
+	Pokemon uint16
+	Form    uint16
+}
+
+// ReadTrainer reads a trainer.
+func ReadTrainer(r io.Reader) (Trainer, error) {
+	var t Trainer
+	err := binary.Read(r, binary.LittleEndian, &t)
+	return t, err
+}
+
+// ReadTeam reads the team of trainer t.
+// Moves which are 0 are left out.
+func ReadTeam(r io.Reader, t *Trainer) ([]TrainerPokemon, error) {
+	if t.Format > TrainerMoves|TrainerItem {
+		return nil, fmt.Errorf("unknown trainer type: %d", t.Format)
+	}
+	team := make([]TrainerPokemon, t.NumPokemon)
+	for i := range team {
+		var p trainerPokemon
+		if err := binary.Read(r, binary.LittleEndian, &p); err != nil {
+			return nil, err
+		}
+		team[i] = TrainerPokemon{Unknown: p.Unknown, Level: p.Level, Pokemon: p.Pokemon, Form: p.Form}
+		if t.Format&TrainerItem != 0 {
+			if err := binary.Read(r, binary.LittleEndian, &team[i].Item); err != nil {
+				return nil, err
+			}
+		}
+		if t.Format&TrainerMoves != 0 {
+			var moves [4]uint16
+			if err := binary.Read(r, binary.LittleEndian, &moves); err != nil {
+				return nil, err
+			}
+			for _, m := range moves {
+				if m != 0 {
+					team[i].Moves = append(team[i].Moves, m)
+				}
+			}
+		}
+	}
+	return team, nil
+}
+
+// TeamBytes returns the team in the format read by ReadTeam.
+func TeamBytes(t *Trainer, team []TrainerPokemon) []byte {
+	var b bytes.Buffer
+	for _, p := range team {
+		binary.Write(&b, binary.LittleEndian, trainerPokemon{p.Unknown, p.Level, p.Pokemon, p.Form})
+		if t.Format&TrainerItem != 0 {
+			binary.Write(&b, binary.LittleEndian, p.Item)
+		}
+		if t.Format&TrainerMoves != 0 {
+			var moves [4]uint16
+			copy(moves[:], p.Moves)
+			binary.Write(&b, binary.LittleEndian, moves)
+		}
+	}
+	return b.Bytes()
+}
+
+func (p TrainerPokemon) String() string {
+	return p.Format(nil)
+}
+
+// Format is like String but takes the species name from cat.
+func (p TrainerPokemon) Format(cat *names.Catalog) string {
+	species := int(p.Pokemon)
+	return fmt.Sprintf("L%d %s %d (%x)", p.Level, cat.Species(species), p.Form, p.Unknown)
+}
+
+// A Team is a trainer and their Pokémon.
+type Team struct {
+	Trainer Trainer
+	Pokemon []TrainerPokemon
+}
+
+// Record returns the team for export.
+func (t *Team) Record(cat *names.Catalog) export.Record {
+	var r export.Record
+	r.Add("class", className(cat, int(t.Trainer.TrainerClass)))
+	r.Add("battle_type", t.Trainer.BattleType)
+	r.Add("items", t.Trainer.Items)
+	list := []export.Record{}
+	for _, p := range t.Pokemon {
+		var pr export.Record
+		pr.Add("level", p.Level)
+		pr.Add("species", nameOr(cat.Species(int(p.Pokemon)), int(p.Pokemon)))
+		pr.Add("form", p.Form)
+		pr.Add("unknown", p.Unknown)
+		moves := []string{}
+		for _, m := range p.Moves {
+			moves = append(moves, nameOr(cat.Move(int(m)), int(m)))
+		}
+		pr.Add("moves", moves)
+		if p.Item != 0 {
+			pr.Add("item", nameOr(cat.Item(int(p.Item)), int(p.Item)))
+		} else {
+			pr.Add("item", nil)
+		}
+		list = append(list, pr)
+	}
+	r.Add("pokemon", list)
+	return r
+}
+
+// className returns the name of trainer class n for export.
+// A name shared by several classes, such as "Leader",
+// is followed by the class number, e.g. "Leader#12",
+// so that it decodes as the same class.
+func className(cat *names.Catalog, n int) string {
+	name := cat.TrainerClass(n)
+	if name == "" {
+		return strconv.Itoa(n)
+	}
+	for i := 0; i < 256; i++ {
+		if i != n && cat.TrainerClass(i) == name {
+			return fmt.Sprintf("%s#%d", name, n)
+		}
+	}
+	return name
+}
+
+// Decode sets the team from the fields of an exported record.
+// The trainer's format is widened if any Pokémon has moves or an item.
+// A class name shared by several classes must give the class number, as Record does.
+func (t *Team) Decode(d *export.Decoder, cat *names.Catalog) {
+	classes := func(name string) (int, bool) {
+		if i := strings.LastIndex(name, "#"); i >= 0 {
+			n, err := strconv.Atoi(name[i+1:])
+			if err == nil && 0 <= n && n < 256 && cat.TrainerClass(n) == name[:i] {
+				return n, true
+			}
+		}
+		var found []int
+		for i := 0; i < 256; i++ {
+			if cat.TrainerClass(i) == name {
+				found = append(found, i)
+			}
+		}
+		switch len(found) {
+		case 0:
+			return 0, false
+		case 1:
+			return found[0], true
+		}
+		d.Fail("class", fmt.Errorf("%q names %d classes; use one of them, e.g. %q", name, len(found), fmt.Sprintf("%s#%d", name, found[0])))
+		return 0, false
+	}
+	d.Name("class", &t.Trainer.TrainerClass, classes)
+	d.Int("battle_type", &t.Trainer.BattleType)
+	d.Ints("items", &t.Trainer.Items)
+	list := d.List("pokemon")
+	if list == nil {
+		return
+	}
+	if len(list) > 6 {
+		d.Fail("pokemon", fmt.Errorf("%d Pokémon; a team has at most 6", len(list)))
+		return
+	}
+	team := make([]TrainerPokemon, len(list))
+	for i, pd := range list {
+		p := &team[i]
+		if i < len(t.Pokemon) {
+			*p = t.Pokemon[i]
+		}
+		pd.Int("level", &p.Level)
+		pd.ID("species", &p.Pokemon, cat.SpeciesID, cat.SpeciesCount())
+		pd.Int("form", &p.Form)
+		pd.Int("unknown", &p.Unknown)
+		pd.IDs("moves", &p.Moves, cat.MoveID, cat.MoveCount())
+		pd.ID("item", &p.Item, cat.ItemID, cat.ItemCount())
+		switch {
+		case p.Level < 1 || p.Level > 100:
+			pd.Fail("level", fmt.Errorf("%d out of range", p.Level))
+		case p.Pokemon == 0:
+			pd.Fail("species", fmt.Errorf("missing species"))
+		case len(p.Moves) > 4:
+			pd.Fail("moves", fmt.Errorf("more than 4 moves"))
+		}
+		if len(p.Moves) > 0 {
+			t.Trainer.Format |= TrainerMoves
+		}
+		if p.Item != 0 {
+			t.Trainer.Format |= TrainerItem
+		}
+	}
+	t.Pokemon = team
+	t.Trainer.NumPokemon = uint8(len(team))
+}
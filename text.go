@@ -1,26 +1,89 @@
 package main
 
 import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"os"
 	"log"
 	"path/filepath"
+	"regexp"
+	"regexp/syntax"
 	"strconv"
+	"strings"
+	"unicode"
 	"unicode/utf8"
 
+	"xy/export"
 	"xy/garc"
+	"xy/names"
 	"xy/text"
+	"xy/util"
 )
 
+var format = flag.String("format", "text", "output `format`: text, json, csv, or yaml")
+
 func main() {
-	filename := os.Args[1]
-	outdir := os.Args[2]
-	err := do(filename, outdir)
+	flag.Parse()
+	if flag.Arg(0) == "grep" {
+		if err := grep(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *format == "text" && flag.NArg() < 2 || flag.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "usage: text file.garc outdir")
+		fmt.Fprintln(os.Stderr, "       text -format json|csv|yaml file.garc")
+		fmt.Fprintln(os.Stderr, "       text grep [-oras] [-i] [-lang list] [-index file] [-format f] [romfs] pattern")
+		os.Exit(1)
+	}
+	filename := flag.Arg(0)
+	var err error
+	switch {
+	case *format == "text":
+		err = do(filename, flag.Arg(1))
+	case export.IsFormat(*format):
+		err = dump(filename, *format)
+	default:
+		err = fmt.Errorf("unknown format: %s", *format)
+	}
 	if err != nil {
 		log.Print(err)
 	}
 }
 
+// dump writes every line of text in the GARC to stdout as a table.
+func dump(filename, format string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gfiles, err := garc.Files(f)
+	if err != nil {
+		return err
+	}
+	var table []export.Record
+	for gnum, gfile := range gfiles {
+		ss, err := text.Read(gfile)
+		if err != nil {
+			return fmt.Errorf("%s %d: %s", filename, gnum, err)
+		}
+		for i, s := range ss {
+			var r export.Record
+			r.Add("file", gnum)
+			r.Add("line", i)
+			r.Add("text", s)
+			table = append(table, r)
+		}
+	}
+	return export.Write(os.Stdout, format, table)
+}
+
 func do(filename string, outdir string) error {
 	f, err := os.Open(filename)
 	if err != nil {
@@ -127,3 +190,398 @@ func quoteWith(s string, quote byte, ASCIIonly bool) string {
 	return string(buf)
 
 }
+
+// A corpus is the game text of every message archive in every language.
+type corpus struct {
+	Version  names.Version
+	Archives []archive
+
+	// Trigrams maps each trigram of the case-folded text
+	// to the numbers of the lines containing it, in increasing order.
+	// Lines are numbered through every file of every archive in turn.
+	// It is only built for an index file.
+	Trigrams map[string][]uint32
+}
+
+// An archive is one message GARC.
+type archive struct {
+	Path  string
+	Lang  string
+	Story bool       // story text rather than common messages
+	Files [][]string // lines of each file, escaped as by text.Escape
+}
+
+// count returns the number of lines in a.
+func (a *archive) count() int {
+	n := 0
+	for _, lines := range a.Files {
+		n += len(lines)
+	}
+	return n
+}
+
+// grep searches the game text for lines matching a regular expression
+// and prints each with the same line in the other languages.
+//
+// With -index, the text is read from an index file instead of the romfs,
+// along with the trigrams of each line, so that only the lines containing
+// every trigram of the pattern's literal text are matched against it.
+// If a romfs is given too, the index is written first.
+func grep(args []string) error {
+	flags := flag.NewFlagSet("text grep", flag.ExitOnError)
+	oras := flags.Bool("oras", false, "the romfs is from Omega Ruby or Alpha Sapphire")
+	fold := flags.Bool("i", false, "ignore case")
+	langs := flags.String("lang", "", "search only the comma-separated `languages` (default all)")
+	index := flags.String("index", "", "read the text and its trigrams from the index `file`, writing it first if a romfs is given")
+	format := flags.String("format", "text", "output `format`: text, json, csv, or yaml")
+	flags.Parse(args)
+	if flags.NArg() < 1 || flags.NArg() < 2 && *index == "" {
+		fmt.Fprintln(os.Stderr, "usage: text grep [-oras] [-i] [-lang list] [-format f] romfs pattern")
+		fmt.Fprintln(os.Stderr, "       text grep -index file [-oras] romfs pattern")
+		fmt.Fprintln(os.Stderr, "       text grep -index file [-i] [-lang list] [-format f] pattern")
+		os.Exit(1)
+	}
+	if *format != "text" && !export.IsFormat(*format) {
+		return fmt.Errorf("unknown format: %s", *format)
+	}
+	pattern := flags.Arg(flags.NArg() - 1)
+	if *fold {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	search := make(map[string]bool)
+	for _, lang := range strings.Split(*langs, ",") {
+		if lang == "" {
+			continue
+		}
+		if !isLanguage(lang) {
+			return fmt.Errorf("unknown language %q; want one of %s", lang, strings.Join(names.Languages, ", "))
+		}
+		search[lang] = true
+	}
+
+	var c *corpus
+	if flags.NArg() >= 2 {
+		v := names.XY
+		if *oras {
+			v = names.ORAS
+		}
+		c, err = readCorpus(flags.Arg(0), v)
+		if err != nil {
+			return err
+		}
+		if *index != "" {
+			c.index()
+			if err := c.write(*index); err != nil {
+				return err
+			}
+		}
+	} else {
+		c, err = readIndex(*index)
+		if err != nil {
+			return err
+		}
+	}
+
+	// If the index can narrow the search,
+	// only the candidate lines are matched.
+	var candidates map[uint32]bool
+	if tree, err := syntax.Parse(pattern, syntax.Perl); err == nil {
+		candidates = c.candidates(tree)
+	}
+
+	var table []export.Record
+	n := uint32(0) // number of the next line, as in the index
+	for ai := range c.Archives {
+		a := &c.Archives[ai]
+		if len(search) > 0 && !search[a.Lang] {
+			n += uint32(a.count())
+			continue
+		}
+		for fi, lines := range a.Files {
+			for li, s := range lines {
+				id := n
+				n++
+				if candidates != nil && !candidates[id] {
+					continue
+				}
+				if !re.MatchString(s) {
+					continue
+				}
+				// A line matching in several languages is reported once,
+				// in the first of them.
+				if c.matchedBefore(ai, fi, li, re, search) {
+					continue
+				}
+				if *format == "text" {
+					fmt.Printf("%s %d:%d %s: %s\n", a.Path, fi, li, a.Lang, s)
+					for _, b := range c.parallel(ai, fi, li) {
+						fmt.Printf("\t%s: %s\n", b.Lang, b.Files[fi][li])
+					}
+					continue
+				}
+				var r, other export.Record
+				r.Add("archive", a.Path)
+				r.Add("file", fi)
+				r.Add("line", li)
+				r.Add("lang", a.Lang)
+				r.Add("text", s)
+				for _, b := range c.parallel(ai, fi, li) {
+					other.Add(b.Lang, b.Files[fi][li])
+				}
+				r.Add("parallel", other)
+				table = append(table, r)
+			}
+		}
+	}
+	if *format != "text" {
+		return export.Write(os.Stdout, *format, table)
+	}
+	return nil
+}
+
+// parallel returns the other archives of the same kind
+// as archive ai which have line li of file fi.
+func (c *corpus) parallel(ai, fi, li int) []*archive {
+	var list []*archive
+	for bi := range c.Archives {
+		b := &c.Archives[bi]
+		if bi == ai || b.Story != c.Archives[ai].Story {
+			continue
+		}
+		if fi < len(b.Files) && li < len(b.Files[fi]) {
+			list = append(list, b)
+		}
+	}
+	return list
+}
+
+// matchedBefore reports whether line li of file fi
+// matches in a searched archive before ai of the same kind.
+func (c *corpus) matchedBefore(ai, fi, li int, re *regexp.Regexp, search map[string]bool) bool {
+	for bi := 0; bi < ai; bi++ {
+		b := &c.Archives[bi]
+		if b.Story != c.Archives[ai].Story || len(search) > 0 && !search[b.Lang] {
+			continue
+		}
+		if fi < len(b.Files) && li < len(b.Files[fi]) && re.MatchString(b.Files[fi][li]) {
+			return true
+		}
+	}
+	return false
+}
+
+// readCorpus reads the common and story text of every language.
+// Archives missing from the romfs are skipped.
+func readCorpus(name string, v names.Version) (*corpus, error) {
+	romfs, err := util.OpenFS(name)
+	if err != nil {
+		return nil, err
+	}
+	c := &corpus{Version: v}
+	for _, story := range []bool{false, true} {
+		for _, lang := range names.Languages {
+			common, storyPath, err := names.TextArchives(v, lang)
+			if err != nil {
+				return nil, err
+			}
+			a := archive{Path: common, Lang: lang, Story: story}
+			if story {
+				a.Path = storyPath
+			}
+			g, err := util.OpenGARCFS(romfs, a.Path)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", a.Path, err)
+			}
+			a.Files = make([][]string, len(g.Files))
+			for i, f := range g.Files {
+				raw, err := text.ReadRaw(f)
+				if err != nil {
+					log.Printf("%s %d: %s", a.Path, i, err)
+					continue
+				}
+				lines := make([]string, len(raw))
+				for j, s := range raw {
+					lines[j] = text.Escape(s)
+				}
+				a.Files[i] = lines
+			}
+			g.Close()
+			c.Archives = append(c.Archives, a)
+		}
+	}
+	if len(c.Archives) == 0 {
+		return nil, fmt.Errorf("%s: no message archives", name)
+	}
+	return c, nil
+}
+
+// index fills in c.Trigrams.
+func (c *corpus) index() {
+	c.Trigrams = make(map[string][]uint32)
+	n := uint32(0)
+	for _, a := range c.Archives {
+		for _, lines := range a.Files {
+			for _, s := range lines {
+				for _, t := range trigrams(s) {
+					list := c.Trigrams[t]
+					if len(list) == 0 || list[len(list)-1] != n {
+						c.Trigrams[t] = append(list, n)
+					}
+				}
+				n++
+			}
+		}
+	}
+}
+
+// candidates returns the set of lines which contain every trigram
+// of the literal text that matches of re must contain.
+// It returns nil if c has no index or re has no such trigrams,
+// in which case every line must be matched.
+func (c *corpus) candidates(re *syntax.Regexp) map[uint32]bool {
+	if c.Trigrams == nil {
+		return nil
+	}
+	var list []uint32
+	found := false
+	for _, lit := range literals(re) {
+		for _, t := range trigrams(lit) {
+			if !found {
+				list, found = c.Trigrams[t], true
+			} else {
+				list = intersect(list, c.Trigrams[t])
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+	set := make(map[uint32]bool, len(list))
+	for _, n := range list {
+		set[n] = true
+	}
+	return set
+}
+
+// literals returns strings which every match of re contains.
+// Case is ignored, as the index is of case-folded text.
+func literals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCapture, syntax.OpPlus:
+		return literals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			return literals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		// Adjacent literals, such as "ab" and "(?i)c", form one string.
+		var list []string
+		var run []rune
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpLiteral {
+				run = append(run, sub.Rune...)
+				continue
+			}
+			if len(run) > 0 {
+				list = append(list, string(run))
+				run = nil
+			}
+			list = append(list, literals(sub)...)
+		}
+		if len(run) > 0 {
+			list = append(list, string(run))
+		}
+		return list
+	}
+	return nil
+}
+
+// trigrams returns every run of three runes in s after case folding.
+func trigrams(s string) []string {
+	r := []rune(s)
+	for i := range r {
+		r[i] = fold(r[i])
+	}
+	var list []string
+	for i := 0; i+3 <= len(r); i++ {
+		list = append(list, string(r[i:i+3]))
+	}
+	return list
+}
+
+// fold returns the smallest rune equivalent to r under case folding,
+// so that runes which match each other with -i have the same trigrams.
+func fold(r rune) rune {
+	min := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+	return min
+}
+
+// intersect returns the numbers in both a and b, which are in increasing order.
+func intersect(a, b []uint32) []uint32 {
+	var list []uint32
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0] < b[0]:
+			a = a[1:]
+		case a[0] > b[0]:
+			b = b[1:]
+		default:
+			list = append(list, a[0])
+			a, b = a[1:], b[1:]
+		}
+	}
+	return list
+}
+
+func isLanguage(lang string) bool {
+	for _, l := range names.Languages {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *corpus) write(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := gob.NewEncoder(w).Encode(c); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readIndex(name string) (*corpus, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c := new(corpus)
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(c); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return c, nil
+}
@@ -0,0 +1,118 @@
+// Usage: typechart [-format csv|json] code.bin[:offset]
+// Print the type effectiveness chart from the decompressed code.bin.
+// If no offset is given, the chart is found by searching for it.
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"xy/names"
+	"xy/types"
+)
+
+var format = flag.String("format", "csv", "output `format`: csv or json")
+
+func die(v ...interface{}) {
+	fmt.Fprintln(os.Stderr, v...)
+	os.Exit(1)
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() < 1 {
+		die("usage: typechart [-format csv|json] code.bin[:offset]")
+	}
+	filename, off := splitOffset(flag.Arg(0))
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		die(err)
+	}
+
+	if off < 0 {
+		off, err = types.Find(b)
+		if err != nil {
+			die(err)
+		}
+		fmt.Fprintf(os.Stderr, "found type chart at %#x\n", off)
+	}
+	chart, err := types.Read(b, off)
+	if err != nil {
+		die(err)
+	}
+
+	switch *format {
+	case "csv":
+		err = writeCSV(chart)
+	case "json":
+		err = writeJSON(chart)
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		die(err)
+	}
+}
+
+func typeNames() []string {
+	var list []string
+	for i := 0; i < types.N; i++ {
+		list = append(list, names.Type(i))
+	}
+	return list
+}
+
+// writeCSV writes the chart with a row for each attacking type
+// and a column for each defending type.
+func writeCSV(chart *types.Chart) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write(append([]string{"attacking"}, typeNames()...))
+	for atk := 0; atk < types.N; atk++ {
+		row := []string{names.Type(atk)}
+		for def := 0; def < types.N; def++ {
+			row = append(row, strconv.FormatFloat(chart.Multiplier(atk, def), 'g', -1, 64))
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func writeJSON(chart *types.Chart) error {
+	var v struct {
+		Types []string    `json:"types"`
+		Chart [][]float64 `json:"chart"`
+	}
+	v.Types = typeNames()
+	for atk := 0; atk < types.N; atk++ {
+		var row []float64
+		for def := 0; def < types.N; def++ {
+			row = append(row, chart.Multiplier(atk, def))
+		}
+		v.Chart = append(v.Chart, row)
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(append(b, '\n'))
+	return err
+}
+
+// splitOffset splits "file:offset" into the file name and the offset,
+// which is -1 if there is none. The suffix is only taken as an offset
+// if it is a number, so that Windows paths such as C:\code.bin work.
+func splitOffset(arg string) (string, int) {
+	if i := strings.LastIndex(arg, ":"); i >= 0 {
+		if n, err := strconv.ParseInt(arg[i+1:], 0, 64); err == nil && n >= 0 {
+			return arg[:i], int(n)
+		}
+	}
+	return arg, -1
+}
@@ -0,0 +1,88 @@
+// Package types reads the type effectiveness chart from the game's code.
+package types
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// N is the number of types.
+const N = 18
+
+// Effectiveness values in the chart,
+// in quarters of the usual damage.
+const (
+	Immune           = 0
+	NotVeryEffective = 2
+	Effective        = 4
+	SuperEffective   = 8
+)
+
+// A Chart is the type effectiveness matrix,
+// indexed by attacking type and then defending type,
+// in the same order as names.Type.
+// It is stored the same way in code.bin.
+type Chart [N][N]uint8
+
+var ErrNotFound = errors.New("types: type chart not found")
+
+// normalRow is the first row of the chart:
+// Normal is not very effective against Rock and Steel,
+// and does not affect Ghost.
+var normalRow = []byte{4, 4, 4, 4, 4, 2, 4, 0, 2, 4, 4, 4, 4, 4, 4, 4, 4, 4}
+
+// Find returns the offset of the type chart in b,
+// which is usually the decompressed code.bin.
+func Find(b []byte) (int, error) {
+	for off := 0; ; {
+		i := bytes.Index(b[off:], normalRow)
+		if i < 0 {
+			return 0, ErrNotFound
+		}
+		off += i
+		if _, err := Read(b, off); err == nil {
+			return off, nil
+		}
+		off++
+	}
+}
+
+// Read reads the type chart at offset off in b.
+func Read(b []byte, off int) (*Chart, error) {
+	if off < 0 || off+N*N > len(b) {
+		return nil, fmt.Errorf("types: offset %#x out of range", off)
+	}
+	var c Chart
+	for i := range c {
+		copy(c[i][:], b[off+i*N:])
+		for j, v := range c[i] {
+			switch v {
+			case Immune, NotVeryEffective, Effective, SuperEffective:
+			default:
+				return nil, fmt.Errorf("types: bad effectiveness %d at %#x", v, off+i*N+j)
+			}
+		}
+	}
+	return &c, nil
+}
+
+// Multiplier returns the damage multiplier of attacking type atk
+// against a Pokémon of the single type def.
+func (c *Chart) Multiplier(atk, def int) float64 {
+	if atk < 0 || atk >= N || def < 0 || def >= N {
+		return 1
+	}
+	return float64(c[atk][def]) / Effective
+}
+
+// Effectiveness returns the damage multiplier of attacking type atk
+// against a Pokémon of types def1 and def2.
+// For a single-typed Pokémon pass def2 = def1 or -1.
+func (c *Chart) Effectiveness(atk, def1, def2 int) float64 {
+	m := c.Multiplier(atk, def1)
+	if def2 >= 0 && def2 != def1 {
+		m *= c.Multiplier(atk, def2)
+	}
+	return m
+}
@@ -1,13 +1,18 @@
 package util
 
 import (
+	"bytes"
+	"io"
+	"io/fs"
+	"io/ioutil"
 	"os"
 	"xy/garc"
+	"xy/romfs"
 )
 
 type GARC struct {
 	Files []*garc.File
-	f *os.File
+	f io.Closer
 }
 
 func (g *GARC) Close() error {
@@ -21,7 +26,47 @@ func OpenGARC(name string) (*GARC, error) {
 	}
 	files, err := garc.Files(f)
 	if err != nil {
+		f.Close()
 		return nil, err
 	}
 	return &GARC{Files: files, f: f}, nil
 }
+
+// OpenGARCFS opens the named GARC in fsys.
+// If the file does not support random access
+// it is read into memory first.
+func OpenGARCFS(fsys fs.FS, name string) (*GARC, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := f.(garc.Reader)
+	if !ok {
+		b, err := ioutil.ReadAll(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	files, err := garc.Files(r)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &GARC{Files: files, f: f}, nil
+}
+
+// OpenFS opens a romfs, which can be either
+// a directory the romfs was extracted to
+// or a decrypted RomFS image such as romfs.bin.
+func OpenFS(name string) (fs.FS, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return os.DirFS(name), nil
+	}
+	return romfs.Open(name)
+}
@@ -0,0 +1,113 @@
+// Package veekun imports data into a database with the veekun schema.
+//
+// The importers are written against the Tx interface,
+// which is implemented by the Postgres and SQLite backends
+// and by a dry-run backend that prints the statements it would run.
+// Queries use Postgres-style $1 placeholders for every backend.
+package veekun
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// A Row is the result of QueryRow.
+type Row interface {
+	Scan(dest ...interface{}) error
+}
+
+// Rows is the result of Query.
+type Rows interface {
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+	Close() error
+}
+
+// A Tx is a database transaction.
+type Tx interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) Row
+	Query(query string, args ...interface{}) (Rows, error)
+	Commit() error
+	Rollback() error
+}
+
+// A DB is an open database.
+type DB interface {
+	Begin() (Tx, error)
+	Close() error
+}
+
+// Open opens the database named by dsn, which is one of
+//
+//	postgres://...       a Postgres connection URL
+//	dbname=... ...       a Postgres connection string
+//	sqlite:path          an SQLite database file
+//	path.sqlite, path.db an SQLite database file
+//	dry-run:dsn          print the changes instead of making them,
+//	                     reading from the database named by dsn
+//	name                 the Postgres database name on the local server
+func Open(dsn string) (DB, error) {
+	switch {
+	case dsn == "dry-run":
+		// The importers look things up before changing anything,
+		// so there must be a database to read from.
+		return nil, fmt.Errorf("veekun: dry-run needs a database to read from, e.g. dry-run:pokedex.sqlite")
+	case strings.HasPrefix(dsn, "dry-run:"):
+		db, err := Open(strings.TrimPrefix(dsn, "dry-run:"))
+		if err != nil {
+			return nil, err
+		}
+		return &dryRunDB{db: db}, nil
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		return openPostgres(dsn)
+	case strings.HasPrefix(dsn, "sqlite:"):
+		return openSQLite(strings.TrimPrefix(dsn, "sqlite:"))
+	case strings.HasSuffix(dsn, ".sqlite"), strings.HasSuffix(dsn, ".db"):
+		return openSQLite(dsn)
+	case strings.Contains(dsn, "="):
+		return openPostgres(dsn)
+	case dsn == "" || strings.ContainsAny(dsn, ":/ "):
+		return nil, fmt.Errorf("veekun: unrecognized database %q", dsn)
+	}
+	return openPostgres(fmt.Sprintf("postgres:///%s?sslmode=disable", dsn))
+}
+
+// sqlDB is a database/sql database.
+// rebind rewrites queries for the driver's placeholder syntax.
+type sqlDB struct {
+	db     *sql.DB
+	rebind func(string) string
+}
+
+func (db *sqlDB) Begin() (Tx, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{tx, db.rebind}, nil
+}
+
+func (db *sqlDB) Close() error { return db.db.Close() }
+
+type sqlTx struct {
+	tx     *sql.Tx
+	rebind func(string) string
+}
+
+func (tx *sqlTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.tx.Exec(tx.rebind(query), args...)
+}
+
+func (tx *sqlTx) QueryRow(query string, args ...interface{}) Row {
+	return tx.tx.QueryRow(tx.rebind(query), args...)
+}
+
+func (tx *sqlTx) Query(query string, args ...interface{}) (Rows, error) {
+	return tx.tx.Query(tx.rebind(query), args...)
+}
+
+func (tx *sqlTx) Commit() error   { return tx.tx.Commit() }
+func (tx *sqlTx) Rollback() error { return tx.tx.Rollback() }
@@ -0,0 +1,123 @@
+package veekun
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// dryRunDB prints the statements that would change the database
+// instead of running them.
+// Queries are run against db.
+type dryRunDB struct {
+	db DB
+	w  io.Writer
+}
+
+func (db *dryRunDB) Begin() (Tx, error) {
+	tx := &dryRunTx{w: db.w, nextID: -1}
+	if tx.w == nil {
+		tx.w = os.Stdout
+	}
+	var err error
+	tx.tx, err = db.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (db *dryRunDB) Close() error { return db.db.Close() }
+
+type dryRunTx struct {
+	tx     Tx
+	w      io.Writer
+	nextID int64 // fake ids returned by INSERT ... RETURNING
+}
+
+// modifies reports whether the statement changes the database.
+func modifies(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "WITH":
+		return strings.Contains(strings.ToUpper(query), "SETVAL")
+	}
+	return true
+}
+
+func (tx *dryRunTx) print(query string, args []interface{}) {
+	fmt.Fprintln(tx.w, strings.Join(strings.Fields(query), " ")+";")
+	if len(args) > 0 {
+		fmt.Fprint(tx.w, "--")
+		for i, a := range args {
+			fmt.Fprintf(tx.w, " $%d=%#v", i+1, a)
+		}
+		fmt.Fprintln(tx.w)
+	}
+}
+
+func (tx *dryRunTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if !modifies(query) {
+		return tx.tx.Exec(query, args...)
+	}
+	tx.print(query, args)
+	return dryRunResult{}, nil
+}
+
+func (tx *dryRunTx) QueryRow(query string, args ...interface{}) Row {
+	if modifies(query) {
+		tx.print(query, args)
+		id := tx.nextID
+		tx.nextID--
+		return idRow(id)
+	}
+	return tx.tx.QueryRow(query, args...)
+}
+
+func (tx *dryRunTx) Query(query string, args ...interface{}) (Rows, error) {
+	if modifies(query) {
+		tx.print(query, args)
+		return noRows{}, nil
+	}
+	return tx.tx.Query(query, args...)
+}
+
+// Commit rolls back, since nothing was changed.
+func (tx *dryRunTx) Commit() error   { return tx.tx.Rollback() }
+func (tx *dryRunTx) Rollback() error { return tx.tx.Rollback() }
+
+type dryRunResult struct{}
+
+func (dryRunResult) LastInsertId() (int64, error) { return 0, nil }
+func (dryRunResult) RowsAffected() (int64, error) { return 0, nil }
+
+// idRow is the row returned by a statement with a RETURNING clause.
+// It scans a fake negative id into the first destination.
+type idRow int64
+
+func (r idRow) Scan(dest ...interface{}) error {
+	if len(dest) == 0 {
+		return nil
+	}
+	switch d := dest[0].(type) {
+	case *int:
+		*d = int(r)
+	case *int64:
+		*d = int64(r)
+	default:
+		return fmt.Errorf("veekun: dry run: cannot scan id into %T", d)
+	}
+	return nil
+}
+
+type noRows struct{}
+
+func (noRows) Next() bool                     { return false }
+func (noRows) Scan(dest ...interface{}) error { return sql.ErrNoRows }
+func (noRows) Err() error                     { return nil }
+func (noRows) Close() error                   { return nil }
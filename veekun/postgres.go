@@ -0,0 +1,15 @@
+package veekun
+
+import (
+	"database/sql"
+
+	_ "github.com/lib/pq"
+)
+
+func openPostgres(url string) (DB, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlDB{db, func(q string) string { return q }}, nil
+}
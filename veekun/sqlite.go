@@ -0,0 +1,24 @@
+package veekun
+
+import (
+	"database/sql"
+	"regexp"
+
+	_ "modernc.org/sqlite"
+)
+
+func openSQLite(path string) (DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlDB{db, sqliteRebind}, nil
+}
+
+var placeholder = regexp.MustCompile(`\$([0-9]+)`)
+
+// sqliteRebind rewrites $1 placeholders as ?1,
+// which SQLite understands with the same meaning.
+func sqliteRebind(q string) string {
+	return placeholder.ReplaceAllString(q, "?$1")
+}
@@ -0,0 +1,301 @@
+package veekun
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// A Table describes the rows of a table that Sync manages.
+type Table struct {
+	Name    string
+	Key     []string // columns identifying a row
+	Columns []string // other columns
+}
+
+// A Record is the values of a row: the key columns followed by the others.
+type Record []interface{}
+
+// A Change is a row which was added, removed, or changed.
+type Change struct {
+	Key      []interface{}
+	Old, New []interface{} // non-key values; Old is nil if added, New if removed
+}
+
+// A Diff lists the changes made to a table by Sync.
+type Diff struct {
+	Table   *Table
+	Added   []Change
+	Removed []Change
+	Changed []Change
+}
+
+// Empty reports whether there were no changes.
+func (d *Diff) Empty() bool {
+	return len(d.Added)+len(d.Removed)+len(d.Changed) == 0
+}
+
+// Sync makes the rows of t selected by the condition where
+// equal to want.
+// Rows in want which are missing are inserted,
+// rows whose values differ are updated,
+// and rows selected by where which are not in want are deleted.
+// Rows are matched by their key columns.
+// The condition's placeholders refer to args.
+// An empty condition selects only the rows with the keys in want,
+// so nothing is deleted.
+func Sync(tx Tx, t *Table, where string, args []interface{}, want []Record) (*Diff, error) {
+	nkey := len(t.Key)
+	cols := append(append([]string(nil), t.Key...), t.Columns...)
+	for _, r := range want {
+		if len(r) != len(cols) {
+			return nil, fmt.Errorf("veekun: %s: record has %d values, want %d", t.Name, len(r), len(cols))
+		}
+	}
+
+	have := make(map[string][]interface{})
+	if where != "" {
+		q := fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(cols, ", "), t.Name, where)
+		rows, err := tx.Query(q, args...)
+		if err != nil {
+			return nil, fmt.Errorf("veekun: %s: %v", t.Name, err)
+		}
+		for rows.Next() {
+			vals := make([]interface{}, len(cols))
+			ptrs := make([]interface{}, len(cols))
+			for i := range vals {
+				ptrs[i] = &vals[i]
+			}
+			if err := rows.Scan(ptrs...); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			for i := range vals {
+				vals[i] = normalize(vals[i])
+			}
+			have[keyString(vals[:nkey])] = vals
+		}
+		if err := rows.Close(); err != nil {
+			return nil, err
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	d := &Diff{Table: t}
+	seen := make(map[string]bool)
+	for _, r := range want {
+		vals := make([]interface{}, len(r))
+		for i := range r {
+			vals[i] = normalize(r[i])
+		}
+		k := keyString(vals[:nkey])
+		if seen[k] {
+			return nil, fmt.Errorf("veekun: %s: duplicate key %s", t.Name, k)
+		}
+		seen[k] = true
+		old, ok := have[k]
+		if !ok && where == "" {
+			var err error
+			old, ok, err = selectRow(tx, t, cols, vals[:nkey])
+			if err != nil {
+				return nil, err
+			}
+		}
+		switch {
+		case !ok:
+			if err := insert(tx, t, cols, vals); err != nil {
+				return nil, err
+			}
+			d.Added = append(d.Added, Change{Key: vals[:nkey], New: vals[nkey:]})
+		case !equalValues(old[nkey:], vals[nkey:]):
+			if err := update(tx, t, vals); err != nil {
+				return nil, err
+			}
+			d.Changed = append(d.Changed, Change{Key: vals[:nkey], Old: old[nkey:], New: vals[nkey:]})
+		}
+	}
+
+	var removed []string
+	for k := range have {
+		if !seen[k] {
+			removed = append(removed, k)
+		}
+	}
+	sort.Strings(removed)
+	for _, k := range removed {
+		old := have[k]
+		if err := remove(tx, t, old[:nkey]); err != nil {
+			return nil, err
+		}
+		d.Removed = append(d.Removed, Change{Key: old[:nkey], Old: old[nkey:]})
+	}
+	return d, nil
+}
+
+func selectRow(tx Tx, t *Table, cols []string, key []interface{}) ([]interface{}, bool, error) {
+	q := fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(cols, ", "), t.Name, keyCondition(t.Key, 1))
+	rows, err := tx.Query(q, key...)
+	if err != nil {
+		return nil, false, fmt.Errorf("veekun: %s: %v", t.Name, err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, false, rows.Err()
+	}
+	vals := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return nil, false, err
+	}
+	for i := range vals {
+		vals[i] = normalize(vals[i])
+	}
+	return vals, true, nil
+}
+
+// keyCondition returns "a = $n AND b = $n+1 ...".
+func keyCondition(key []string, n int) string {
+	var conds []string
+	for i, c := range key {
+		conds = append(conds, fmt.Sprintf("%s = $%d", c, n+i))
+	}
+	return strings.Join(conds, " AND ")
+}
+
+func insert(tx Tx, t *Table, cols []string, vals []interface{}) error {
+	var ph []string
+	for i := range cols {
+		ph = append(ph, fmt.Sprintf("$%d", i+1))
+	}
+	q := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", t.Name, strings.Join(cols, ", "), strings.Join(ph, ", "))
+	_, err := tx.Exec(q, vals...)
+	if err != nil {
+		return fmt.Errorf("veekun: %s: inserting %v: %v", t.Name, vals, err)
+	}
+	return nil
+}
+
+func update(tx Tx, t *Table, vals []interface{}) error {
+	nkey := len(t.Key)
+	var sets []string
+	for i, c := range t.Columns {
+		sets = append(sets, fmt.Sprintf("%s = $%d", c, i+1))
+	}
+	q := fmt.Sprintf("UPDATE %s SET %s WHERE %s", t.Name, strings.Join(sets, ", "), keyCondition(t.Key, len(t.Columns)+1))
+	args := append(append([]interface{}(nil), vals[nkey:]...), vals[:nkey]...)
+	_, err := tx.Exec(q, args...)
+	if err != nil {
+		return fmt.Errorf("veekun: %s: updating %v: %v", t.Name, vals, err)
+	}
+	return nil
+}
+
+func remove(tx Tx, t *Table, key []interface{}) error {
+	q := fmt.Sprintf("DELETE FROM %s WHERE %s", t.Name, keyCondition(t.Key, 1))
+	_, err := tx.Exec(q, key...)
+	if err != nil {
+		return fmt.Errorf("veekun: %s: deleting %v: %v", t.Name, key, err)
+	}
+	return nil
+}
+
+// normalize converts values to the types drivers return,
+// so that values read from the database compare equal to extracted ones.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case []byte:
+		return string(v)
+	}
+	return v
+}
+
+func equalValues(a, b []interface{}) bool {
+	for i := range a {
+		if !equalValue(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// equalValue compares two normalized values.
+// SQLite has no boolean type, so booleans equal 0 and 1.
+func equalValue(a, b interface{}) bool {
+	if ab, ok := a.(bool); ok {
+		a = boolInt(ab)
+	}
+	if bb, ok := b.(bool); ok {
+		b = boolInt(bb)
+	}
+	return a == b
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func keyString(key []interface{}) string {
+	var parts []string
+	for _, v := range key {
+		parts = append(parts, fmt.Sprint(v))
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// WriteTo writes a report of the changes, one row per line:
+//
+//	table + (key) column=value ...
+//	table - (key) column=value ...
+//	table ~ (key) column: old -> new ...
+func (d *Diff) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	t := d.Table
+	for _, c := range d.Added {
+		fmt.Fprintf(&b, "%s + %s", t.Name, keyString(c.Key))
+		for i, v := range c.New {
+			fmt.Fprintf(&b, " %s=%v", t.Columns[i], v)
+		}
+		b.WriteString("\n")
+	}
+	for _, c := range d.Removed {
+		fmt.Fprintf(&b, "%s - %s", t.Name, keyString(c.Key))
+		for i, v := range c.Old {
+			fmt.Fprintf(&b, " %s=%v", t.Columns[i], v)
+		}
+		b.WriteString("\n")
+	}
+	for _, c := range d.Changed {
+		fmt.Fprintf(&b, "%s ~ %s", t.Name, keyString(c.Key))
+		for i := range c.New {
+			if !equalValue(c.Old[i], c.New[i]) {
+				fmt.Fprintf(&b, " %s: %v -> %v", t.Columns[i], c.Old[i], c.New[i])
+			}
+		}
+		b.WriteString("\n")
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
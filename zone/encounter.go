@@ -0,0 +1,177 @@
+package zone
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"xy/export"
+	"xy/names"
+)
+
+// Encounter is the wild encounter table of a zone in X and Y,
+// found at EncounterOffset in the zone's file.
+// Omega Ruby and Alpha Sapphire have a different layout; see CheckEncounters.
+//
+// The header has a byte for each of the first ten methods,
+// which is 0 if the zone doesn't have it.
+type Encounter struct {
+	Header [16]byte
+	Grass  [12]Slot
+	Flower [3][12]Slot
+	Rough  [12]Slot
+
+	Water     [5]Slot
+	RockSmash [5]Slot
+
+	Fishing [3][3]Slot
+	Horde   [3][5]Slot
+}
+
+// ErrEncounterLayout is returned by CheckEncounters for games
+// whose encounter tables aren't laid out as an Encounter.
+var ErrEncounterLayout = errors.New("zone: Omega Ruby and Alpha Sapphire encounter tables are not supported")
+
+// CheckEncounters returns ErrEncounterLayout unless
+// the game's encounter tables can be read as Encounters.
+// Only X and Y's can: Omega Ruby and Alpha Sapphire
+// have no flower or rough terrain encounters,
+// and reading or writing their tables as Encounters would garble them.
+func CheckEncounters(v names.Version) error {
+	if v != names.XY {
+		return ErrEncounterLayout
+	}
+	return nil
+}
+
+// Slot is an encounter slot.
+type Slot struct {
+	Pokemon  uint16
+	MinLevel uint8
+	MaxLevel uint8
+}
+
+func (s Slot) Species() int { return int(s.Pokemon & 0x7FF) }
+func (s Slot) Form() int    { return int(s.Pokemon >> 11) }
+
+// ReadEncounter returns the encounter table in the decompressed zone file b,
+// or nil if the zone has none.
+func ReadEncounter(b []byte) (*Encounter, error) {
+	off := EncounterOffset(b)
+	if off < 0 {
+		return nil, nil
+	}
+	var enc Encounter
+	if err := binary.Read(bytes.NewReader(b[off:]), le, &enc); err != nil {
+		return nil, err
+	}
+	return &enc, nil
+}
+
+// WriteEncounter writes enc over the encounter table in the zone file b.
+func WriteEncounter(b []byte, enc *Encounter) error {
+	off := EncounterOffset(b)
+	if off < 0 || off+binary.Size(enc) > len(b) {
+		return fmt.Errorf("zone: no room for encounters")
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, le, enc)
+	copy(b[off:], buf.Bytes())
+	return nil
+}
+
+// An EncounterMethod names a list of slots in an Encounter.
+type EncounterMethod struct {
+	Name   string
+	Header int // index in Encounter.Header, or -1
+	Slots  func(*Encounter) []Slot
+}
+
+// EncounterMethods lists the slots of an Encounter.
+var EncounterMethods = []EncounterMethod{
+	{"walk", 0, func(e *Encounter) []Slot { return e.Grass[:] }},
+	{"yellow_flowers", 1, func(e *Encounter) []Slot { return e.Flower[0][:] }},
+	{"purple_flowers", 2, func(e *Encounter) []Slot { return e.Flower[1][:] }},
+	{"red_flowers", 3, func(e *Encounter) []Slot { return e.Flower[2][:] }},
+	{"rough_terrain", 4, func(e *Encounter) []Slot { return e.Rough[:] }},
+	{"surf", 5, func(e *Encounter) []Slot { return e.Water[:] }},
+	{"rock_smash", 6, func(e *Encounter) []Slot { return e.RockSmash[:] }},
+	{"old_rod", 7, func(e *Encounter) []Slot { return e.Fishing[0][:] }},
+	{"good_rod", 8, func(e *Encounter) []Slot { return e.Fishing[1][:] }},
+	{"super_rod", 9, func(e *Encounter) []Slot { return e.Fishing[2][:] }},
+	{"horde_1", -1, func(e *Encounter) []Slot { return e.Horde[0][:] }},
+	{"horde_2", -1, func(e *Encounter) []Slot { return e.Horde[1][:] }},
+	{"horde_3", -1, func(e *Encounter) []Slot { return e.Horde[2][:] }},
+}
+
+// Has reports whether the zone has the method's encounters.
+func (e *Encounter) Has(m *EncounterMethod) bool {
+	return m.Header < 0 || e.Header[m.Header] != 0
+}
+
+// Record returns the encounter table for export.
+// Methods the zone doesn't have are null.
+func (e *Encounter) Record(cat *names.Catalog) export.Record {
+	var r export.Record
+	r.Add("header", e.Header)
+	for i := range EncounterMethods {
+		m := &EncounterMethods[i]
+		if !e.Has(m) {
+			r.Add(m.Name, nil)
+			continue
+		}
+		list := []export.Record{}
+		for _, t := range m.Slots(e) {
+			var s export.Record
+			s.Add("species", cat.Species(t.Species()))
+			s.Add("form", t.Form())
+			s.Add("min_level", t.MinLevel)
+			s.Add("max_level", t.MaxLevel)
+			list = append(list, s)
+		}
+		r.Add(m.Name, list)
+	}
+	return r
+}
+
+// Decode sets the encounter table from the fields of an exported record.
+// Each list of slots must be complete.
+// A null method is removed by clearing its header byte;
+// the header field must be used to add one.
+func (e *Encounter) Decode(d *export.Decoder, cat *names.Catalog) {
+	d.Ints("header", &e.Header)
+	for i := range EncounterMethods {
+		m := &EncounterMethods[i]
+		if !d.Has(m.Name) {
+			continue
+		}
+		list := d.List(m.Name)
+		if len(list) == 0 {
+			if m.Header >= 0 {
+				e.Header[m.Header] = 0
+			}
+			continue
+		}
+		slots := m.Slots(e)
+		if len(list) != len(slots) {
+			d.Fail(m.Name, fmt.Errorf("%d slots, want %d", len(list), len(slots)))
+			return
+		}
+		for j, sd := range list {
+			s := &slots[j]
+			species, form := s.Species(), s.Form()
+			sd.ID("species", &species, cat.SpeciesID, cat.SpeciesCount())
+			sd.Int("form", &form)
+			sd.Int("min_level", &s.MinLevel)
+			sd.Int("max_level", &s.MaxLevel)
+			switch {
+			case species > 0x7FF || form > 0x1F:
+				sd.Fail("species", fmt.Errorf("species %d form %d out of range", species, form))
+			case s.MinLevel > s.MaxLevel || s.MaxLevel > 100:
+				sd.Fail("max_level", fmt.Errorf("levels %d-%d out of range", s.MinLevel, s.MaxLevel))
+			}
+			s.Pokemon = uint16(species&0x7FF | form<<11)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Package zone decodes the zone table found in Pokémon X and Y
+// and Omega Ruby and Alpha Sapphire.
+//
+// The zone table is the last file in
+// a/0/1/2 in Pokémon X and Y, and
+// a/0/1/3 in Pokémon Omega Ruby and Alpha Sapphire.
+// Every other file in the archive belongs to the zone with the same index
+// and holds, among other things, the zone's wild encounter tables.
+package zone
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"xy/garc"
+)
+
+var le = binary.LittleEndian
+
+// Size is the size of a zone record in bytes.
+const Size = 0x38
+
+var ErrSize = errors.New("zone: table size is not a multiple of the record size")
+
+// Zone is a single zone record.
+//
+// Only the location has been confirmed against the games' data;
+// the other fields are named after the values observed in them.
+type Zone struct {
+	MapType    uint8  // 0x00
+	MapFlags   uint8  // 0x01
+	MapMatrix  uint16 // 0x02: index of the map matrix in a/0/3/9 (XY)
+	ScriptFile uint16 // 0x04: index of the zone script
+	TextFile   uint16 // 0x06: index of the zone's message file in a/0/8/x (XY)
+	BGMDay     uint32 // 0x08: sound ID of the daytime music
+	BGMNight   uint32 // 0x0C: sound ID of the nighttime music
+	Unknown10  uint16
+	Unknown12  uint16
+	Weather    uint8 // 0x14
+	BattleBG   uint8 // 0x15: battle background
+	Unknown16  uint16
+	FlyFlags   uint16 // 0x18
+	Unknown1A  uint16
+	Location   uint8 // 0x1C: index into the location names, see names.Location
+	Unknown1D  uint8
+	Unknown1E  uint16
+	Camera     [4]uint32
+	Unknown30  [2]uint32
+}
+
+// Fly flags.
+const (
+	FlagFly            = 1 << 0 // Fly can be used to leave the zone
+	FlagEscape         = 1 << 1 // Dig and Escape Rope can be used
+	FlagTeleport       = 1 << 2 // Teleport can be used
+	FlagBike           = 1 << 3 // the bicycle can be ridden
+	FlagRollerSkates   = 1 << 4 // roller skates can be used
+	FlagFlyDestination = 1 << 5 // the zone is a Fly destination
+)
+
+func (z *Zone) CanFly() bool           { return z.FlyFlags&FlagFly != 0 }
+func (z *Zone) CanEscape() bool        { return z.FlyFlags&FlagEscape != 0 }
+func (z *Zone) CanTeleport() bool      { return z.FlyFlags&FlagTeleport != 0 }
+func (z *Zone) CanBike() bool          { return z.FlyFlags&FlagBike != 0 }
+func (z *Zone) IsFlyDestination() bool { return z.FlyFlags&FlagFlyDestination != 0 }
+
+// Decode decodes a zone table.
+func Decode(b []byte) ([]Zone, error) {
+	if len(b)%Size != 0 {
+		return nil, ErrSize
+	}
+	zones := make([]Zone, len(b)/Size)
+	err := binary.Read(bytes.NewReader(b), le, zones)
+	if err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
+
+// Read reads a zone table from r.
+func Read(r io.Reader) ([]Zone, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(b)
+}
+
+// ReadGARC reads the zone table from the files of a zone data archive.
+// Zone i's encounter file is files[i].
+func ReadGARC(files []*garc.File) ([]Zone, error) {
+	if len(files) == 0 {
+		return nil, errors.New("zone: empty archive")
+	}
+	return Read(files[len(files)-1])
+}
+
+// EncounterOffset returns the offset of the encounter tables
+// within the (decompressed) zone file b,
+// or -1 if the zone has no encounters.
+func EncounterOffset(b []byte) int {
+	if len(b) < 0x14 {
+		return -1
+	}
+	off := int(le.Uint32(b[0x10:]))
+	if off < 0 || off >= len(b) {
+		return -1
+	}
+	return off
+}